@@ -17,66 +17,191 @@ package main
 // @schemes http https
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
 
 	"tempmail/internal/config"
+	"tempmail/internal/dnsbl"
 	"tempmail/internal/handler"
+	"tempmail/internal/health"
+	"tempmail/internal/logging"
+	"tempmail/internal/mailauth"
+	"tempmail/internal/metrics"
+	"tempmail/internal/ratelimit"
 	"tempmail/internal/repository"
+	"tempmail/internal/resolver"
 	"tempmail/internal/service"
 	smtpserver "tempmail/internal/smtp"
+	"tempmail/internal/storage"
+	"tempmail/internal/tracing"
 )
 
 func main() {
 	// Загружаем конфигурацию
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Ошибка загрузки конфигурации:", err)
+		fatal(slog.Default(), "failed to load config", err)
 	}
 
-	fmt.Println("=== TempMail Server ===")
+	// Структурированный логгер (LOG_LEVEL, LOG_FORMAT); передаётся всем компонентам
+	logger, err := logging.New(cfg.Log, os.Stdout)
+	if err != nil {
+		fatal(slog.Default(), "invalid log config", err)
+	}
+
+	logger.Info("starting tempmail", slog.String("component", "api"))
 
-	// Подключаемся к базе данных
-	fmt.Println("Подключение к PostgreSQL...")
-	db, err := repository.NewPostgresDB(cfg.Database)
+	// Трассировка OpenTelemetry (TRACING_ENABLED); спаны уходят в OTLP-коллектор
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "tempmail-api")
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err)
+		fatal(logger, "invalid tracing config", err)
 	}
-	defer db.Close()
-	fmt.Println("Подключение успешно!")
 
-	// Создаём репозитории
-	mailboxRepo := repository.NewMailboxRepository(db.DB)
-	messageRepo := repository.NewMessageRepository(db.DB)
+	// Реестр метрик Prometheus; передаётся всем компонентам
+	appMetrics := metrics.New()
+
+	// Открываем хранилище данных (STORAGE_BACKEND): PostgreSQL, файл SQLite или память процесса
+	store, err := storage.Open(cfg.Storage, cfg.Database, appMetrics)
+	if err != nil {
+		fatal(logger, "failed to open storage", err)
+	}
+	defer store.Close()
+	logger.Info("storage opened", slog.String("backend", cfg.Storage.Backend))
+
+	// Постоянная статистика: события копятся и записываются в БД
+	// События SMTP приходят через метрики, события сервисов — через observer
+	statsService := service.NewStatsService(store.Stats, cfg.Stats, logger.With(slog.String("component", "stats")))
+	appMetrics.SetRecorder(statsService)
+	stopStats := make(chan struct{})
+	go statsService.Run(stopStats)
+
+	// События сервисов получают метрики и постоянная статистика
+	observer := service.Observers{appMetrics, statsService}
 
 	// Создаём сервисы
-	mailboxService := service.NewMailboxService(mailboxRepo, cfg.Mail)
-	messageService := service.NewMessageService(messageRepo, mailboxRepo, cfg.Limits)
+	addressGenerator, err := service.NewAddressGenerator(cfg.Mail)
+	if err != nil {
+		fatal(logger, "invalid address generator config", err)
+	}
+	addressPolicy, err := service.NewAddressPolicy(cfg.Mail)
+	if err != nil {
+		fatal(logger, "invalid address policy config", err)
+	}
+	mailboxService := service.NewMailboxService(store.Mailboxes, store.APIKeys, cfg.Mail, addressGenerator, addressPolicy, observer, logger)
+	messageService := service.NewMessageService(store.Messages, store.Mailboxes, cfg.Limits, cfg.Mail, observer)
+	apiKeyService := service.NewAPIKeyService(store.APIKeys)
+	adminService := service.NewAdminService(store.Mailboxes, store.Messages, observer)
+
+	// Запускаем фоновую очистку истёкших ящиков
+	stopCleanup := make(chan struct{})
+	go mailboxService.RunCleanup(stopCleanup)
 
 	// Создаём обработчики
 	mailboxHandler := handler.NewMailboxHandler(mailboxService)
 	messageHandler := handler.NewMessageHandler(messageService)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+	adminHandler := handler.NewAdminHandler(adminService)
+	statsHandler := handler.NewStatsHandler(statsService, appMetrics)
+	auth := handler.NewAuthMiddleware(apiKeyService, mailboxService, cfg.Auth)
+
+	// Проверка SPF/DKIM/DMARC входящих писем
+	var verifier *mailauth.Verifier
+	if cfg.SMTP.AuthCheck {
+		verifier = mailauth.NewVerifier(resolver.New(cfg.DNS), cfg.DNS)
+	}
+
+	// Redis подключаем, только если его использует какое-либо хранилище
+	var redisClient *redis.Client
+	if cfg.UsesRedis() {
+		redisClient, err = repository.NewRedisClient(cfg.Redis)
+		if err != nil {
+			fatal(logger, "failed to connect to redis", err)
+		}
+		defer redisClient.Close()
+	}
+
+	// Хранилище счётчиков лимитов — общее для SMTP и REST API
+	rateStore, err := ratelimit.NewStore(cfg.RateLimit, redisClient)
+	if err != nil {
+		fatal(logger, "invalid rate limit store config", err)
+	}
+
+	// Защита SMTP-сервера: лимиты соединений и писем, списки IP
+	protection, err := smtpserver.NewProtection(cfg.SMTP, rateStore, appMetrics, logger)
+	if err != nil {
+		fatal(logger, "invalid smtp protection config", err)
+	}
+
+	// Лимиты запросов к REST API используют то же хранилище счётчиков
+	limits, err := handler.NewRateLimiter(cfg.APILimits, rateStore)
+	if err != nil {
+		fatal(logger, "invalid api rate limit config", err)
+	}
 
 	// Создаём Fiber-приложение
+	// За обратным прокси IP клиента берём из заголовка (HTTP_PROXY_HEADER)
 	app := fiber.New(fiber.Config{
-		AppName: "TempMail API",
+		AppName:                 "TempMail API",
+		DisableStartupMessage:   true, // Запуск пишем в структурированный лог
+		ProxyHeader:             cfg.Server.ProxyHeader,
+		EnableIPValidation:      cfg.Server.ProxyHeader != "",
+		EnableTrustedProxyCheck: len(cfg.Server.TrustedProxies) > 0,
+		TrustedProxies:          cfg.Server.TrustedProxies,
 	})
 
+	// Проверки готовности (/readyz); проверку SMTP добавим после создания сервера
+	checks := health.NewRegistry(cfg.Health.Timeout)
+	store.RegisterChecks(checks)
+	if redisClient != nil {
+		checks.Register("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+	checks.Register("cleanup", mailboxService.CheckCleanup)
+
 	// Настраиваем маршруты
-	handler.SetupRoutes(app, mailboxHandler, messageHandler)
+	handler.SetupRoutes(app, mailboxHandler, messageHandler, apiKeyHandler, adminHandler, statsHandler, auth, limits, appMetrics, checks, cfg.Server.RequestTimeout, logger.With(slog.String("component", "http")))
+
+	// Проверка IP клиентов по чёрным спискам (без DNSBL_ZONES выключена)
+	blocklistDNS := cfg.DNS
+	if cfg.DNSBL.Server != "" {
+		blocklistDNS.Server = cfg.DNSBL.Server
+	}
+	blocklist, err := dnsbl.NewChecker(resolver.New(blocklistDNS), cfg.DNSBL, cfg.DNS, logger)
+	if err != nil {
+		fatal(logger, "invalid dnsbl config", err)
+	}
+
+	// Грейлистинг (GREYLIST_ENABLED)
+	var greylistStore service.GreylistStore
+	switch cfg.Greylist.Store {
+	case "storage":
+		greylistStore = store.Greylist // Основное хранилище (STORAGE_BACKEND)
+	case "redis":
+		greylistStore = repository.NewRedisGreylist(redisClient)
+	default:
+		fatal(logger, "unknown greylist store", fmt.Errorf("GREYLIST_STORE=%q", cfg.Greylist.Store))
+	}
+	greylist := smtpserver.NewGreylist(cfg.Greylist, greylistStore, logger)
 
 	// Создаём SMTP-сервер
-	smtpServer := smtpserver.NewServer(cfg.Server, cfg.Mail, mailboxService, messageService)
+	smtpServer, err := smtpserver.NewServer(cfg.Server, cfg.Mail, cfg.SMTP, mailboxService, messageService, verifier, protection, blocklist, greylist, appMetrics, logger.With(slog.String("component", "smtp")))
+	if err != nil {
+		fatal(logger, "invalid smtp server config", err)
+	}
+	checks.Register("smtp", smtpServer.CheckListeners)
 
 	// Запускаем SMTP-сервер в отдельной горутине
 	go func() {
 		if err := smtpServer.Start(); err != nil {
-			log.Printf("SMTP-сервер остановлен: %v", err)
+			logger.Error("smtp server stopped", logging.Err(err))
 		}
 	}()
 
@@ -84,20 +209,36 @@ func main() {
 	go func() {
 		addr := fmt.Sprintf(":%d", cfg.Server.HTTPPort)
 		if err := app.Listen(addr); err != nil {
-			log.Printf("HTTP-сервер остановлен: %v", err)
+			logger.Error("http server stopped", logging.Err(err))
 		}
 	}()
 
-	fmt.Printf("\nHTTP API: http://localhost:%d\n", cfg.Server.HTTPPort)
-	fmt.Printf("SMTP: localhost:%d\n", cfg.Server.SMTPPort)
-	fmt.Println("\nНажмите Ctrl+C для остановки")
+	logger.Info("servers started", slog.Int("http_port", cfg.Server.HTTPPort), slog.Int("smtp_port", cfg.Server.SMTPPort))
 
 	// Ожидаем сигнал завершения
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	fmt.Println("\nОстановка серверов...")
+	logger.Info("shutting down")
+	close(stopCleanup)
 	smtpServer.Close()
 	app.Shutdown()
+
+	// Записываем события, накопленные до остановки серверов
+	close(stopStats)
+	if err := statsService.Flush(context.Background()); err != nil {
+		logger.Error("stats flush failed", logging.Err(err))
+	}
+
+	// Отправляем спаны, накопленные до остановки
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error("tracing shutdown failed", logging.Err(err))
+	}
+}
+
+// fatal пишет ошибку запуска в лог и завершает процесс
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
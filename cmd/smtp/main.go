@@ -1,49 +1,209 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/redis/go-redis/v9"
 
 	"tempmail/internal/config"
+	"tempmail/internal/dnsbl"
+	"tempmail/internal/health"
+	"tempmail/internal/logging"
+	"tempmail/internal/mailauth"
+	"tempmail/internal/metrics"
+	"tempmail/internal/ratelimit"
 	"tempmail/internal/repository"
+	"tempmail/internal/resolver"
 	"tempmail/internal/service"
 	smtpserver "tempmail/internal/smtp"
+	"tempmail/internal/storage"
+	"tempmail/internal/tracing"
 )
 
 func main() {
+	// Код выхода задаётся при остановке; os.Exit вызывается последним,
+	// после отложенного закрытия хранилища и Redis
+	exitCode := 0
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+
 	// Загружаем конфигурацию
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatal("Ошибка загрузки конфигурации:", err)
+		fatal(slog.Default(), "failed to load config", err)
+	}
+
+	// Структурированный логгер (LOG_LEVEL, LOG_FORMAT); передаётся всем компонентам
+	logger, err := logging.New(cfg.Log, os.Stdout)
+	if err != nil {
+		fatal(slog.Default(), "invalid log config", err)
 	}
 
-	fmt.Println("=== TempMail SMTP Server ===")
+	logger.Info("starting tempmail", slog.String("component", "smtp"))
 
-	// Подключаемся к базе данных
-	fmt.Println("Подключение к PostgreSQL...")
-	db, err := repository.NewPostgresDB(cfg.Database)
+	// Трассировка OpenTelemetry (TRACING_ENABLED); спаны уходят в OTLP-коллектор
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing, "tempmail-smtp")
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err)
+		fatal(logger, "invalid tracing config", err)
 	}
-	defer db.Close()
-	fmt.Println("Подключение успешно!")
 
-	// Создаём репозитории
-	mailboxRepo := repository.NewMailboxRepository(db.DB)
-	messageRepo := repository.NewMessageRepository(db.DB)
+	// Реестр метрик Prometheus; передаётся всем компонентам
+	appMetrics := metrics.New()
+
+	// Открываем хранилище данных (STORAGE_BACKEND): PostgreSQL, файл SQLite или память процесса
+	store, err := storage.Open(cfg.Storage, cfg.Database, appMetrics)
+	if err != nil {
+		fatal(logger, "failed to open storage", err)
+	}
+	defer store.Close()
+	logger.Info("storage opened", slog.String("backend", cfg.Storage.Backend))
+
+	// Постоянная статистика: счётчики SMTP-сервера попадают в общий /stats
+	statsService := service.NewStatsService(store.Stats, cfg.Stats, logger.With(slog.String("component", "stats")))
+	appMetrics.SetRecorder(statsService)
+	stopStats := make(chan struct{})
+	go statsService.Run(stopStats)
+
+	// События сервисов получают метрики и постоянная статистика
+	observer := service.Observers{appMetrics, statsService}
 
 	// Создаём сервисы
-	mailboxService := service.NewMailboxService(mailboxRepo, cfg.Mail)
-	messageService := service.NewMessageService(messageRepo, mailboxRepo, cfg.Limits)
+	addressGenerator, err := service.NewAddressGenerator(cfg.Mail)
+	if err != nil {
+		fatal(logger, "invalid address generator config", err)
+	}
+	addressPolicy, err := service.NewAddressPolicy(cfg.Mail)
+	if err != nil {
+		fatal(logger, "invalid address policy config", err)
+	}
+	mailboxService := service.NewMailboxService(store.Mailboxes, store.APIKeys, cfg.Mail, addressGenerator, addressPolicy, observer, logger)
+	messageService := service.NewMessageService(store.Messages, store.Mailboxes, cfg.Limits, cfg.Mail, observer)
+
+	// Запускаем фоновую очистку истёкших ящиков
+	stopCleanup := make(chan struct{})
+	go mailboxService.RunCleanup(stopCleanup)
+
+	// Проверка SPF/DKIM/DMARC входящих писем
+	var verifier *mailauth.Verifier
+	if cfg.SMTP.AuthCheck {
+		verifier = mailauth.NewVerifier(resolver.New(cfg.DNS), cfg.DNS)
+	}
+
+	// Redis подключаем, только если его использует какое-либо хранилище
+	var redisClient *redis.Client
+	if cfg.UsesRedis() {
+		redisClient, err = repository.NewRedisClient(cfg.Redis)
+		if err != nil {
+			fatal(logger, "failed to connect to redis", err)
+		}
+		defer redisClient.Close()
+	}
+
+	// Защита SMTP-сервера: лимиты соединений и писем, списки IP
+	rateStore, err := ratelimit.NewStore(cfg.RateLimit, redisClient)
+	if err != nil {
+		fatal(logger, "invalid rate limit store config", err)
+	}
+	protection, err := smtpserver.NewProtection(cfg.SMTP, rateStore, appMetrics, logger)
+	if err != nil {
+		fatal(logger, "invalid smtp protection config", err)
+	}
+
+	// Проверка IP клиентов по чёрным спискам (без DNSBL_ZONES выключена)
+	blocklistDNS := cfg.DNS
+	if cfg.DNSBL.Server != "" {
+		blocklistDNS.Server = cfg.DNSBL.Server
+	}
+	blocklist, err := dnsbl.NewChecker(resolver.New(blocklistDNS), cfg.DNSBL, cfg.DNS, logger)
+	if err != nil {
+		fatal(logger, "invalid dnsbl config", err)
+	}
+
+	// Грейлистинг (GREYLIST_ENABLED)
+	var greylistStore service.GreylistStore
+	switch cfg.Greylist.Store {
+	case "storage":
+		greylistStore = store.Greylist // Основное хранилище (STORAGE_BACKEND)
+	case "redis":
+		greylistStore = repository.NewRedisGreylist(redisClient)
+	default:
+		fatal(logger, "unknown greylist store", fmt.Errorf("GREYLIST_STORE=%q", cfg.Greylist.Store))
+	}
+	greylist := smtpserver.NewGreylist(cfg.Greylist, greylistStore, logger)
 
 	// Создаём и запускаем SMTP-сервер
-	server := smtpserver.NewServer(cfg.Server, cfg.Mail, mailboxService, messageService)
+	server, err := smtpserver.NewServer(cfg.Server, cfg.Mail, cfg.SMTP, mailboxService, messageService, verifier, protection, blocklist, greylist, appMetrics, logger.With(slog.String("component", "smtp")))
+	if err != nil {
+		fatal(logger, "invalid smtp server config", err)
+	}
 
-	fmt.Printf("\nSMTP-сервер запущен на порту %d\n", cfg.Server.SMTPPort)
-	fmt.Printf("Домен: %s\n", cfg.Mail.Domain)
-	fmt.Println("Нажмите Ctrl+C для остановки")
+	// Проверки готовности (/readyz)
+	checks := health.NewRegistry(cfg.Health.Timeout)
+	store.RegisterChecks(checks)
+	if redisClient != nil {
+		checks.Register("redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+	checks.Register("cleanup", mailboxService.CheckCleanup)
+	checks.Register("smtp", server.CheckListeners)
 
-	if err := server.Start(); err != nil {
-		log.Fatal("Ошибка SMTP-сервера:", err)
+	// Метрики и проверки отдельного SMTP-сервера отдаются на METRICS_PORT
+	if cfg.Server.MetricsPort > 0 {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", appMetrics.Handler())
+			mux.Handle("/livez", checks.LiveHandler())
+			mux.Handle("/readyz", checks.ReadyHandler())
+			addr := fmt.Sprintf(":%d", cfg.Server.MetricsPort)
+			if err := http.ListenAndServe(addr, mux); err != nil {
+				logger.Error("metrics server stopped", logging.Err(err))
+			}
+		}()
 	}
+
+	// SMTP-сервер работает в отдельной горутине, пока не придёт сигнал завершения
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Start()
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+		logger.Info("shutting down")
+	case err := <-serverErr:
+		logger.Error("smtp server failed", logging.Err(err))
+		exitCode = 1
+	}
+
+	close(stopCleanup)
+	server.Close()
+
+	// Записываем события, накопленные до остановки сервера
+	close(stopStats)
+	if err := statsService.Flush(context.Background()); err != nil {
+		logger.Error("stats flush failed", logging.Err(err))
+	}
+
+	// Отправляем спаны, накопленные до остановки
+	if err := shutdownTracing(context.Background()); err != nil {
+		logger.Error("tracing shutdown failed", logging.Err(err))
+	}
+}
+
+// fatal пишет ошибку запуска в лог и завершает процесс
+func fatal(logger *slog.Logger, msg string, err error) {
+	logger.Error(msg, logging.Err(err))
+	os.Exit(1)
 }
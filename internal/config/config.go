@@ -1,6 +1,8 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -10,17 +12,60 @@ import (
 // Config — главная структура конфигурации приложения
 // Все поля заполняются из переменных окружения
 type Config struct {
-	Server   ServerConfig   // Настройки серверов
-	Database DatabaseConfig // Настройки базы данных
-	Redis    RedisConfig    // Настройки Redis
-	Mail     MailConfig     // Настройки почты
-	Limits   LimitsConfig   // Лимиты
+	Server    ServerConfig    // Настройки серверов
+	Storage   StorageConfig   // Выбор хранилища данных
+	Database  DatabaseConfig  // Настройки базы данных
+	Redis     RedisConfig     // Настройки Redis
+	RateLimit RateLimitConfig // Хранилище счётчиков ограничений частоты
+	APILimits APILimitsConfig // Ограничения частоты запросов к REST API
+	Mail      MailConfig      // Настройки почты
+	Limits    LimitsConfig    // Лимиты
+	SMTP      SMTPConfig      // Приём писем по SMTP
+	DNS       DNSConfig       // DNS-запросы при проверке писем
+	DNSBL     DNSBLConfig     // Чёрные списки IP (DNSBL)
+	Greylist  GreylistConfig  // Грейлистинг входящей почты
+	Auth      AuthConfig      // Доступ к REST API
+	Stats     StatsConfig     // Постоянная статистика (/stats)
+	Health    HealthConfig    // Проверки готовности (/readyz)
+	Log       LogConfig       // Логирование
+	Tracing   TracingConfig   // Трассировка OpenTelemetry
+}
+
+// UsesRedis сообщает, нужно ли какому-либо хранилищу подключение к Redis
+func (c *Config) UsesRedis() bool {
+	return c.RateLimit.Store == "redis" || (c.Greylist.Enabled && c.Greylist.Store == "redis")
 }
 
 // ServerConfig — настройки HTTP и SMTP серверов
 type ServerConfig struct {
 	HTTPPort int `envconfig:"HTTP_PORT" default:"8080"` // Порт HTTP сервера
 	SMTPPort int `envconfig:"SMTP_PORT" default:"2525"` // Порт SMTP сервера
+
+	// Порт отправки писем (submission) для владельцев ящиков; 0 — выключен
+	SubmissionPort int `envconfig:"SUBMISSION_PORT" default:"0"`
+
+	// Порт /metrics отдельного SMTP-сервера (cmd/smtp); 0 — выключен
+	// API-сервер отдаёт /metrics на HTTP_PORT
+	MetricsPort int `envconfig:"METRICS_PORT" default:"0"`
+
+	// Заголовок с IP клиента за обратным прокси (например, X-Forwarded-For)
+	// Доверяем ему только от адресов из HTTP_TRUSTED_PROXIES, если список задан
+	ProxyHeader    string   `envconfig:"HTTP_PROXY_HEADER"`
+	TrustedProxies []string `envconfig:"HTTP_TRUSTED_PROXIES"`
+
+	// Предельное время обработки HTTP-запроса, включая запросы к БД; 0 — без ограничения
+	RequestTimeout time.Duration `envconfig:"HTTP_REQUEST_TIMEOUT" default:"30s"`
+}
+
+// StorageConfig — где хранятся ящики, письма, ключи и статистика
+type StorageConfig struct {
+	// postgres — PostgreSQL (DB_*); sqlite — файл SQLITE_PATH, схема создаётся при запуске;
+	// memory — в памяти процесса, без внешних зависимостей:
+	// данные теряются при остановке, API и отдельный SMTP-сервер их не разделяют
+	Backend string `envconfig:"STORAGE_BACKEND" default:"postgres"`
+
+	// Файл базы данных для STORAGE_BACKEND=sqlite; создаётся, если его нет
+	SQLitePath string `envconfig:"SQLITE_PATH" default:"tempmail.db"`
 }
 
 // DatabaseConfig — настройки подключения к PostgreSQL
@@ -29,13 +74,47 @@ type DatabaseConfig struct {
 	Port     int    `envconfig:"DB_PORT" default:"5432"`      // Порт БД
 	Name     string `envconfig:"DB_NAME" default:"tempmail"`  // Имя базы данных
 	User     string `envconfig:"DB_USER" default:"postgres"`  // Пользователь БД
-	Password string `envconfig:"DB_PASSWORD" required:"true"` // Пароль БД (обязательный)
+	Password string `envconfig:"DB_PASSWORD"`                 // Пароль БД (обязателен для STORAGE_BACKEND=postgres)
+
+	// Предельное время одного запроса; медленная БД не держит SMTP-сессии и HTTP-запросы
+	// Очистке и пересчёту статистики, которые обрабатывают много строк, отводится больше
+	// 0 — без ограничения
+	QueryTimeout       time.Duration `envconfig:"DB_QUERY_TIMEOUT" default:"5s"`
+	MaintenanceTimeout time.Duration `envconfig:"DB_MAINTENANCE_TIMEOUT" default:"5m"`
+}
+
+// AuthConfig — доступ к REST API
+type AuthConfig struct {
+	// Требовать API-ключ для всех запросов к /api/v1 (иначе ключ необязателен)
+	RequireAPIKey bool `envconfig:"API_KEY_REQUIRED" default:"false"`
+
+	// Токен администратора для /api/v1/admin; пусто — админские маршруты выключены
+	AdminToken string `envconfig:"ADMIN_TOKEN"`
 }
 
 // RedisConfig — настройки подключения к Redis
 type RedisConfig struct {
-	Host string `envconfig:"REDIS_HOST" default:"localhost"` // Адрес Redis
-	Port int    `envconfig:"REDIS_PORT" default:"6379"`      // Порт Redis
+	Host     string `envconfig:"REDIS_HOST" default:"localhost"` // Адрес Redis
+	Port     int    `envconfig:"REDIS_PORT" default:"6379"`      // Порт Redis
+	Password string `envconfig:"REDIS_PASSWORD"`                 // Пароль Redis
+	DB       int    `envconfig:"REDIS_DB" default:"0"`           // Номер базы Redis
+}
+
+// RateLimitConfig — где хранить счётчики ограничений частоты
+type RateLimitConfig struct {
+	// memory — в памяти процесса, redis — общие для всех экземпляров сервиса
+	Store string `envconfig:"RATE_LIMIT_STORE" default:"memory"`
+}
+
+// APILimitsConfig — ограничения частоты запросов к REST API
+// Счётчики ведутся для каждого API-ключа, а для запросов без ключа — для IP (IPv6 — подсеть /64)
+type APILimitsConfig struct {
+	Create Rate `envconfig:"HTTP_RATE_CREATE" default:"30/1h"` // Создание ящиков
+	Read   Rate `envconfig:"HTTP_RATE_READ" default:"300/1m"`  // Чтение и изменение ящиков и писем
+	Stream Rate `envconfig:"HTTP_RATE_STREAM" default:"60/1m"` // Выгрузка исходных текстов писем
+
+	// Адреса и подсети (CIDR), запросы с которых не ограничиваются
+	AllowIPs []string `envconfig:"HTTP_RATE_ALLOW_IPS"`
 }
 
 // MailConfig — настройки почтовых ящиков
@@ -43,6 +122,30 @@ type MailConfig struct {
 	Domain     string        `envconfig:"MAIL_DOMAIN" default:"tempmail.dev"` // Домен для email
 	DefaultTTL time.Duration `envconfig:"DEFAULT_TTL" default:"1h"`           // Время жизни по умолчанию
 	MaxTTL     time.Duration `envconfig:"MAX_TTL" default:"24h"`              // Максимальное время жизни
+
+	// Абсолютный предел жизни ящика с учётом всех продлений (от момента создания)
+	MaxLifetime time.Duration `envconfig:"MAX_LIFETIME" default:"168h"`
+
+	// Льготный период после истечения срока: ящик не принимает почту,
+	// но письма можно читать, а сам ящик — восстановить
+	GracePeriod time.Duration `envconfig:"GRACE_PERIOD" default:"1h"`
+
+	// Генерация случайных адресов: random, pronounceable, words или prefix
+	AddressStyle  string `envconfig:"ADDRESS_STYLE" default:"random"`
+	AddressLength int    `envconfig:"ADDRESS_LENGTH" default:"10"` // Длина случайной части адреса
+	AddressPrefix string `envconfig:"ADDRESS_PREFIX"`              // Префикс для стиля prefix (например, "qa-")
+
+	// Политика имён ящиков
+	AddressDots       string   `envconfig:"ADDRESS_DOTS" default:"keep"` // keep — точки значимы, ignore — не значимы (a.b = ab)
+	ReservedAddresses []string `envconfig:"RESERVED_ADDRESSES" default:"postmaster,abuse,admin,administrator,hostmaster,webmaster,root,security,noreply,no-reply,mailer-daemon,support"`
+	BlockedWordsFile  string   `envconfig:"BLOCKED_WORDS_FILE"` // Файл с запрещёнными словами (по одному на строку)
+
+	// Как часто деактивировать истёкшие ящики и удалять ящики после льготного периода
+	CleanupInterval time.Duration `envconfig:"CLEANUP_INTERVAL" default:"5m"`
+
+	// Символы-разделители подадреса: box+tag@domain попадает в ящик box@domain
+	// Пустая строка отключает подадресацию
+	SubaddressSeparators string `envconfig:"SUBADDRESS_SEPARATORS" default:"+"`
 }
 
 // LimitsConfig — лимиты и ограничения
@@ -52,6 +155,107 @@ type LimitsConfig struct {
 	MaxMessagesPerMailbox int `envconfig:"MAX_MESSAGES_PER_MAILBOX" default:"100"` // Макс. писем в ящике
 }
 
+// SMTPConfig — правила приёма писем SMTP-сервером
+type SMTPConfig struct {
+	// Проверять SPF, DKIM и DMARC входящих писем
+	AuthCheck bool `envconfig:"SMTP_AUTH_CHECK" default:"true"`
+
+	// Списки адресов и подсетей (CIDR): разрешённые не ограничиваются, запрещённые отклоняются сразу
+	AllowIPs []string `envconfig:"SMTP_ALLOW_IPS"`
+	DenyIPs  []string `envconfig:"SMTP_DENY_IPS"`
+
+	// Ограничения для одного IP (IPv6 — для подсети /64)
+	MaxConnsPerIP int  `envconfig:"SMTP_MAX_CONNS_PER_IP" default:"10"`   // Одновременных соединений
+	ConnRate      Rate `envconfig:"SMTP_CONN_RATE" default:"60/1m"`       // Новых соединений
+	MessageRate   Rate `envconfig:"SMTP_MESSAGE_RATE" default:"100/1h"`   // Писем (команд MAIL FROM)
+	RecipientRate Rate `envconfig:"SMTP_RECIPIENT_RATE" default:"200/1h"` // Писем в один ящик
+
+	// Задержка ответа после каждой ошибки клиента; растёт с числом ошибок
+	TarpitDelay time.Duration `envconfig:"SMTP_TARPIT_DELAY" default:"1s"`
+
+	// Пауза перед приветствием: клиента, заговорившего раньше, отключаем
+	// Задерживает каждое входящее соединение, поэтому включается явно (например, 2s)
+	GreetDelay time.Duration `envconfig:"SMTP_GREET_DELAY" default:"0"`
+
+	// Сертификат и ключ в PEM: включают STARTTLS на обоих портах
+	TLSCert string `envconfig:"SMTP_TLS_CERT"`
+	TLSKey  string `envconfig:"SMTP_TLS_KEY"`
+
+	// Разрешить вход на submission-порт без TLS (только для разработки)
+	AllowInsecureAuth bool `envconfig:"SUBMISSION_ALLOW_INSECURE_AUTH" default:"false"`
+}
+
+// DNSConfig — настройки DNS-резолвера
+type DNSConfig struct {
+	Server  string        `envconfig:"DNS_SERVER"`               // host:port DNS-сервера (пусто — системный)
+	Timeout time.Duration `envconfig:"DNS_TIMEOUT" default:"5s"` // Таймаут одной проверки
+}
+
+// DNSBLConfig — проверка IP клиента по чёрным спискам
+type DNSBLConfig struct {
+	// Зоны через запятую: zone[:reject|spam|score=N], без действия — reject
+	// Например: zen.spamhaus.org:reject,bl.spamcop.net:score=2
+	Zones []string `envconfig:"DNSBL_ZONES"`
+
+	// Пороги по сумме очков зон score (0 — порог выключен)
+	SpamScore   int `envconfig:"DNSBL_SPAM_SCORE" default:"2"`
+	RejectScore int `envconfig:"DNSBL_REJECT_SCORE" default:"5"`
+
+	// Отдельный DNS-сервер для запросов к спискам (пусто — DNS_SERVER)
+	// Spamhaus не отвечает на запросы через публичные резолверы
+	Server string `envconfig:"DNSBL_DNS_SERVER"`
+
+	CacheTTL time.Duration `envconfig:"DNSBL_CACHE_TTL" default:"15m"` // Время хранения результатов
+}
+
+// GreylistConfig — грейлистинг: первая попытка доставки от незнакомого
+// отправителя получает временный отказ, повтор после задержки принимается
+type GreylistConfig struct {
+	Enabled bool     `envconfig:"GREYLIST_ENABLED" default:"false"` // Включить грейлистинг
+	Domains []string `envconfig:"GREYLIST_DOMAINS"`                 // Домены с грейлистингом (пусто — все наши)
+	Store   string   `envconfig:"GREYLIST_STORE" default:"storage"` // Где хранить триплеты: storage (STORAGE_BACKEND) или redis
+
+	Delay        time.Duration `envconfig:"GREYLIST_DELAY" default:"5m"`           // Сколько ждать до повтора
+	RetryWindow  time.Duration `envconfig:"GREYLIST_RETRY_WINDOW" default:"24h"`   // Сколько ждать повтора
+	PassTTL      time.Duration `envconfig:"GREYLIST_PASS_TTL" default:"720h"`      // Сколько помнить прошедший триплет
+	WhitelistTTL time.Duration `envconfig:"GREYLIST_WHITELIST_TTL" default:"720h"` // Сколько помнить сеть после доставки
+}
+
+// StatsConfig — постоянная статистика в PostgreSQL
+type StatsConfig struct {
+	// Как часто записывать накопленные счётчики в БД
+	FlushInterval time.Duration `envconfig:"STATS_FLUSH_INTERVAL" default:"10s"`
+
+	// Как часто собирать суточные итоги и удалять старые часовые значения
+	RollupInterval time.Duration `envconfig:"STATS_ROLLUP_INTERVAL" default:"10m"`
+
+	// Сколько хранить часовые значения (не меньше 48h); дальше — только суточные
+	HourlyRetention time.Duration `envconfig:"STATS_HOURLY_RETENTION" default:"720h"`
+
+	// Наибольшее число точек временного ряда в одном ответе
+	MaxPoints int `envconfig:"STATS_MAX_POINTS" default:"1000"`
+}
+
+// HealthConfig — проверки готовности (/readyz)
+type HealthConfig struct {
+	// Время на все проверки одного запроса; не уложившиеся считаются неудачными
+	Timeout time.Duration `envconfig:"HEALTH_TIMEOUT" default:"2s"`
+}
+
+// LogConfig — логирование
+type LogConfig struct {
+	Level  string `envconfig:"LOG_LEVEL" default:"info"`  // debug, info, warn, error
+	Format string `envconfig:"LOG_FORMAT" default:"text"` // text или json
+}
+
+// TracingConfig — трассировка OpenTelemetry (экспорт по OTLP/HTTP)
+type TracingConfig struct {
+	Enabled     bool    `envconfig:"TRACING_ENABLED" default:"false"`           // Отправлять трассы
+	Endpoint    string  `envconfig:"TRACING_ENDPOINT" default:"localhost:4318"` // host:port OTLP/HTTP коллектора
+	Insecure    bool    `envconfig:"TRACING_INSECURE" default:"true"`           // Без TLS (локальный коллектор)
+	SampleRatio float64 `envconfig:"TRACING_SAMPLE_RATIO" default:"1"`          // Доля записываемых трасс
+}
+
 // Load загружает конфигурацию из переменных окружения
 // Сначала пытается прочитать файл .env, затем читает переменные окружения
 func Load() (*Config, error) {
@@ -69,6 +273,20 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
+	// Без пароля можно работать только с хранилищем в памяти
+	if cfg.Storage.Backend == "postgres" && cfg.Database.Password == "" {
+		return nil, errors.New("required key DB_PASSWORD missing value")
+	}
+
+	// GREYLIST_STORE=postgres — устаревшее имя для storage; без PostgreSQL оно
+	// молча уводило бы триплеты в другое хранилище, поэтому такое сочетание отклоняем
+	if cfg.Greylist.Store == "postgres" {
+		if cfg.Storage.Backend != "postgres" {
+			return nil, fmt.Errorf("GREYLIST_STORE=postgres requires STORAGE_BACKEND=postgres (got %q), use GREYLIST_STORE=storage", cfg.Storage.Backend)
+		}
+		cfg.Greylist.Store = "storage"
+	}
+
 	// Возвращаем указатель на конфигурацию
 	return &cfg, nil
 }
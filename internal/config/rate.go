@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rate — ограничение частоты событий в формате "N/окно", например "100/1h"
+// Значения "", "0" и "off" выключают ограничение
+type Rate struct {
+	Limit  int           // Сколько событий разрешено за окно
+	Window time.Duration // Длина окна
+}
+
+// Decode разбирает значение переменной окружения (интерфейс envconfig.Decoder)
+func (r *Rate) Decode(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" || value == "off" {
+		*r = Rate{}
+		return nil
+	}
+
+	count, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return fmt.Errorf("ожидается формат N/окно, например 100/1h: %q", value)
+	}
+
+	limit, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || limit < 0 {
+		return fmt.Errorf("некорректное число событий: %q", value)
+	}
+
+	// Допускаем сокращение "100/h" вместо "100/1h"
+	window = strings.TrimSpace(window)
+	d, err := time.ParseDuration(window)
+	if err != nil {
+		d, err = time.ParseDuration("1" + window)
+	}
+	if err != nil || d <= 0 {
+		return fmt.Errorf("некорректная длина окна: %q", value)
+	}
+
+	*r = Rate{Limit: limit, Window: d}
+	return nil
+}
+
+// Enabled сообщает, включено ли ограничение
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Window > 0
+}
+
+// String возвращает ограничение в том же формате, в котором оно задаётся
+func (r Rate) String() string {
+	if !r.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", r.Limit, r.Window)
+}
@@ -0,0 +1,265 @@
+// Package dnsbl проверяет IP-адреса клиентов по чёрным спискам (DNSBL/RBL)
+//
+// Адрес 192.0.2.1 проверяется запросом A-записи 1.2.0.192.<зона>: ответ из
+// 127.0.0.0/8 означает, что адрес в списке, NXDOMAIN — что его там нет.
+package dnsbl
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/logging"
+	"tempmail/internal/resolver"
+)
+
+// Listing — попадание адреса в один список
+type Listing struct {
+	Zone   Zone     // Список, в котором найден адрес
+	Codes  []string // Коды ответа (127.0.0.x) — причина по классификации списка
+	Reason string   // Пояснение из TXT-записи, если список его даёт
+}
+
+// Result — итог проверки адреса по всем спискам
+type Result struct {
+	Listings []Listing // Списки, в которых найден адрес
+	Score    int       // Сумма очков
+	Reject   bool      // Соединение нужно отклонить
+	Spam     bool      // Письма нужно пометить как спам
+}
+
+// Listed сообщает, найден ли адрес хотя бы в одном списке
+func (r *Result) Listed() bool {
+	return r != nil && len(r.Listings) > 0
+}
+
+// Rejection возвращает список, по которому отклоняется соединение, для текста ответа
+func (r *Result) Rejection() *Listing {
+	for i := range r.Listings {
+		if r.Listings[i].Zone.Action == ActionReject {
+			return &r.Listings[i]
+		}
+	}
+	if len(r.Listings) > 0 {
+		return &r.Listings[0]
+	}
+	return nil
+}
+
+// cacheSweepInterval — как часто удалять устаревшие записи кэша
+const cacheSweepInterval = time.Minute
+
+// cacheEntry — закэшированный результат проверки
+type cacheEntry struct {
+	result  *Result
+	expires time.Time
+}
+
+// Checker проверяет адреса по настроенным спискам
+type Checker struct {
+	resolver    resolver.Resolver
+	dns         config.DNSConfig
+	zones       []Zone
+	spamScore   int
+	rejectScore int
+	cacheTTL    time.Duration
+	logger      *slog.Logger
+
+	mu        sync.Mutex
+	cache     map[string]cacheEntry
+	lastSweep time.Time
+}
+
+// NewChecker создаёт проверку по спискам из конфигурации
+// Если ни одной зоны не задано, возвращает nil — проверка выключена
+// Ошибки DNS пишутся в логгер из контекста проверки, а если его нет — в logger
+func NewChecker(r resolver.Resolver, cfg config.DNSBLConfig, dns config.DNSConfig, logger *slog.Logger) (*Checker, error) {
+	zones := make([]Zone, 0, len(cfg.Zones))
+	for _, item := range cfg.Zones {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		zone, err := ParseZone(item)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, zone)
+	}
+	if len(zones) == 0 {
+		return nil, nil
+	}
+
+	return &Checker{
+		resolver:    r,
+		dns:         dns,
+		zones:       zones,
+		spamScore:   cfg.SpamScore,
+		rejectScore: cfg.RejectScore,
+		cacheTTL:    cfg.CacheTTL,
+		logger:      logging.OrDiscard(logger),
+		cache:       make(map[string]cacheEntry),
+		lastSweep:   time.Now(),
+	}, nil
+}
+
+// Check проверяет адрес по всем спискам параллельно
+// Ошибки DNS не считаются попаданием: лучше принять письмо, чем отказать по сбою
+func (c *Checker) Check(ctx context.Context, ip net.IP) *Result {
+	query := reverseIP(ip)
+	if query == "" {
+		return &Result{}
+	}
+
+	if result, ok := c.cached(query); ok {
+		return result
+	}
+
+	ctx, cancel := resolver.WithTimeout(ctx, c.dns)
+	defer cancel()
+
+	listings := make([]*Listing, len(c.zones))
+	var wg sync.WaitGroup
+	for i, zone := range c.zones {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listings[i] = c.lookup(ctx, query, zone)
+		}()
+	}
+	wg.Wait()
+
+	result := &Result{}
+	for _, listing := range listings {
+		if listing == nil {
+			continue
+		}
+		result.Listings = append(result.Listings, *listing)
+
+		switch listing.Zone.Action {
+		case ActionReject:
+			result.Reject = true
+		case ActionSpam:
+			result.Spam = true
+		case ActionScore:
+			result.Score += listing.Zone.Score
+		}
+	}
+	if c.rejectScore > 0 && result.Score >= c.rejectScore {
+		result.Reject = true
+	}
+	if c.spamScore > 0 && result.Score >= c.spamScore {
+		result.Spam = true
+	}
+
+	c.store(query, result)
+	return result
+}
+
+// lookup проверяет адрес по одному списку; nil — адреса в списке нет
+func (c *Checker) lookup(ctx context.Context, query string, zone Zone) *Listing {
+	name := query + "." + zone.Name
+	addrs, err := c.resolver.LookupHost(ctx, name)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+			logging.FromContext(ctx, c.logger).Warn("dnsbl lookup failed",
+				slog.String("zone", zone.Name),
+				logging.Err(err),
+			)
+		}
+		return nil
+	}
+
+	var codes []string
+	for _, addr := range addrs {
+		if listedCode(addr) {
+			codes = append(codes, addr)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	listing := &Listing{Zone: zone, Codes: codes}
+
+	// Пояснение нужно только для текста отказа
+	if zone.Action == ActionReject {
+		if txt, err := c.resolver.LookupTXT(ctx, name); err == nil && len(txt) > 0 {
+			listing.Reason = txt[0]
+		}
+	}
+	return listing
+}
+
+// listedCode проверяет, что ответ означает попадание в список
+// 127.255.255.0/24 — служебные коды Spamhaus (например, запрос через
+// публичный резолвер заблокирован), это не попадание
+func listedCode(addr string) bool {
+	ip := net.ParseIP(addr).To4()
+	if ip == nil || ip[0] != 127 {
+		return false
+	}
+	return !(ip[1] == 255 && ip[2] == 255)
+}
+
+// reverseIP переворачивает адрес для запроса к DNSBL:
+// 192.0.2.1 → 1.2.0.192, IPv6 — по полубайтам (RFC 5782)
+func reverseIP(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return net.IPv4(ip4[3], ip4[2], ip4[1], ip4[0]).String()
+	}
+
+	ip16 := ip.To16()
+	if ip16 == nil {
+		return ""
+	}
+	digits := hex.EncodeToString(ip16)
+	labels := make([]string, len(digits))
+	for i := range digits {
+		labels[len(digits)-1-i] = digits[i : i+1]
+	}
+	return strings.Join(labels, ".")
+}
+
+// cached возвращает результат из кэша
+func (c *Checker) cached(query string) (*Result, bool) {
+	if c.cacheTTL <= 0 {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.cache[query]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.result, true
+}
+
+// store сохраняет результат в кэш и заодно удаляет устаревшие записи
+func (c *Checker) store(query string, result *Result) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) >= cacheSweepInterval {
+		c.lastSweep = now
+		for key, entry := range c.cache {
+			if now.After(entry.expires) {
+				delete(c.cache, key)
+			}
+		}
+	}
+	c.cache[query] = cacheEntry{result: result, expires: now.Add(c.cacheTTL)}
+}
@@ -0,0 +1,272 @@
+package dnsbl
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+)
+
+// stubResolver отвечает на запросы к спискам из заранее заданных записей
+// Имена без записей дают NXDOMAIN, имена из errs — заданную ошибку
+// Запросы к зоне slowZone зависают до отмены контекста
+type stubResolver struct {
+	hosts    map[string][]string
+	txt      map[string][]string
+	errs     map[string]error
+	slowZone string
+	queries  atomic.Int32
+}
+
+func (r *stubResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.queries.Add(1)
+	if r.slowZone != "" && strings.HasSuffix(host, "."+r.slowZone) {
+		<-ctx.Done()
+		return nil, &net.DNSError{Err: ctx.Err().Error(), Name: host, IsTimeout: true}
+	}
+	if err, ok := r.errs[host]; ok {
+		return nil, err
+	}
+	if addrs, ok := r.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	if txt, ok := r.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func (r *stubResolver) LookupMX(context.Context, string) ([]*net.MX, error) {
+	return nil, nil
+}
+
+func (r *stubResolver) LookupIPAddr(context.Context, string) ([]net.IPAddr, error) {
+	return nil, nil
+}
+
+func (r *stubResolver) LookupAddr(context.Context, string) ([]string, error) {
+	return nil, nil
+}
+
+func newTestChecker(t *testing.T, r *stubResolver, cfg config.DNSBLConfig, timeout time.Duration) *Checker {
+	t.Helper()
+	c, err := NewChecker(r, cfg, config.DNSConfig{Timeout: timeout}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReverseIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"192.0.2.1", "1.2.0.192"},
+		{"::ffff:192.0.2.1", "1.2.0.192"}, // IPv4 в IPv6-записи проверяется как IPv4
+		{"2001:db8::1", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2"},
+		{"2001:DB8:abcd:12::ff00", "0.0.f.f.0.0.0.0.0.0.0.0.0.0.0.0.2.1.0.0.d.c.b.a.8.b.d.0.1.0.0.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := reverseIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("reverseIP(%s) = %q, want %q", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if got := reverseIP(nil); got != "" {
+		t.Errorf("reverseIP(nil) = %q, want empty", got)
+	}
+}
+
+func TestNewCheckerWithoutZones(t *testing.T) {
+	c, err := NewChecker(&stubResolver{}, config.DNSBLConfig{Zones: []string{" "}}, config.DNSConfig{}, nil)
+	if err != nil || c != nil {
+		t.Fatalf("NewChecker = %v, %v; want nil, nil", c, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	const query = "1.2.0.192"
+
+	tests := []struct {
+		name       string
+		zones      []string
+		resolver   *stubResolver
+		wantZones  []string
+		wantScore  int
+		wantReject bool
+		wantSpam   bool
+		wantReason string
+	}{
+		{
+			name:     "not listed",
+			zones:    []string{"bl.example"},
+			resolver: &stubResolver{},
+		},
+		{
+			name:  "reject zone with reason",
+			zones: []string{"bl.example:reject"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".bl.example": {"127.0.0.2"}},
+				txt:   map[string][]string{query + ".bl.example": {"Listed, see https://bl.example/lookup"}},
+			},
+			wantZones:  []string{"bl.example"},
+			wantReject: true,
+			wantReason: "Listed, see https://bl.example/lookup",
+		},
+		{
+			name:  "spam zone",
+			zones: []string{"spam.example:spam"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".spam.example": {"127.0.0.4"}},
+			},
+			wantZones: []string{"spam.example"},
+			wantSpam:  true,
+		},
+		{
+			name:  "score below thresholds",
+			zones: []string{"a.example:score=1", "b.example:score=3"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".a.example": {"127.0.0.2"}},
+			},
+			wantZones: []string{"a.example"},
+			wantScore: 1,
+		},
+		{
+			name:  "scores add up to spam",
+			zones: []string{"a.example:score=1", "b.example:score=2"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{
+					query + ".a.example": {"127.0.0.2"},
+					query + ".b.example": {"127.0.0.3"},
+				},
+			},
+			wantZones: []string{"a.example", "b.example"},
+			wantScore: 3,
+			wantSpam:  true,
+		},
+		{
+			name:  "scores add up to reject",
+			zones: []string{"a.example:score=3", "b.example:score=3"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{
+					query + ".a.example": {"127.0.0.2"},
+					query + ".b.example": {"127.0.0.2"},
+				},
+			},
+			wantZones:  []string{"a.example", "b.example"},
+			wantScore:  6,
+			wantReject: true,
+			wantSpam:   true,
+		},
+		{
+			name:  "service code is not a listing",
+			zones: []string{"zen.example"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".zen.example": {"127.255.255.254"}},
+			},
+		},
+		{
+			name:  "answer outside 127/8 is not a listing",
+			zones: []string{"bl.example"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".bl.example": {"198.51.100.7"}},
+			},
+		},
+		{
+			name:  "dns failure is not a listing",
+			zones: []string{"bl.example", "ok.example:spam"},
+			resolver: &stubResolver{
+				hosts: map[string][]string{query + ".ok.example": {"127.0.0.2"}},
+				errs: map[string]error{
+					query + ".bl.example": &net.DNSError{Err: "server misbehaving", IsTemporary: true},
+				},
+			},
+			wantZones: []string{"ok.example"},
+			wantSpam:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DNSBLConfig{Zones: tt.zones, SpamScore: 2, RejectScore: 5}
+			result := newTestChecker(t, tt.resolver, cfg, time.Second).Check(context.Background(), net.ParseIP("192.0.2.1"))
+
+			var zones []string
+			for _, l := range result.Listings {
+				zones = append(zones, l.Zone.Name)
+			}
+			if strings.Join(zones, ",") != strings.Join(tt.wantZones, ",") {
+				t.Errorf("listed in %v, want %v", zones, tt.wantZones)
+			}
+			if result.Score != tt.wantScore || result.Reject != tt.wantReject || result.Spam != tt.wantSpam {
+				t.Errorf("score=%d reject=%v spam=%v, want score=%d reject=%v spam=%v",
+					result.Score, result.Reject, result.Spam, tt.wantScore, tt.wantReject, tt.wantSpam)
+			}
+			if tt.wantReason != "" {
+				if rejection := result.Rejection(); rejection == nil || rejection.Reason != tt.wantReason {
+					t.Errorf("Rejection() = %+v, want reason %q", rejection, tt.wantReason)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckIPv6(t *testing.T) {
+	r := &stubResolver{hosts: map[string][]string{
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.v6.example": {"127.0.0.2"},
+	}}
+	c := newTestChecker(t, r, config.DNSBLConfig{Zones: []string{"v6.example"}}, time.Second)
+
+	if result := c.Check(context.Background(), net.ParseIP("2001:db8::1")); !result.Reject {
+		t.Errorf("2001:db8::1 not rejected: %+v", result)
+	}
+	if result := c.Check(context.Background(), net.ParseIP("2001:db8::2")); result.Listed() {
+		t.Errorf("2001:db8::2 listed: %+v", result)
+	}
+}
+
+// TestCheckTimeout проверяет, что зависший список не задерживает проверку
+// дольше DNS_TIMEOUT, а ответы остальных списков учитываются
+func TestCheckTimeout(t *testing.T) {
+	r := &stubResolver{
+		hosts:    map[string][]string{"1.2.0.192.fast.example": {"127.0.0.2"}},
+		slowZone: "slow.example",
+	}
+	c := newTestChecker(t, r, config.DNSBLConfig{Zones: []string{"slow.example", "fast.example:spam"}}, 50*time.Millisecond)
+
+	began := time.Now()
+	result := c.Check(context.Background(), net.ParseIP("192.0.2.1"))
+	if took := time.Since(began); took > time.Second {
+		t.Fatalf("Check took %v, want about the 50ms DNS timeout", took)
+	}
+	if result.Reject || !result.Spam || len(result.Listings) != 1 {
+		t.Errorf("result = %+v, want only the spam listing from fast.example", result)
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	r := &stubResolver{hosts: map[string][]string{"1.2.0.192.bl.example": {"127.0.0.2"}}}
+	cfg := config.DNSBLConfig{Zones: []string{"bl.example:spam"}, CacheTTL: time.Minute}
+	c := newTestChecker(t, r, cfg, time.Second)
+
+	first := c.Check(context.Background(), net.ParseIP("192.0.2.1"))
+	second := c.Check(context.Background(), net.ParseIP("192.0.2.1"))
+	if first != second {
+		t.Error("second check did not come from the cache")
+	}
+	if n := r.queries.Load(); n != 1 {
+		t.Errorf("resolver queried %d times, want 1", n)
+	}
+}
@@ -0,0 +1,49 @@
+package dnsbl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Action — что делать с клиентом, найденным в списке
+type Action string
+
+const (
+	ActionReject Action = "reject" // Отклонить соединение
+	ActionSpam   Action = "spam"   // Принять письма, но пометить как спам
+	ActionScore  Action = "score"  // Добавить очки; решение по сумме очков
+)
+
+// Zone — один чёрный список
+type Zone struct {
+	Name   string // Домен зоны, например zen.spamhaus.org
+	Action Action // Действие при попадании в список
+	Score  int    // Очки для действия score
+}
+
+// ParseZone разбирает описание зоны: "zone", "zone:reject", "zone:spam" или "zone:score=N"
+// Без действия зона отклоняет соединение
+func ParseZone(s string) (Zone, error) {
+	name, action, _ := strings.Cut(strings.TrimSpace(s), ":")
+	name = strings.Trim(strings.ToLower(strings.TrimSpace(name)), ".")
+	if name == "" {
+		return Zone{}, fmt.Errorf("пустое имя зоны DNSBL: %q", s)
+	}
+
+	zone := Zone{Name: name, Action: ActionReject}
+	switch action = strings.ToLower(strings.TrimSpace(action)); {
+	case action == "" || action == string(ActionReject):
+	case action == string(ActionSpam):
+		zone.Action = ActionSpam
+	case strings.HasPrefix(action, string(ActionScore)+"="):
+		score, err := strconv.Atoi(strings.TrimPrefix(action, string(ActionScore)+"="))
+		if err != nil || score <= 0 {
+			return Zone{}, fmt.Errorf("некорректные очки зоны DNSBL: %q", s)
+		}
+		zone.Action, zone.Score = ActionScore, score
+	default:
+		return Zone{}, fmt.Errorf("неизвестное действие зоны DNSBL: %q", s)
+	}
+	return zone, nil
+}
@@ -0,0 +1,38 @@
+package domain
+
+import "time"
+
+// MailboxSearch — условия поиска ящиков по всему сервису (админка)
+// Пустые поля не ограничивают выборку
+type MailboxSearch struct {
+	IDs      []string // Только ящики с этими ID
+	Query    string   // Подстрока адреса (без учёта регистра)
+	Active   *bool    // Только активные или только неактивные
+	APIKeyID string   // Только ящики, созданные ключом
+	Limit    int      // Размер страницы
+	Offset   int      // Сколько записей пропустить
+}
+
+// IsEmpty сообщает, что условия не ограничивают выборку
+// Массовые операции с пустыми условиями затронули бы все ящики
+func (s MailboxSearch) IsEmpty() bool {
+	return len(s.IDs) == 0 && s.Query == "" && s.Active == nil && s.APIKeyID == ""
+}
+
+// MessageSearch — условия поиска писем по всему сервису (админка)
+// Пустые поля не ограничивают выборку
+type MessageSearch struct {
+	MailboxID   string    // Только письма ящика
+	From        string    // Подстрока адреса отправителя (без учёта регистра)
+	Spam        *bool     // Только спам или только не спам
+	Quarantined *bool     // Только письма в карантине или вне его
+	Since       time.Time // Полученные не раньше
+	Limit       int       // Размер страницы
+	Offset      int       // Сколько записей пропустить
+}
+
+// AddressCount — адрес и число писем с ним (отчёты по отправителям и получателям)
+type AddressCount struct {
+	Address string `json:"address"`
+	Count   int    `json:"count"`
+}
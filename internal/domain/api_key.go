@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"time"
+)
+
+// APIKey — ключ доступа к REST API для одного клиента (команды)
+// Квота 0 означает отсутствие ограничения
+type APIKey struct {
+	ID                  string     `json:"id"`                     // Уникальный идентификатор (UUID)
+	Name                string     `json:"name"`                   // Название (команда, сервис)
+	Prefix              string     `json:"prefix"`                 // Начало ключа, чтобы узнать его в списке
+	MaxMailboxesPerHour int        `json:"max_mailboxes_per_hour"` // Ящиков в час
+	MaxActiveMailboxes  int        `json:"max_active_mailboxes"`   // Активных ящиков одновременно
+	IsActive            bool       `json:"is_active"`              // Отозванный ключ не принимается
+	CreatedAt           time.Time  `json:"created_at"`             // Дата создания
+	LastUsedAt          *time.Time `json:"last_used_at,omitempty"` // Последний запрос с ключом
+
+	// Счётчики использования
+	Requests         int64 `json:"requests"`          // Запросов с ключом
+	MailboxesCreated int64 `json:"mailboxes_created"` // Создано ящиков
+
+	// В БД хранится только SHA-256 ключа; сам ключ известен лишь при создании
+	KeyHash string `json:"-"`
+	Key     string `json:"-"`
+}
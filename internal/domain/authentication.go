@@ -0,0 +1,41 @@
+package domain
+
+// Результаты проверок (RFC 8601): pass, fail, softfail, neutral, none, temperror, permerror
+const (
+	AuthPass      = "pass"
+	AuthFail      = "fail"
+	AuthNone      = "none"
+	AuthTempError = "temperror"
+	AuthPermError = "permerror"
+)
+
+// Authentication — результаты проверки подлинности входящего письма
+type Authentication struct {
+	SPF   SPFResult    `json:"spf"`   // Проверка IP отправителя по SPF-записи
+	DKIM  []DKIMResult `json:"dkim"`  // По одному результату на каждую DKIM-подпись
+	DMARC DMARCResult  `json:"dmarc"` // Итог DMARC с учётом выравнивания доменов
+}
+
+// SPFResult — результат проверки SPF
+type SPFResult struct {
+	Result string `json:"result"` // pass, fail, softfail, neutral, none, temperror, permerror
+	Domain string `json:"domain"` // Проверенный домен (из MAIL FROM или HELO)
+	IP     string `json:"ip"`     // IP-адрес подключившегося клиента
+}
+
+// DKIMResult — результат проверки одной DKIM-подписи
+type DKIMResult struct {
+	Result     string `json:"result"`               // pass, fail, temperror, permerror
+	Domain     string `json:"domain"`               // Домен подписи (тег d=)
+	Identifier string `json:"identifier,omitempty"` // Идентификатор подписанта (тег i=)
+	Error      string `json:"error,omitempty"`      // Причина неудачи
+}
+
+// DMARCResult — результат проверки DMARC
+type DMARCResult struct {
+	Result      string `json:"result"`           // pass, fail, none, temperror, permerror
+	Domain      string `json:"domain"`           // Домен из заголовка From
+	Policy      string `json:"policy,omitempty"` // Опубликованная политика: none, quarantine, reject
+	SPFAligned  bool   `json:"spf_aligned"`      // SPF прошёл для домена, выровненного с From
+	DKIMAligned bool   `json:"dkim_aligned"`     // Есть валидная подпись домена, выровненного с From
+}
@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+)
+
+// Envelope — SMTP-конверт и параметры соединения, с которыми пришло письмо
+// В отличие от заголовков письма, эти данные сообщает не отправитель, а сам сервер
+type Envelope struct {
+	MessageID    string    `json:"-"`                     // ID письма
+	QueueID      string    `json:"queue_id"`              // ID письма на нашем сервере (из заголовка Received)
+	RemoteIP     string    `json:"remote_ip"`             // IP-адрес клиента
+	Helo         string    `json:"helo"`                  // Имя из HELO/EHLO
+	TLS          bool      `json:"tls"`                   // Письмо передано по TLS (STARTTLS)
+	TLSVersion   string    `json:"tls_version,omitempty"` // Версия TLS (например, "TLS 1.3")
+	TLSCipher    string    `json:"tls_cipher,omitempty"`  // Набор шифров
+	MailFrom     string    `json:"mail_from"`             // Отправитель из MAIL FROM (пусто — null sender <>)
+	RcptTo       string    `json:"rcpt_to"`               // Получатель из RCPT TO
+	BodyType     string    `json:"body_type,omitempty"`   // Параметр BODY=: 7BIT, 8BITMIME или BINARYMIME
+	SMTPUTF8     bool      `json:"smtputf8"`              // Клиент передал параметр SMTPUTF8
+	DeclaredSize int64     `json:"declared_size"`         // Размер из параметра SIZE= (0 — не указан)
+	Size         int64     `json:"size"`                  // Фактический размер письма в байтах
+	ReceivedAt   time.Time `json:"received_at"`           // Момент приёма письма сервером
+}
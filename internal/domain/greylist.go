@@ -0,0 +1,8 @@
+package domain
+
+// GreylistTriplet — ключ грейлистинга: откуда, от кого и кому пришло письмо
+type GreylistTriplet struct {
+	Network   string // Сеть клиента: /24 для IPv4, /64 для IPv6
+	Sender    string // Адрес из MAIL FROM ("<>" — null sender)
+	Recipient string // Адрес из RCPT TO
+}
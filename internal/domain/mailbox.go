@@ -1,17 +1,28 @@
 package domain
 
 import (
+	"strings"
 	"time"
 )
 
 // Mailbox — почтовый ящик
 // Каждый ящик имеет уникальный адрес и время жизни
 type Mailbox struct {
-	ID        string    `json:"id"`         // Уникальный идентификатор (UUID)
-	Address   string    `json:"address"`    // Email адрес (например, abc123@tempmail.dev)
-	CreatedAt time.Time `json:"created_at"` // Дата создания
-	ExpiresAt time.Time `json:"expires_at"` // Дата истечения срока
-	IsActive  bool      `json:"is_active"`  // Активен ли ящик
+	ID         string    `json:"id"`          // Уникальный идентификатор (UUID)
+	Address    string    `json:"address"`     // Email адрес (например, abc123@tempmail.dev) или шаблон (test-*@tempmail.dev)
+	CreatedAt  time.Time `json:"created_at"`  // Дата создания
+	ExpiresAt  time.Time `json:"expires_at"`  // Дата истечения срока
+	IsActive   bool      `json:"is_active"`   // Активен ли ящик (false — деактивирован после истечения срока)
+	IsWildcard bool      `json:"is_wildcard"` // Адрес — шаблон со звёздочкой (catch-all)
+
+	MaxExpiresAt time.Time     `json:"max_expires_at"`       // Дальше этой даты срок продлить нельзя
+	AutoExtend   time.Duration `json:"auto_extend"`          // Продление при активности (0 — выключено)
+	APIKeyID     string        `json:"api_key_id,omitempty"` // Ключ, создавший ящик (пусто — создан анонимно)
+
+	// Токен ящика для входа на submission-порт: в БД хранится только SHA-256,
+	// сам токен известен лишь при создании ящика
+	TokenHash string `json:"-"`
+	Token     string `json:"-"`
 }
 
 // IsExpired проверяет, истёк ли срок действия ящика
@@ -20,3 +31,75 @@ func (m *Mailbox) IsExpired() bool {
 	// After проверяет, наступило ли время ExpiresAt
 	return time.Now().After(m.ExpiresAt)
 }
+
+// AcceptsMail проверяет, может ли ящик сейчас принимать письма
+func (m *Mailbox) AcceptsMail() bool {
+	return m.IsActive && !m.IsExpired()
+}
+
+// IsGone проверяет, закончился ли льготный период после истечения срока
+// Такой ящик нельзя ни читать, ни восстановить — он ждёт удаления
+func (m *Mailbox) IsGone(grace time.Duration) bool {
+	return time.Now().After(m.ExpiresAt.Add(grace))
+}
+
+// ActivityExpiry вычисляет новый срок действия после активности в ящике
+// (получение письма, чтение списка писем)
+// Второе значение false, если автопродление выключено или срок не меняется
+func (m *Mailbox) ActivityExpiry(now time.Time) (time.Time, bool) {
+	if m.AutoExtend <= 0 {
+		return m.ExpiresAt, false
+	}
+
+	next := now.Add(m.AutoExtend)
+	if next.After(m.MaxExpiresAt) {
+		next = m.MaxExpiresAt
+	}
+
+	return next, next.After(m.ExpiresAt)
+}
+
+// Matches проверяет, подходит ли адрес под шаблон ящика
+// Звёздочка совпадает с любой (в том числе пустой) последовательностью символов
+// Для обычного ящика требуется точное совпадение адреса
+func (m *Mailbox) Matches(address string) bool {
+	if !m.IsWildcard {
+		return strings.EqualFold(m.Address, address)
+	}
+	return matchWildcard(strings.ToLower(m.Address), strings.ToLower(address))
+}
+
+// Specificity возвращает «точность» шаблона — число символов кроме звёздочек
+// Из нескольких подходящих шаблонов выбирается самый точный
+func (m *Mailbox) Specificity() int {
+	return len(m.Address) - strings.Count(m.Address, "*")
+}
+
+// matchWildcard сопоставляет строку с шаблоном, где '*' — любая подстрока
+func matchWildcard(pattern, s string) bool {
+	// Части шаблона между звёздочками должны идти в строке по порядку
+	parts := strings.Split(pattern, "*")
+
+	// Первая часть — обязательный префикс
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+
+	// Последняя часть — обязательный суффикс
+	last := parts[len(parts)-1]
+	if len(parts) == 1 {
+		return s == ""
+	}
+
+	// Средние части ищем жадно слева направо
+	for _, part := range parts[1 : len(parts)-1] {
+		idx := strings.Index(s, part)
+		if idx < 0 {
+			return false
+		}
+		s = s[idx+len(part):]
+	}
+
+	return strings.HasSuffix(s, last)
+}
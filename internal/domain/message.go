@@ -6,15 +6,36 @@ import (
 
 // Message — входящее письмо
 type Message struct {
-	ID          string    `json:"id"`           // Уникальный идентификатор
-	MailboxID   string    `json:"mailbox_id"`   // ID почтового ящика
-	FromAddress string    `json:"from_address"` // Адрес отправителя
-	Subject     string    `json:"subject"`      // Тема письма
-	BodyText    string    `json:"body_text"`    // Текстовое содержимое
-	BodyHTML    string    `json:"body_html"`    // HTML содержимое
-	ReceivedAt  time.Time `json:"received_at"`  // Дата получения
-	IsRead      bool      `json:"is_read"`      // Прочитано ли
-	IsSpam      bool      `json:"is_spam"`      // Помечено как спам
+	ID          string    `json:"id"`            // Уникальный идентификатор
+	MailboxID   string    `json:"mailbox_id"`    // ID почтового ящика
+	FromAddress string    `json:"from_address"`  // Адрес отправителя
+	Recipient   string    `json:"recipient"`     // Исходный адрес получателя из RCPT TO
+	Subject     string    `json:"subject"`       // Тема письма
+	BodyText    string    `json:"body_text"`     // Текстовое содержимое
+	BodyHTML    string    `json:"body_html"`     // HTML содержимое
+	Tag         string    `json:"tag,omitempty"` // Тег из подадреса (box+tag@domain)
+	ReceivedAt  time.Time `json:"received_at"`   // Дата получения
+	IsRead      bool      `json:"is_read"`       // Прочитано ли
+	IsSpam      bool      `json:"is_spam"`       // Помечено как спам
+
+	// В карантине: письмо скрыто от владельца ящика, его видит только администратор
+	IsQuarantined bool `json:"is_quarantined"`
+
+	// Результаты SPF/DKIM/DMARC; nil, если проверка не выполнялась
+	Authentication *Authentication `json:"authentication,omitempty"`
+
+	// SMTP-конверт; nil у писем, сохранённых до появления конвертов
+	Envelope *Envelope `json:"envelope,omitempty"`
+
+	// Исходный текст письма (RFC 5322) с нашим заголовком Received
+	// Загружается отдельно, в обычных выборках не заполняется
+	RawSource []byte `json:"-"`
+}
+
+// MessageFilter — условия выборки писем ящика
+// Пустые поля не ограничивают выборку
+type MessageFilter struct {
+	Tag string // Только письма с указанным тегом
 }
 
 // Attachment — вложение к письму
@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// Имена постоянных счётчиков статистики (/stats)
+const (
+	StatMailboxesCreated    = "mailboxes_created"    // Создано ящиков
+	StatMailboxesExpired    = "mailboxes_expired"    // Деактивировано очисткой
+	StatMailboxesDeleted    = "mailboxes_deleted"    // Удалено очисткой
+	StatMailboxesRemoved    = "mailboxes_removed"    // Удалено владельцами и администратором
+	StatMessagesStored      = "messages_stored"      // Сохранено писем
+	StatSpamMessages        = "spam_messages"        // Из них спама
+	StatMessagesDeleted     = "messages_deleted"     // Удалено владельцами
+	StatSMTPSessions        = "smtp_sessions"        // SMTP-сессий
+	StatConnectionsRejected = "connections_rejected" // Отклонено соединений
+	StatEarlyTalkers        = "early_talkers"        // Заговоривших до приветствия
+	StatRecipientsAccepted  = "recipients_accepted"  // Принято получателей
+	StatRecipientsRejected  = "recipients_rejected"  // Отклонено получателей
+	StatRateLimited         = "rate_limited"         // Отказов по лимитам писем
+	StatGreylisted          = "greylisted"           // Отложено грейлистингом
+	StatParseFailures       = "parse_failures"       // Писем, которые не удалось разобрать
+)
+
+// StatNames — все постоянные счётчики в порядке вывода
+var StatNames = []string{
+	StatMailboxesCreated,
+	StatMailboxesExpired,
+	StatMailboxesDeleted,
+	StatMailboxesRemoved,
+	StatMessagesStored,
+	StatSpamMessages,
+	StatMessagesDeleted,
+	StatSMTPSessions,
+	StatConnectionsRejected,
+	StatEarlyTalkers,
+	StatRecipientsAccepted,
+	StatRecipientsRejected,
+	StatRateLimited,
+	StatGreylisted,
+	StatParseFailures,
+}
+
+// StatValue — значение счётчика за интервал, начинающийся в Time
+type StatValue struct {
+	Time  time.Time
+	Name  string
+	Value int64
+}
+
+// StatPoint — значения всех счётчиков за один интервал временного ряда
+type StatPoint struct {
+	Time   time.Time        `json:"time"`
+	Values map[string]int64 `json:"values"`
+}
@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/service"
+)
+
+// AdminHandler — обработчик запросов администратора
+type AdminHandler struct {
+	service *service.AdminService
+}
+
+// NewAdminHandler создаёт новый обработчик
+func NewAdminHandler(svc *service.AdminService) *AdminHandler {
+	return &AdminHandler{service: svc}
+}
+
+// MailboxPageResponse — страница ящиков с общим числом найденных
+type MailboxPageResponse struct {
+	Total int               `json:"total"`
+	Items []MailboxResponse `json:"items"`
+}
+
+// AdminMessageResponse — краткая информация о письме для администратора
+type AdminMessageResponse struct {
+	ID            string `json:"id"`
+	MailboxID     string `json:"mailbox_id"`
+	FromAddress   string `json:"from_address"`
+	Recipient     string `json:"recipient"`
+	Subject       string `json:"subject"`
+	ReceivedAt    string `json:"received_at"`
+	IsRead        bool   `json:"is_read"`
+	IsSpam        bool   `json:"is_spam"`
+	IsQuarantined bool   `json:"is_quarantined"`
+}
+
+// MessagePageResponse — страница писем с общим числом найденных
+type MessagePageResponse struct {
+	Total int                    `json:"total"`
+	Items []AdminMessageResponse `json:"items"`
+}
+
+// ExpireRequest — условия выбора ящиков для досрочного истечения
+// Нужно хотя бы одно условие; несколько условий объединяются через «и»
+type ExpireRequest struct {
+	IDs      []string `json:"ids"`        // ID ящиков
+	Query    string   `json:"query"`      // Подстрока адреса
+	APIKeyID string   `json:"api_key_id"` // Ящики, созданные ключом
+}
+
+// ExpireResponse — результат досрочного истечения
+type ExpireResponse struct {
+	Expired int64 `json:"expired"` // Сколько ящиков переведено в льготный период
+}
+
+// queryBool разбирает необязательный логический параметр строки запроса
+func queryBool(c *fiber.Ctx, name string) (*bool, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, errors.New(name + ": ожидается true или false")
+	}
+	return &value, nil
+}
+
+// badQuery отвечает на неверный параметр строки запроса
+func badQuery(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+		Error:   "Неверные параметры запроса",
+		Details: err.Error(),
+	})
+}
+
+// SearchMailboxes ищет ящики по всему сервису
+// @Summary Поиск ящиков
+// @Description Возвращает ящики всех клиентов, новые первыми, с общим числом найденных
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param q query string false "Подстрока адреса"
+// @Param active query bool false "Только активные (true) или неактивные (false)"
+// @Param api_key_id query string false "Только ящики ключа"
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 500)"
+// @Param offset query int false "Сколько записей пропустить"
+// @Success 200 {object} MailboxPageResponse "Страница ящиков"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/mailboxes [get]
+func (h *AdminHandler) SearchMailboxes(c *fiber.Ctx) error {
+	active, err := queryBool(c, "active")
+	if err != nil {
+		return badQuery(c, err)
+	}
+
+	mailboxes, total, err := h.service.SearchMailboxes(c.UserContext(), domain.MailboxSearch{
+		Query:    c.Query("q"),
+		Active:   active,
+		APIKeyID: c.Query("api_key_id"),
+		Limit:    c.QueryInt("limit"),
+		Offset:   c.QueryInt("offset"),
+	})
+	if err != nil {
+		return internalError(c, err)
+	}
+
+	response := MailboxPageResponse{Total: total, Items: make([]MailboxResponse, len(mailboxes))}
+	for i, mailbox := range mailboxes {
+		response.Items[i] = newMailboxResponse(mailbox)
+	}
+	return c.JSON(response)
+}
+
+// DeleteMailbox удаляет любой ящик
+// @Summary Принудительно удалить ящик
+// @Description Удаляет ящик с письмами независимо от владельца и срока действия
+// @Tags admin
+// @Security AdminToken
+// @Param id path string true "ID почтового ящика"
+// @Success 204 "Ящик удалён"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Ящик не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/mailboxes/{id} [delete]
+func (h *AdminHandler) DeleteMailbox(c *fiber.Ctx) error {
+	if err := h.service.DeleteMailbox(c.UserContext(), c.Params("id")); err != nil {
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		return internalError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// ExpireMailboxes досрочно завершает срок ящиков
+// @Summary Массовое истечение ящиков
+// @Description Переводит подходящие активные ящики в льготный период: почта больше не принимается, письма удаляются после GRACE_PERIOD
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body ExpireRequest true "Условия выбора ящиков"
+// @Success 200 {object} ExpireResponse "Число ящиков"
+// @Failure 400 {object} ErrorResponse "Не заданы условия"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/mailboxes/expire [post]
+func (h *AdminHandler) ExpireMailboxes(c *fiber.Ctx) error {
+	var req ExpireRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	expired, err := h.service.ExpireMailboxes(c.UserContext(), domain.MailboxSearch{
+		IDs:      req.IDs,
+		Query:    req.Query,
+		APIKeyID: req.APIKeyID,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrEmptyFilter) {
+			return badQuery(c, err)
+		}
+		return internalError(c, err)
+	}
+	return c.JSON(ExpireResponse{Expired: expired})
+}
+
+// SearchMessages ищет письма по всему сервису
+// @Summary Поиск писем
+// @Description Возвращает письма всех ящиков, новые первыми, включая письма в карантине
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param mailbox_id query string false "Только письма ящика"
+// @Param from query string false "Подстрока адреса отправителя"
+// @Param spam query bool false "Только спам (true) или только не спам (false)"
+// @Param quarantined query bool false "Только письма в карантине (true) или вне его (false)"
+// @Param since query string false "Полученные не раньше (RFC 3339)"
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 500)"
+// @Param offset query int false "Сколько записей пропустить"
+// @Success 200 {object} MessagePageResponse "Страница писем"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/messages [get]
+func (h *AdminHandler) SearchMessages(c *fiber.Ctx) error {
+	spam, err := queryBool(c, "spam")
+	if err != nil {
+		return badQuery(c, err)
+	}
+	return h.searchMessages(c, spam)
+}
+
+// SpamMessages возвращает письма, помеченные как спам
+// @Summary Спам
+// @Description Возвращает письма, помеченные как спам, новые первыми. Принимает те же параметры, что и /admin/messages.
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param limit query int false "Размер страницы (по умолчанию 50, не больше 500)"
+// @Param offset query int false "Сколько записей пропустить"
+// @Success 200 {object} MessagePageResponse "Страница писем"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/messages/spam [get]
+func (h *AdminHandler) SpamMessages(c *fiber.Ctx) error {
+	spam := true
+	return h.searchMessages(c, &spam)
+}
+
+// searchMessages ищет письма по параметрам строки запроса
+func (h *AdminHandler) searchMessages(c *fiber.Ctx, spam *bool) error {
+	quarantined, err := queryBool(c, "quarantined")
+	if err != nil {
+		return badQuery(c, err)
+	}
+
+	var since time.Time
+	if raw := c.Query("since"); raw != "" {
+		since, err = time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return badQuery(c, errors.New("since: ожидается время в формате RFC 3339"))
+		}
+	}
+
+	messages, total, err := h.service.SearchMessages(c.UserContext(), domain.MessageSearch{
+		MailboxID:   c.Query("mailbox_id"),
+		From:        c.Query("from"),
+		Spam:        spam,
+		Quarantined: quarantined,
+		Since:       since,
+		Limit:       c.QueryInt("limit"),
+		Offset:      c.QueryInt("offset"),
+	})
+	if err != nil {
+		return internalError(c, err)
+	}
+
+	response := MessagePageResponse{Total: total, Items: make([]AdminMessageResponse, len(messages))}
+	for i, msg := range messages {
+		response.Items[i] = AdminMessageResponse{
+			ID:            msg.ID,
+			MailboxID:     msg.MailboxID,
+			FromAddress:   msg.FromAddress,
+			Recipient:     msg.Recipient,
+			Subject:       msg.Subject,
+			ReceivedAt:    msg.ReceivedAt.Format(time.RFC3339),
+			IsRead:        msg.IsRead,
+			IsSpam:        msg.IsSpam,
+			IsQuarantined: msg.IsQuarantined,
+		}
+	}
+	return c.JSON(response)
+}
+
+// Quarantine помещает письмо в карантин
+// @Summary Поместить письмо в карантин
+// @Description Скрывает письмо от владельца ящика; администратор по-прежнему видит его в /admin/messages
+// @Tags admin
+// @Security AdminToken
+// @Param id path string true "ID письма"
+// @Success 204 "Письмо в карантине"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Письмо не найдено"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/messages/{id}/quarantine [post]
+func (h *AdminHandler) Quarantine(c *fiber.Ctx) error {
+	return h.setQuarantined(c, true)
+}
+
+// Release возвращает письмо из карантина
+// @Summary Вернуть письмо из карантина
+// @Description Снова показывает письмо владельцу ящика
+// @Tags admin
+// @Security AdminToken
+// @Param id path string true "ID письма"
+// @Success 204 "Письмо возвращено"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Письмо не найдено"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/messages/{id}/quarantine [delete]
+func (h *AdminHandler) Release(c *fiber.Ctx) error {
+	return h.setQuarantined(c, false)
+}
+
+// setQuarantined меняет карантин письма из параметра id
+func (h *AdminHandler) setQuarantined(c *fiber.Ctx, quarantined bool) error {
+	if err := h.service.SetQuarantined(c.UserContext(), c.Params("id"), quarantined); err != nil {
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Письмо не найдено",
+			})
+		}
+		return internalError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// TopSenders возвращает отчёт по отправителям
+// @Summary Самые активные отправители
+// @Description Адреса отправителей (заголовок From) с наибольшим числом писем за период
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param period query string false "Период (по умолчанию 24h)"
+// @Param limit query int false "Размер отчёта (по умолчанию 10, не больше 100)"
+// @Success 200 {array} domain.AddressCount "Отчёт"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/reports/senders [get]
+func (h *AdminHandler) TopSenders(c *fiber.Ctx) error {
+	return h.report(c, h.service.TopSenders)
+}
+
+// TopRecipients возвращает отчёт по получателям
+// @Summary Самые активные получатели
+// @Description Адреса получателей (RCPT TO) с наибольшим числом писем за период
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param period query string false "Период (по умолчанию 24h)"
+// @Param limit query int false "Размер отчёта (по умолчанию 10, не больше 100)"
+// @Success 200 {array} domain.AddressCount "Отчёт"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/reports/recipients [get]
+func (h *AdminHandler) TopRecipients(c *fiber.Ctx) error {
+	return h.report(c, h.service.TopRecipients)
+}
+
+// report строит отчёт по адресам с параметрами period и limit
+func (h *AdminHandler) report(c *fiber.Ctx, build func(context.Context, time.Duration, int) ([]domain.AddressCount, error)) error {
+	period, err := parseDuration(c.Query("period"))
+	if err != nil || period < 0 {
+		return badQuery(c, errors.New("period: ожидается длительность, например 24h"))
+	}
+
+	counts, err := build(c.UserContext(), period, c.QueryInt("limit"))
+	if err != nil {
+		return internalError(c, err)
+	}
+	if counts == nil {
+		counts = []domain.AddressCount{}
+	}
+	return c.JSON(counts)
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/service"
+)
+
+// APIKeyHandler — обработчик запросов для API-ключей
+type APIKeyHandler struct {
+	service *service.APIKeyService
+}
+
+// NewAPIKeyHandler создаёт новый обработчик
+func NewAPIKeyHandler(svc *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: svc}
+}
+
+// APIKeyRequest — запрос на создание или изменение ключа
+// Не переданные поля при изменении остаются прежними
+type APIKeyRequest struct {
+	Name                *string `json:"name"`                   // Название (команда, сервис)
+	MaxMailboxesPerHour *int    `json:"max_mailboxes_per_hour"` // Ящиков в час (0 — без ограничения)
+	MaxActiveMailboxes  *int    `json:"max_active_mailboxes"`   // Активных ящиков (0 — без ограничения)
+	IsActive            *bool   `json:"is_active"`              // false — ключ отозван
+}
+
+// APIKeyResponse — данные ключа со счётчиками использования
+type APIKeyResponse struct {
+	ID                  string `json:"id"`
+	Name                string `json:"name"`
+	Prefix              string `json:"prefix"`                 // Начало ключа
+	MaxMailboxesPerHour int    `json:"max_mailboxes_per_hour"` // 0 — без ограничения
+	MaxActiveMailboxes  int    `json:"max_active_mailboxes"`   // 0 — без ограничения
+	IsActive            bool   `json:"is_active"`
+	CreatedAt           string `json:"created_at"`
+	LastUsedAt          string `json:"last_used_at,omitempty"`
+	Requests            int64  `json:"requests"`          // Запросов с ключом
+	MailboxesCreated    int64  `json:"mailboxes_created"` // Создано ящиков
+	Key                 string `json:"key,omitempty"`     // Сам ключ; возвращается только при создании
+}
+
+// newAPIKeyResponse преобразует ключ в формат ответа API
+func newAPIKeyResponse(key *domain.APIKey) APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:                  key.ID,
+		Name:                key.Name,
+		Prefix:              key.Prefix,
+		MaxMailboxesPerHour: key.MaxMailboxesPerHour,
+		MaxActiveMailboxes:  key.MaxActiveMailboxes,
+		IsActive:            key.IsActive,
+		CreatedAt:           key.CreatedAt.Format(time.RFC3339),
+		Requests:            key.Requests,
+		MailboxesCreated:    key.MailboxesCreated,
+		Key:                 key.Key,
+	}
+	if key.LastUsedAt != nil {
+		resp.LastUsedAt = key.LastUsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+// options преобразует запрос в параметры сервиса
+func (r APIKeyRequest) options() service.APIKeyOptions {
+	return service.APIKeyOptions{
+		Name:                r.Name,
+		MaxMailboxesPerHour: r.MaxMailboxesPerHour,
+		MaxActiveMailboxes:  r.MaxActiveMailboxes,
+		IsActive:            r.IsActive,
+	}
+}
+
+// keyError отвечает на ошибку сервиса ключей
+func keyError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, service.ErrAPIKeyNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+			Error: "API-ключ не найден",
+		})
+	}
+	if errors.Is(err, service.ErrEmptyKeyName) || errors.Is(err, service.ErrInvalidQuota) {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error:   "Неверные параметры ключа",
+			Details: err.Error(),
+		})
+	}
+	return internalError(c, err)
+}
+
+// Create создаёт API-ключ
+// @Summary Создать API-ключ
+// @Description Создаёт ключ клиента с квотами. Сам ключ возвращается только в этом ответе.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param request body APIKeyRequest true "Название и квоты"
+// @Success 201 {object} APIKeyResponse "Ключ создан"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/keys [post]
+func (h *APIKeyHandler) Create(c *fiber.Ctx) error {
+	var req APIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	key, err := h.service.Create(c.UserContext(), req.options())
+	if err != nil {
+		return keyError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(newAPIKeyResponse(key))
+}
+
+// List возвращает все API-ключи
+// @Summary Список API-ключей
+// @Description Возвращает все ключи с квотами и счётчиками использования
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Success 200 {array} APIKeyResponse "Список ключей"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/keys [get]
+func (h *APIKeyHandler) List(c *fiber.Ctx) error {
+	keys, err := h.service.List(c.UserContext())
+	if err != nil {
+		return keyError(c, err)
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i, key := range keys {
+		response[i] = newAPIKeyResponse(key)
+	}
+	return c.JSON(response)
+}
+
+// Get возвращает API-ключ
+// @Summary Получить API-ключ
+// @Description Возвращает ключ с квотами и счётчиками использования
+// @Tags admin
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "ID ключа"
+// @Success 200 {object} APIKeyResponse "Ключ"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Ключ не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/keys/{id} [get]
+func (h *APIKeyHandler) Get(c *fiber.Ctx) error {
+	key, err := h.service.GetByID(c.UserContext(), c.Params("id"))
+	if err != nil {
+		return keyError(c, err)
+	}
+	return c.JSON(newAPIKeyResponse(key))
+}
+
+// Update меняет API-ключ
+// @Summary Изменить API-ключ
+// @Description Меняет название, квоты или активность ключа (is_active=false отзывает ключ)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security AdminToken
+// @Param id path string true "ID ключа"
+// @Param request body APIKeyRequest true "Изменяемые поля"
+// @Success 200 {object} APIKeyResponse "Ключ изменён"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Ключ не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/keys/{id} [patch]
+func (h *APIKeyHandler) Update(c *fiber.Ctx) error {
+	var req APIKeyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат запроса",
+		})
+	}
+
+	key, err := h.service.Update(c.UserContext(), c.Params("id"), req.options())
+	if err != nil {
+		return keyError(c, err)
+	}
+	return c.JSON(newAPIKeyResponse(key))
+}
+
+// Delete удаляет API-ключ
+// @Summary Удалить API-ключ
+// @Description Удаляет ключ. Созданные им ящики остаются, но становятся анонимными.
+// @Tags admin
+// @Security AdminToken
+// @Param id path string true "ID ключа"
+// @Success 204 "Ключ удалён"
+// @Failure 401 {object} ErrorResponse "Неверный токен администратора"
+// @Failure 404 {object} ErrorResponse "Ключ не найден"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /admin/keys/{id} [delete]
+func (h *APIKeyHandler) Delete(c *fiber.Ctx) error {
+	if err := h.service.Delete(c.UserContext(), c.Params("id")); err != nil {
+		return keyError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// Me возвращает ключ, с которым выполнен запрос
+// @Summary Мой API-ключ
+// @Description Возвращает квоты и счётчики использования ключа, с которым выполнен запрос
+// @Tags keys
+// @Produce json
+// @Security APIKey
+// @Success 200 {object} APIKeyResponse "Ключ"
+// @Failure 401 {object} ErrorResponse "Требуется API-ключ"
+// @Router /keys/me [get]
+func (h *APIKeyHandler) Me(c *fiber.Ctx) error {
+	return c.JSON(newAPIKeyResponse(apiKeyFrom(c)))
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/service"
+)
+
+// localsAPIKey — ключ в c.Locals, под которым лежит ключ клиента
+const localsAPIKey = "apiKey"
+
+// AuthMiddleware — проверка API-ключей и токена администратора
+type AuthMiddleware struct {
+	keys      *service.APIKeyService
+	mailboxes *service.MailboxService
+	cfg       config.AuthConfig
+}
+
+// NewAuthMiddleware создаёт middleware авторизации
+func NewAuthMiddleware(keys *service.APIKeyService, mailboxes *service.MailboxService, cfg config.AuthConfig) *AuthMiddleware {
+	return &AuthMiddleware{keys: keys, mailboxes: mailboxes, cfg: cfg}
+}
+
+// requestToken читает ключ из заголовка X-API-Key или Authorization: Bearer
+func requestToken(c *fiber.Ctx) string {
+	if key := c.Get("X-API-Key"); key != "" {
+		return strings.TrimSpace(key)
+	}
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
+
+// apiKeyFrom возвращает ключ клиента текущего запроса (nil — анонимный запрос)
+func apiKeyFrom(c *fiber.Ctx) *domain.APIKey {
+	key, _ := c.Locals(localsAPIKey).(*domain.APIKey)
+	return key
+}
+
+// APIKey проверяет ключ клиента, если он передан, и сохраняет его в c.Locals
+// Без ключа запрос проходит анонимно, если API_KEY_REQUIRED выключен
+func (m *AuthMiddleware) APIKey(c *fiber.Ctx) error {
+	// Группа /mailbox по префиксу совпадает и с /mailboxes: ключ уже проверен
+	if apiKeyFrom(c) != nil {
+		return c.Next()
+	}
+
+	token := requestToken(c)
+	if token == "" {
+		if m.cfg.RequireAPIKey {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+				Error: "Требуется API-ключ (заголовок X-API-Key или Authorization: Bearer)",
+			})
+		}
+		return c.Next()
+	}
+
+	key, err := m.keys.Authenticate(c.UserContext(), token)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+				Error: "Неверный или отозванный API-ключ",
+			})
+		}
+		return internalError(c, err)
+	}
+
+	c.Locals(localsAPIKey, key)
+	return c.Next()
+}
+
+// RequireAPIKey пропускает только запросы с ключом
+// Ставится после APIKey на маршруты, которые без ключа не имеют смысла
+func (m *AuthMiddleware) RequireAPIKey(c *fiber.Ctx) error {
+	if apiKeyFrom(c) == nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+			Error: "Требуется API-ключ (заголовок X-API-Key или Authorization: Bearer)",
+		})
+	}
+	return c.Next()
+}
+
+// MailboxOwner не даёт работать с ящиком, созданным другим ключом
+// Для чужого ящика отвечаем 404, чтобы не раскрывать его существование
+func (m *AuthMiddleware) MailboxOwner(c *fiber.Ctx) error {
+	err := m.mailboxes.CheckAccess(c.UserContext(), c.Params("id"), apiKeyFrom(c))
+	if err != nil {
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		return internalError(c, err)
+	}
+	return c.Next()
+}
+
+// Admin пропускает только запросы с токеном администратора
+func (m *AuthMiddleware) Admin(c *fiber.Ctx) error {
+	// Без ADMIN_TOKEN админские маршруты выключены
+	if m.cfg.AdminToken == "" {
+		return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+			Error: "Не найдено",
+		})
+	}
+
+	token := requestToken(c)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(m.cfg.AdminToken)) != 1 {
+		return c.Status(fiber.StatusUnauthorized).JSON(ErrorResponse{
+			Error: "Неверный токен администратора",
+		})
+	}
+	return c.Next()
+}
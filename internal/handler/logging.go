@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
+
+	"tempmail/internal/logging"
+)
+
+// RequestLogger выдаёт каждому запросу логгер с request_id и пишет строку журнала доступа
+// ID берётся из заголовка X-Request-ID (его ставит requestid.New) и возвращается клиенту
+// Логгер запроса доступен обработчикам через requestLogger и сервисам через контекст
+func RequestLogger(base *slog.Logger) fiber.Handler {
+	base = logging.OrDiscard(base)
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		logger := base.With(slog.String("request_id", requestID(c)))
+		// trace_id связывает строки лога с трассой запроса
+		if id := traceID(c); id != "" {
+			logger = logger.With(slog.String("trace_id", id))
+		}
+		c.SetUserContext(logging.WithContext(c.UserContext(), logger))
+
+		err := c.Next()
+
+		// Строку запроса не пишем: в ней могут быть токены
+		status := responseStatus(c, err)
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logger.Log(c.UserContext(), level, "http request",
+			slog.String("method", c.Method()),
+			slog.String("route", c.Route().Path),
+			slog.String("path", c.Path()),
+			slog.Int("status", status),
+			slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+			slog.String("ip", c.IP()),
+		)
+		return err
+	}
+}
+
+// requestID возвращает ID запроса, выданный requestid.New
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestid.ConfigDefault.ContextKey).(string)
+	return id
+}
+
+// requestLogger возвращает логгер текущего запроса
+func requestLogger(c *fiber.Ctx) *slog.Logger {
+	return logging.FromContext(c.UserContext(), nil)
+}
+
+// responseStatus возвращает код ответа
+// Ошибку превратит в ответ обработчик ошибок Fiber уже после middleware
+func responseStatus(c *fiber.Ctx, err error) int {
+	if err == nil {
+		return c.Response().StatusCode()
+	}
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+	return fiber.StatusInternalServerError
+}
+
+// internalError записывает непредвиденную ошибку в лог и отвечает 500
+// Клиент получает только request_id, по которому ошибку можно найти в логах
+// Если запрос не уложился в срок (HTTP_REQUEST_TIMEOUT, DB_QUERY_TIMEOUT), отвечает 503:
+// повтор запроса позже может пройти
+func internalError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		requestLogger(c).Warn("request timed out", logging.Err(err))
+		return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
+			Error:   "Сервис временно не успевает ответить, повторите запрос позже",
+			Details: "request_id: " + requestID(c),
+		})
+	}
+	requestLogger(c).Error("request failed", logging.Err(err))
+	return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
+		Error:   "Внутренняя ошибка сервера",
+		Details: "request_id: " + requestID(c),
+	})
+}
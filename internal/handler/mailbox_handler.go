@@ -6,6 +6,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
+	"tempmail/internal/domain"
 	"tempmail/internal/service"
 )
 
@@ -21,29 +22,79 @@ func NewMailboxHandler(svc *service.MailboxService) *MailboxHandler {
 
 // CreateRequest — структура запроса на создание ящика
 type CreateRequest struct {
-	Address string `json:"address"` // Желаемый адрес (необязательно)
+	Address string `json:"address"` // Желаемый адрес или шаблон со звёздочкой (необязательно)
 	TTL     string `json:"ttl"`     // Время жизни (например, "1h", "30m")
+
+	// Продление при активности: каждое письмо или чтение списка писем
+	// сдвигает срок действия на это время вперёд (например, "30m")
+	AutoExtend string `json:"auto_extend"`
+}
+
+// ExtendRequest — структура запроса на продление ящика
+type ExtendRequest struct {
+	TTL string `json:"ttl"` // На сколько продлить (например, "1h"); по умолчанию DEFAULT_TTL
+}
+
+// RestoreRequest — структура запроса на восстановление истёкшего ящика
+type RestoreRequest struct {
+	TTL string `json:"ttl"` // Новое время жизни от текущего момента; по умолчанию DEFAULT_TTL
 }
 
 // MailboxResponse — структура ответа с данными ящика
 type MailboxResponse struct {
-	ID        string `json:"id"`
-	Address   string `json:"address"`
-	CreatedAt string `json:"created_at"`
-	ExpiresAt string `json:"expires_at"`
-	IsActive  bool   `json:"is_active"`
+	ID           string `json:"id"`
+	Address      string `json:"address"`
+	CreatedAt    string `json:"created_at"`
+	ExpiresAt    string `json:"expires_at"`
+	IsActive     bool   `json:"is_active"`
+	IsWildcard   bool   `json:"is_wildcard"`
+	MaxExpiresAt string `json:"max_expires_at"`        // Предел продления срока
+	AutoExtend   string `json:"auto_extend,omitempty"` // Шаг автопродления (например, "30m0s")
+	Token        string `json:"token,omitempty"`       // Токен для submission-порта; возвращается только при создании
+	APIKeyID     string `json:"api_key_id,omitempty"`  // Ключ, с которым создан ящик
+}
+
+// newMailboxResponse преобразует ящик в формат ответа API
+func newMailboxResponse(mailbox *domain.Mailbox) MailboxResponse {
+	resp := MailboxResponse{
+		ID:           mailbox.ID,
+		Address:      mailbox.Address,
+		CreatedAt:    mailbox.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:    mailbox.ExpiresAt.Format(time.RFC3339),
+		IsActive:     mailbox.IsActive,
+		IsWildcard:   mailbox.IsWildcard,
+		MaxExpiresAt: mailbox.MaxExpiresAt.Format(time.RFC3339),
+		APIKeyID:     mailbox.APIKeyID,
+	}
+	if mailbox.AutoExtend > 0 {
+		resp.AutoExtend = mailbox.AutoExtend.String()
+	}
+	// Токен известен только у только что созданного ящика
+	resp.Token = mailbox.Token
+	return resp
+}
+
+// parseDuration разбирает длительность из запроса; пустая строка — ноль
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
 }
 
 // Create создаёт новый почтовый ящик
 // @Summary Создать почтовый ящик
-// @Description Создаёт новый временный почтовый ящик. Если адрес не указан, генерируется случайный.
+// @Description Создаёт новый временный почтовый ящик. Если адрес не указан, генерируется случайный. Адрес со звёздочкой (например, "test-*" или "*-qa@tempmail.dev") создаёт ящик-шаблон, принимающий письма для всех подходящих адресов (кроме служебных); в шаблоне должна быть хотя бы одна буква или цифра; ящик с точным адресом имеет приоритет над шаблоном. Имя проверяется по RFC 5321 (до 64 символов, без пробелов и лишних точек), приводится к нижнему регистру; служебные (postmaster, abuse, admin, ...) и запрещённые имена отклоняются.
 // @Tags mailbox
 // @Accept json
 // @Produce json
 // @Param request body CreateRequest false "Параметры создания (необязательно)"
 // @Success 201 {object} MailboxResponse "Ящик успешно создан"
 // @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 409 {object} ErrorResponse "Адрес уже занят"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Failure 429 {object} ErrorResponse "Превышена квота API-ключа"
+// @Failure 503 {object} ErrorResponse "Не удалось подобрать свободный адрес"
 // @Router /mailbox [post]
 func (h *MailboxHandler) Create(c *fiber.Ctx) error {
 	// Парсим тело запроса
@@ -56,58 +107,109 @@ func (h *MailboxHandler) Create(c *fiber.Ctx) error {
 	}
 
 	// Парсим TTL
-	var ttl time.Duration
-	if req.TTL != "" {
-		var err error
-		ttl, err = time.ParseDuration(req.TTL)
-		if err != nil {
-			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-				Error: "Неверный формат TTL. Используйте формат: 1h, 30m, 24h",
-			})
-		}
+	ttl, err := parseDuration(req.TTL)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат TTL. Используйте формат: 1h, 30m, 24h",
+		})
+	}
+
+	autoExtend, err := parseDuration(req.AutoExtend)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат auto_extend. Используйте формат: 30m, 1h",
+		})
 	}
 
 	// Создаём ящик
-	mailbox, err := h.service.Create(req.Address, ttl)
+	mailbox, err := h.service.Create(c.UserContext(), service.CreateOptions{
+		Address:    req.Address,
+		TTL:        ttl,
+		AutoExtend: autoExtend,
+		APIKey:     apiKeyFrom(c),
+	})
 	if err != nil {
 		// Проверяем тип ошибки
 		if errors.Is(err, service.ErrInvalidTTL) {
 			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
-				Error: "TTL превышает максимально допустимое значение",
+				Error: "TTL или auto_extend превышает максимально допустимое значение",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
-		})
+		if errors.Is(err, service.ErrForeignDomain) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: "Домен адреса не обслуживается этим сервером",
+			})
+		}
+		if errors.Is(err, service.ErrInvalidAddress) || errors.Is(err, service.ErrReservedAddress) {
+			// Причина отказа — в тексте ошибки политики адресов
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error:   "Недопустимый адрес",
+				Details: err.Error(),
+			})
+		}
+		if errors.Is(err, service.ErrAddressTaken) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Error: "Адрес уже занят",
+			})
+		}
+		if errors.Is(err, service.ErrAddressSpace) {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(ErrorResponse{
+				Error: "Не удалось подобрать свободный адрес, повторите попытку",
+			})
+		}
+		if errors.Is(err, service.ErrHourlyQuota) || errors.Is(err, service.ErrActiveQuota) {
+			return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResponse{
+				Error:   "Превышена квота API-ключа",
+				Details: err.Error(),
+			})
+		}
+		return internalError(c, err)
 	}
 
 	// Возвращаем успешный ответ
 	// Status(201) — код "Created" (создано)
-	return c.Status(fiber.StatusCreated).JSON(MailboxResponse{
-		ID:        mailbox.ID,
-		Address:   mailbox.Address,
-		CreatedAt: mailbox.CreatedAt.Format(time.RFC3339),
-		ExpiresAt: mailbox.ExpiresAt.Format(time.RFC3339),
-		IsActive:  mailbox.IsActive,
-	})
+	return c.Status(fiber.StatusCreated).JSON(newMailboxResponse(mailbox))
+}
+
+// List возвращает ящики, созданные ключом клиента
+// @Summary Мои ящики
+// @Description Возвращает ящики, созданные с API-ключом запроса, новые первыми
+// @Tags mailbox
+// @Produce json
+// @Security APIKey
+// @Success 200 {array} MailboxResponse "Список ящиков"
+// @Failure 401 {object} ErrorResponse "Требуется API-ключ"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /mailboxes [get]
+func (h *MailboxHandler) List(c *fiber.Ctx) error {
+	mailboxes, err := h.service.ListByAPIKey(c.UserContext(), apiKeyFrom(c).ID)
+	if err != nil {
+		return internalError(c, err)
+	}
+
+	response := make([]MailboxResponse, len(mailboxes))
+	for i, mailbox := range mailboxes {
+		response[i] = newMailboxResponse(mailbox)
+	}
+	return c.JSON(response)
 }
 
 // Get возвращает информацию о ящике
 // @Summary Получить информацию о ящике
-// @Description Возвращает информацию о почтовом ящике по его ID
+// @Description Возвращает информацию о почтовом ящике по его ID. В льготный период после истечения срока ящик возвращается с is_active=false.
 // @Tags mailbox
 // @Produce json
 // @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
 // @Success 200 {object} MailboxResponse "Информация о ящике"
 // @Failure 404 {object} ErrorResponse "Ящик не найден"
-// @Failure 410 {object} ErrorResponse "Срок действия ящика истёк"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /mailbox/{id} [get]
 func (h *MailboxHandler) Get(c *fiber.Ctx) error {
 	// Params получает параметр из URL
 	id := c.Params("id")
 
-	mailbox, err := h.service.GetByID(id)
+	mailbox, err := h.service.GetByID(c.UserContext(), id)
 	if err != nil {
 		if errors.Is(err, service.ErrMailboxNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
@@ -119,18 +221,132 @@ func (h *MailboxHandler) Get(c *fiber.Ctx) error {
 				Error: "Срок действия ящика истёк",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
+		return internalError(c, err)
+	}
+
+	return c.JSON(newMailboxResponse(mailbox))
+}
+
+// Extend продлевает срок действия ящика
+// @Summary Продлить почтовый ящик
+// @Description Сдвигает срок действия ящика на ttl вперёд. Остаток времени не может превышать MAX_TTL, а срок действия — предел жизни ящика (max_expires_at).
+// @Tags mailbox
+// @Accept json
+// @Produce json
+// @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
+// @Param request body ExtendRequest false "Параметры продления (необязательно)"
+// @Success 200 {object} MailboxResponse "Ящик продлён"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 404 {object} ErrorResponse "Ящик не найден"
+// @Failure 409 {object} ErrorResponse "Достигнут предел жизни ящика"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика истёк (используйте restore)"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /mailbox/{id}/extend [post]
+func (h *MailboxHandler) Extend(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req ExtendRequest
+	if err := c.BodyParser(&req); err != nil {
+		// Пустое тело — продлеваем на время по умолчанию
+		req = ExtendRequest{}
+	}
+
+	ttl, err := parseDuration(req.TTL)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат TTL. Используйте формат: 1h, 30m, 24h",
 		})
 	}
 
-	return c.JSON(MailboxResponse{
-		ID:        mailbox.ID,
-		Address:   mailbox.Address,
-		CreatedAt: mailbox.CreatedAt.Format(time.RFC3339),
-		ExpiresAt: mailbox.ExpiresAt.Format(time.RFC3339),
-		IsActive:  mailbox.IsActive,
-	})
+	mailbox, err := h.service.Extend(c.UserContext(), id, ttl)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTTL) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: "TTL превышает максимально допустимое значение",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
+		if errors.Is(err, service.ErrLifetimeReached) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Error: "Достигнут предельный срок жизни ящика",
+			})
+		}
+		return internalError(c, err)
+	}
+
+	return c.JSON(newMailboxResponse(mailbox))
+}
+
+// Restore восстанавливает истёкший ящик
+// @Summary Восстановить почтовый ящик
+// @Description Снова активирует ящик, срок действия которого истёк, но льготный период (GRACE_PERIOD) ещё не закончился. Новый срок отсчитывается от текущего момента.
+// @Tags mailbox
+// @Accept json
+// @Produce json
+// @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
+// @Param request body RestoreRequest false "Параметры восстановления (необязательно)"
+// @Success 200 {object} MailboxResponse "Ящик восстановлен"
+// @Failure 400 {object} ErrorResponse "Неверные параметры запроса"
+// @Failure 404 {object} ErrorResponse "Ящик не найден"
+// @Failure 409 {object} ErrorResponse "Ящик активен или достигнут предел его жизни"
+// @Failure 410 {object} ErrorResponse "Льготный период закончился"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /mailbox/{id}/restore [post]
+func (h *MailboxHandler) Restore(c *fiber.Ctx) error {
+	id := c.Params("id")
+
+	var req RestoreRequest
+	if err := c.BodyParser(&req); err != nil {
+		req = RestoreRequest{}
+	}
+
+	ttl, err := parseDuration(req.TTL)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+			Error: "Неверный формат TTL. Используйте формат: 1h, 30m, 24h",
+		})
+	}
+
+	mailbox, err := h.service.Restore(c.UserContext(), id, ttl)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTTL) {
+			return c.Status(fiber.StatusBadRequest).JSON(ErrorResponse{
+				Error: "TTL превышает максимально допустимое значение",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Льготный период закончился, ящик нельзя восстановить",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxActive) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Error: "Ящик активен, восстановление не требуется",
+			})
+		}
+		if errors.Is(err, service.ErrLifetimeReached) {
+			return c.Status(fiber.StatusConflict).JSON(ErrorResponse{
+				Error: "Достигнут предельный срок жизни ящика",
+			})
+		}
+		return internalError(c, err)
+	}
+
+	return c.JSON(newMailboxResponse(mailbox))
 }
 
 // Delete удаляет почтовый ящик
@@ -140,21 +356,25 @@ func (h *MailboxHandler) Get(c *fiber.Ctx) error {
 // @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
 // @Success 204 "Ящик успешно удалён"
 // @Failure 404 {object} ErrorResponse "Ящик не найден"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /mailbox/{id} [delete]
 func (h *MailboxHandler) Delete(c *fiber.Ctx) error {
 	id := c.Params("id")
 
-	err := h.service.Delete(id)
+	err := h.service.Delete(c.UserContext(), id)
 	if err != nil {
 		if errors.Is(err, service.ErrMailboxNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 				Error: "Почтовый ящик не найден",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
-		})
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
+		return internalError(c, err)
 	}
 
 	// 204 No Content — успешное удаление без тела ответа
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gofiber/fiber/v2"
 
+	"tempmail/internal/domain"
 	"tempmail/internal/service"
 )
 
@@ -24,19 +25,29 @@ type MessageResponse struct {
 	ID          string `json:"id"`
 	MailboxID   string `json:"mailbox_id"`
 	FromAddress string `json:"from_address"`
+	Recipient   string `json:"recipient"`
 	Subject     string `json:"subject"`
 	BodyText    string `json:"body_text,omitempty"`
 	BodyHTML    string `json:"body_html,omitempty"`
+	Tag         string `json:"tag,omitempty"`
 	ReceivedAt  string `json:"received_at"`
 	IsRead      bool   `json:"is_read"`
 	IsSpam      bool   `json:"is_spam"`
+
+	// Результаты проверки SPF, DKIM и DMARC (нет, если проверка выключена)
+	Authentication *domain.Authentication `json:"authentication,omitempty"`
+
+	// SMTP-конверт: MAIL FROM, RCPT TO, IP, HELO, TLS; from_address — это заголовок From
+	Envelope *domain.Envelope `json:"envelope,omitempty"`
 }
 
 // MessageListResponse — краткая информация о письме для списка
 type MessageListResponse struct {
 	ID          string `json:"id"`
 	FromAddress string `json:"from_address"`
+	Recipient   string `json:"recipient"`
 	Subject     string `json:"subject"`
+	Tag         string `json:"tag,omitempty"`
 	ReceivedAt  string `json:"received_at"`
 	IsRead      bool   `json:"is_read"`
 	IsSpam      bool   `json:"is_spam"`
@@ -44,27 +55,37 @@ type MessageListResponse struct {
 
 // GetMessages возвращает список писем
 // @Summary Получить список писем
-// @Description Возвращает список всех писем в почтовом ящике (без содержимого)
+// @Description Возвращает список писем в почтовом ящике (без содержимого). Параметр tag оставляет только письма, пришедшие на подадрес box+tag@domain.
 // @Tags messages
 // @Produce json
 // @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
+// @Param tag query string false "Тег подадреса" example("signup-42")
 // @Success 200 {array} MessageListResponse "Список писем"
 // @Failure 404 {object} ErrorResponse "Ящик не найден"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /mailbox/{id}/messages [get]
 func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
 	mailboxID := c.Params("id")
 
-	messages, err := h.service.GetByMailboxID(mailboxID)
+	// Query получает параметр из строки запроса (?tag=...)
+	filter := domain.MessageFilter{
+		Tag: c.Query("tag"),
+	}
+
+	messages, err := h.service.GetByMailboxID(c.UserContext(), mailboxID, filter)
 	if err != nil {
 		if errors.Is(err, service.ErrMailboxNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 				Error: "Почтовый ящик не найден",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
-		})
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
+		return internalError(c, err)
 	}
 
 	// Преобразуем в формат ответа
@@ -73,7 +94,9 @@ func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
 		response[i] = MessageListResponse{
 			ID:          msg.ID,
 			FromAddress: msg.FromAddress,
+			Recipient:   msg.Recipient,
 			Subject:     msg.Subject,
+			Tag:         msg.Tag,
 			ReceivedAt:  msg.ReceivedAt.Format(time.RFC3339),
 			IsRead:      msg.IsRead,
 			IsSpam:      msg.IsSpam,
@@ -85,44 +108,103 @@ func (h *MessageHandler) GetMessages(c *fiber.Ctx) error {
 
 // GetMessage возвращает письмо по ID
 // @Summary Получить письмо
-// @Description Возвращает полную информацию о письме включая содержимое. Автоматически помечает письмо как прочитанное.
+// @Description Возвращает полную информацию о письме включая содержимое, результаты проверки SPF, DKIM и DMARC (блок authentication) и SMTP-конверт (блок envelope). Автоматически помечает письмо как прочитанное.
 // @Tags messages
 // @Produce json
 // @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
 // @Param mid path string true "ID письма" example("550e8400-e29b-41d4-a716-446655440001")
 // @Success 200 {object} MessageResponse "Информация о письме"
-// @Failure 404 {object} ErrorResponse "Письмо не найдено"
+// @Failure 404 {object} ErrorResponse "Ящик или письмо не найдены"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /mailbox/{id}/messages/{mid} [get]
 func (h *MessageHandler) GetMessage(c *fiber.Ctx) error {
 	// mid — ID письма
 	messageID := c.Params("mid")
 
-	msg, err := h.service.GetByID(messageID)
+	msg, err := h.service.GetByID(c.UserContext(), c.Params("id"), messageID)
 	if err != nil {
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
 		if errors.Is(err, service.ErrMessageNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 				Error: "Письмо не найдено",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
-		})
+		return internalError(c, err)
 	}
 
 	return c.JSON(MessageResponse{
 		ID:          msg.ID,
 		MailboxID:   msg.MailboxID,
 		FromAddress: msg.FromAddress,
+		Recipient:   msg.Recipient,
 		Subject:     msg.Subject,
 		BodyText:    msg.BodyText,
 		BodyHTML:    msg.BodyHTML,
+		Tag:         msg.Tag,
 		ReceivedAt:  msg.ReceivedAt.Format(time.RFC3339),
 		IsRead:      msg.IsRead,
 		IsSpam:      msg.IsSpam,
+
+		Authentication: msg.Authentication,
+		Envelope:       msg.Envelope,
 	})
 }
 
+// GetRawMessage возвращает исходный текст письма
+// @Summary Исходный текст письма
+// @Description Возвращает письмо целиком в формате RFC 5322, включая заголовок Received нашего сервера
+// @Tags messages
+// @Produce message/rfc822
+// @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
+// @Param mid path string true "ID письма" example("550e8400-e29b-41d4-a716-446655440001")
+// @Success 200 {string} string "Исходный текст письма"
+// @Failure 404 {object} ErrorResponse "Ящик или письмо не найдены, или исходный текст не сохранён"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
+// @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
+// @Router /mailbox/{id}/messages/{mid}/raw [get]
+func (h *MessageHandler) GetRawMessage(c *fiber.Ctx) error {
+	messageID := c.Params("mid")
+
+	raw, err := h.service.GetRawSource(c.UserContext(), c.Params("id"), messageID)
+	if err != nil {
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
+		if errors.Is(err, service.ErrMessageNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Письмо не найдено",
+			})
+		}
+		if errors.Is(err, service.ErrNoRawSource) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Исходный текст письма не сохранён",
+			})
+		}
+		return internalError(c, err)
+	}
+
+	c.Set(fiber.HeaderContentType, "message/rfc822")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+messageID+`.eml"`)
+	return c.Send(raw)
+}
+
 // DeleteMessage удаляет письмо
 // @Summary Удалить письмо
 // @Description Удаляет письмо из почтового ящика
@@ -130,22 +212,31 @@ func (h *MessageHandler) GetMessage(c *fiber.Ctx) error {
 // @Param id path string true "ID почтового ящика" example("550e8400-e29b-41d4-a716-446655440000")
 // @Param mid path string true "ID письма" example("550e8400-e29b-41d4-a716-446655440001")
 // @Success 204 "Письмо успешно удалено"
-// @Failure 404 {object} ErrorResponse "Письмо не найдено"
+// @Failure 404 {object} ErrorResponse "Ящик или письмо не найдены"
+// @Failure 410 {object} ErrorResponse "Срок действия ящика и льготный период истекли"
 // @Failure 500 {object} ErrorResponse "Внутренняя ошибка сервера"
 // @Router /mailbox/{id}/messages/{mid} [delete]
 func (h *MessageHandler) DeleteMessage(c *fiber.Ctx) error {
 	messageID := c.Params("mid")
 
-	err := h.service.Delete(messageID)
+	err := h.service.Delete(c.UserContext(), c.Params("id"), messageID)
 	if err != nil {
+		if errors.Is(err, service.ErrMailboxNotFound) {
+			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
+				Error: "Почтовый ящик не найден",
+			})
+		}
+		if errors.Is(err, service.ErrMailboxExpired) {
+			return c.Status(fiber.StatusGone).JSON(ErrorResponse{
+				Error: "Срок действия ящика истёк",
+			})
+		}
 		if errors.Is(err, service.ErrMessageNotFound) {
 			return c.Status(fiber.StatusNotFound).JSON(ErrorResponse{
 				Error: "Письмо не найдено",
 			})
 		}
-		return c.Status(fiber.StatusInternalServerError).JSON(ErrorResponse{
-			Error: "Внутренняя ошибка сервера",
-		})
+		return internalError(c, err)
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
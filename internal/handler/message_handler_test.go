@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/health"
+	"tempmail/internal/metrics"
+	"tempmail/internal/ratelimit"
+	"tempmail/internal/service"
+	"tempmail/internal/storage"
+)
+
+// testAPI — приложение со всеми маршрутами поверх хранилища в памяти
+type testAPI struct {
+	app       *fiber.App
+	store     *storage.Storage
+	mailboxes *service.MailboxService
+	messages  *service.MessageService
+	keys      *service.APIKeyService
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	store, err := storage.Open(config.StorageConfig{Backend: "memory"}, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	mail := config.MailConfig{
+		Domain:        "tempmail.test",
+		DefaultTTL:    time.Hour,
+		MaxTTL:        24 * time.Hour,
+		MaxLifetime:   48 * time.Hour,
+		GracePeriod:   time.Hour,
+		AddressLength: 10,
+		AddressDots:   service.DotsKeep,
+	}
+	generator, err := service.NewAddressGenerator(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := service.NewAddressPolicy(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits, err := NewRateLimiter(config.APILimitsConfig{}, ratelimit.NewMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := metrics.New()
+	statsService := service.NewStatsService(store.Stats, config.StatsConfig{}, nil)
+	api := &testAPI{
+		app:       fiber.New(),
+		store:     store,
+		mailboxes: service.NewMailboxService(store.Mailboxes, store.APIKeys, mail, generator, policy, nil, nil),
+		messages:  service.NewMessageService(store.Messages, store.Mailboxes, config.LimitsConfig{MaxMessageSize: 1 << 20, MaxMessagesPerMailbox: 10}, mail, nil),
+		keys:      service.NewAPIKeyService(store.APIKeys),
+	}
+
+	SetupRoutes(
+		api.app,
+		NewMailboxHandler(api.mailboxes),
+		NewMessageHandler(api.messages),
+		NewAPIKeyHandler(api.keys),
+		NewAdminHandler(service.NewAdminService(store.Mailboxes, store.Messages, nil)),
+		NewStatsHandler(statsService, m),
+		NewAuthMiddleware(api.keys, api.mailboxes, config.AuthConfig{}),
+		limits,
+		m,
+		health.NewRegistry(time.Second),
+		0,
+		nil,
+	)
+	return api
+}
+
+// do выполняет запрос с ключом key (пусто — анонимно) и возвращает код ответа
+func (a *testAPI) do(t *testing.T, method, path, key string) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	resp, err := a.app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestMessageOfForeignMailbox проверяет, что письмо ящика, созданного с ключом,
+// нельзя прочитать, выгрузить или удалить через ID чужого анонимного ящика
+func TestMessageOfForeignMailbox(t *testing.T) {
+	ctx := context.Background()
+	api := newTestAPI(t)
+
+	name := "owner"
+	key, err := api.keys.Create(ctx, service.APIKeyOptions{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := api.mailboxes.Create(ctx, service.CreateOptions{APIKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anonymous, err := api.mailboxes.Create(ctx, service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := &domain.Message{
+		MailboxID:   private.ID,
+		FromAddress: "alice@example.com",
+		Recipient:   private.Address,
+		Subject:     "secret",
+		BodyText:    "secret",
+		ReceivedAt:  time.Now(),
+		RawSource:   []byte("Subject: secret\r\n\r\nsecret\r\n"),
+	}
+	if err := api.messages.Create(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	foreign := "/api/v1/mailbox/" + anonymous.ID + "/messages/" + msg.ID
+	own := "/api/v1/mailbox/" + private.ID + "/messages/" + msg.ID
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		key    string
+		want   int
+	}{
+		{"read via foreign mailbox", http.MethodGet, foreign, "", http.StatusNotFound},
+		{"raw via foreign mailbox", http.MethodGet, foreign + "/raw", "", http.StatusNotFound},
+		{"delete via foreign mailbox", http.MethodDelete, foreign, "", http.StatusNotFound},
+		{"read own mailbox without key", http.MethodGet, own, "", http.StatusNotFound},
+		{"read own mailbox", http.MethodGet, own, key.Key, http.StatusOK},
+		{"raw own mailbox", http.MethodGet, own + "/raw", key.Key, http.StatusOK},
+		{"delete own mailbox", http.MethodDelete, own, key.Key, http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := api.do(t, tt.method, tt.path, tt.key); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestMessageOfGoneMailbox проверяет, что после льготного периода письма ящика
+// недоступны по отдельности, как и их список
+func TestMessageOfGoneMailbox(t *testing.T) {
+	ctx := context.Background()
+	api := newTestAPI(t)
+
+	mailbox, err := api.mailboxes.Create(ctx, service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &domain.Message{
+		MailboxID:   mailbox.ID,
+		FromAddress: "alice@example.com",
+		Recipient:   mailbox.Address,
+		Subject:     "late",
+		ReceivedAt:  time.Now(),
+		RawSource:   []byte("Subject: late\r\n\r\nlate\r\n"),
+	}
+	if err := api.messages.Create(ctx, msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Срок истёк два часа назад, льготный период — час; очистка ещё не прошла
+	if err := api.store.Mailboxes.UpdateExpiresAt(ctx, mailbox.ID, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/v1/mailbox/" + mailbox.ID + "/messages/" + msg.ID
+	missing := "/api/v1/mailbox/00000000-0000-0000-0000-000000000000/messages/" + msg.ID
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"list", http.MethodGet, "/api/v1/mailbox/" + mailbox.ID + "/messages", http.StatusGone},
+		{"read", http.MethodGet, path, http.StatusGone},
+		{"raw", http.MethodGet, path + "/raw", http.StatusGone},
+		{"delete", http.MethodDelete, path, http.StatusGone},
+		{"read via missing mailbox", http.MethodGet, missing, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := api.do(t, tt.method, tt.path, ""); got != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got, err := api.store.Messages.GetByID(ctx, msg.ID); err != nil || got == nil {
+		t.Errorf("message after rejected delete: %+v, %v; want it kept until cleanup", got, err)
+	}
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/metrics"
+)
+
+// MetricsMiddleware замеряет время обработки запросов
+// Маршрут берётся шаблоном (/api/v1/mailbox/:id), чтобы число меток не росло с числом ящиков
+func MetricsMiddleware(m *metrics.Metrics) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		m.ObserveHTTP(c.Method(), c.Route().Path, responseStatus(c, err), time.Since(start))
+		return err
+	}
+}
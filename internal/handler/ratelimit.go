@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/config"
+	"tempmail/internal/logging"
+	"tempmail/internal/ratelimit"
+)
+
+// RateLimiter — ограничение частоты запросов к REST API
+// Создание ящиков, чтение и выгрузка исходников считаются в отдельных бюджетах
+type RateLimiter struct {
+	create *ratelimit.Limiter // Создание ящиков
+	read   *ratelimit.Limiter // Чтение и изменение ящиков и писем
+	stream *ratelimit.Limiter // Выгрузка исходных текстов писем
+	allow  ratelimit.IPList   // IP без ограничений
+}
+
+// NewRateLimiter создаёт ограничитель запросов
+func NewRateLimiter(cfg config.APILimitsConfig, store ratelimit.Store) (*RateLimiter, error) {
+	allow, err := ratelimit.ParseIPList(cfg.AllowIPs)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP_RATE_ALLOW_IPS: %w", err)
+	}
+
+	return &RateLimiter{
+		create: ratelimit.New(store, "http-create", cfg.Create),
+		read:   ratelimit.New(store, "http-read", cfg.Read),
+		stream: ratelimit.New(store, "http-stream", cfg.Stream),
+		allow:  allow,
+	}, nil
+}
+
+// Create ограничивает создание ящиков
+func (l *RateLimiter) Create(c *fiber.Ctx) error {
+	return l.limit(c, l.create)
+}
+
+// Read ограничивает чтение и изменение ящиков и писем
+func (l *RateLimiter) Read(c *fiber.Ctx) error {
+	return l.limit(c, l.read)
+}
+
+// Stream ограничивает выгрузку исходных текстов писем
+func (l *RateLimiter) Stream(c *fiber.Ctx) error {
+	return l.limit(c, l.stream)
+}
+
+// limit учитывает запрос в бюджете limiter и отвечает 429, если бюджет исчерпан
+// Ставится после AuthMiddleware.APIKey: запросы с ключом считаются по ключу
+func (l *RateLimiter) limit(c *fiber.Ctx, limiter *ratelimit.Limiter) error {
+	rate := limiter.Rate()
+	if !rate.Enabled() {
+		return c.Next()
+	}
+
+	key := l.clientKey(c)
+	if key == "" {
+		return c.Next()
+	}
+
+	result, err := limiter.Allow(c.UserContext(), key)
+	if err != nil {
+		// Недоступное хранилище счётчиков не должно останавливать API
+		requestLogger(c).Warn("rate limit check failed", logging.Err(err))
+		return c.Next()
+	}
+
+	// Заголовки по черновику IETF RateLimit header fields
+	c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", rate.Limit, seconds(rate.Window)))
+	c.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	c.Set("RateLimit-Reset", strconv.Itoa(seconds(result.Reset)))
+
+	if !result.Allowed {
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(seconds(result.RetryAfter)))
+		return c.Status(fiber.StatusTooManyRequests).JSON(ErrorResponse{
+			Error:   "Слишком много запросов",
+			Details: fmt.Sprintf("лимит %s, повторите через %d с", rate, seconds(result.RetryAfter)),
+		})
+	}
+	return c.Next()
+}
+
+// clientKey возвращает ключ счётчика: ID API-ключа или IP клиента
+// Пустая строка — запрос не ограничивается
+func (l *RateLimiter) clientKey(c *fiber.Ctx) string {
+	if key := apiKeyFrom(c); key != nil {
+		return "key:" + key.ID
+	}
+
+	// Без корректного заголовка прокси считаем по адресу соединения
+	ip := net.ParseIP(c.IP())
+	if ip == nil {
+		ip = c.Context().RemoteIP()
+	}
+	if l.allow.Contains(ip) {
+		return ""
+	}
+	return "ip:" + ratelimit.IPKey(ip)
+}
+
+// seconds округляет длительность вверх до целых секунд
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -1,13 +1,18 @@
 package handler
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	"github.com/gofiber/swagger"
 
-	"tempmail/internal/service"
+	"tempmail/internal/health"
+	"tempmail/internal/metrics"
 )
 
 // SetupRoutes настраивает все маршруты приложения
@@ -15,14 +20,30 @@ func SetupRoutes(
 	app *fiber.App,
 	mailboxHandler *MailboxHandler,
 	messageHandler *MessageHandler,
+	apiKeyHandler *APIKeyHandler,
+	adminHandler *AdminHandler,
+	statsHandler *StatsHandler,
+	auth *AuthMiddleware,
+	limits *RateLimiter,
+	m *metrics.Metrics,
+	checks *health.Registry,
+	requestTimeout time.Duration,
+	logger *slog.Logger,
 ) {
 	// Middleware
-	app.Use(logger.New())
+	app.Use(requestid.New())
+	app.Use(TracingMiddleware())
+	app.Use(RequestLogger(logger))
+	app.Use(RequestTimeout(requestTimeout))
+	// Паника превращается в ошибку, которую RequestLogger запишет как 500
 	app.Use(recover.New())
+	app.Use(MetricsMiddleware(m))
 	app.Use(cors.New(cors.Config{
 		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Content-Type,Authorization",
+		AllowMethods: "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+		AllowHeaders: "Content-Type,Authorization,X-API-Key,X-Request-ID",
+		// Браузерным клиентам нужны заголовки лимитов и ID запроса
+		ExposeHeaders: "RateLimit-Policy,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After,X-Request-ID",
 	}))
 
 	// Swagger UI
@@ -32,44 +53,80 @@ func SetupRoutes(
 	api := app.Group("/api/v1")
 
 	// Mailbox routes
-	mailbox := api.Group("/mailbox")
-	mailbox.Post("/", mailboxHandler.Create)
-	mailbox.Get("/:id", mailboxHandler.Get)
-	mailbox.Delete("/:id", mailboxHandler.Delete)
+	// Ящик, созданный с ключом, доступен только с этим ключом (auth.MailboxOwner)
+	// Лимиты ставятся после auth.APIKey: запросы с ключом считаются по ключу, без ключа — по IP
+	mailbox := api.Group("/mailbox", auth.APIKey)
+	mailbox.Post("/", limits.Create, mailboxHandler.Create)
+	mailbox.Get("/:id", limits.Read, auth.MailboxOwner, mailboxHandler.Get)
+	mailbox.Delete("/:id", limits.Read, auth.MailboxOwner, mailboxHandler.Delete)
+	mailbox.Post("/:id/extend", limits.Read, auth.MailboxOwner, mailboxHandler.Extend)
+	mailbox.Post("/:id/restore", limits.Read, auth.MailboxOwner, mailboxHandler.Restore)
 
 	// Message routes
-	mailbox.Get("/:id/messages", messageHandler.GetMessages)
-	mailbox.Get("/:id/messages/:mid", messageHandler.GetMessage)
-	mailbox.Delete("/:id/messages/:mid", messageHandler.DeleteMessage)
+	mailbox.Get("/:id/messages", limits.Read, auth.MailboxOwner, messageHandler.GetMessages)
+	mailbox.Get("/:id/messages/:mid", limits.Read, auth.MailboxOwner, messageHandler.GetMessage)
+	mailbox.Get("/:id/messages/:mid/raw", limits.Stream, auth.MailboxOwner, messageHandler.GetRawMessage)
+	mailbox.Delete("/:id/messages/:mid", limits.Read, auth.MailboxOwner, messageHandler.DeleteMessage)
+
+	// Ящики и использование ключа клиента
+	api.Get("/mailboxes", auth.APIKey, auth.RequireAPIKey, limits.Read, mailboxHandler.List)
+	api.Get("/keys/me", auth.APIKey, auth.RequireAPIKey, limits.Read, apiKeyHandler.Me)
+
+	// Admin routes (ADMIN_TOKEN)
+	admin := api.Group("/admin", auth.Admin)
+	admin.Post("/keys", apiKeyHandler.Create)
+	admin.Get("/keys", apiKeyHandler.List)
+	admin.Get("/keys/:id", apiKeyHandler.Get)
+	admin.Patch("/keys/:id", apiKeyHandler.Update)
+	admin.Delete("/keys/:id", apiKeyHandler.Delete)
+	admin.Get("/mailboxes", adminHandler.SearchMailboxes)
+	admin.Post("/mailboxes/expire", adminHandler.ExpireMailboxes)
+	admin.Delete("/mailboxes/:id", adminHandler.DeleteMailbox)
+	admin.Get("/messages", adminHandler.SearchMessages)
+	admin.Get("/messages/spam", adminHandler.SpamMessages)
+	admin.Post("/messages/:id/quarantine", adminHandler.Quarantine)
+	admin.Delete("/messages/:id/quarantine", adminHandler.Release)
+	admin.Get("/reports/senders", adminHandler.TopSenders)
+	admin.Get("/reports/recipients", adminHandler.TopRecipients)
 
 	// Health check
 	// @Summary Проверка здоровья
-	// @Description Возвращает статус сервера
+	// @Description Возвращает статус сервера; то же, что /livez
 	// @Tags system
 	// @Produce json
 	// @Success 200 {object} map[string]string "Статус сервера"
 	// @Router /health [get]
-	app.Get("/health", func(c *fiber.Ctx) error {
-		return c.JSON(fiber.Map{
-			"status": "ok",
-		})
-	})
+	app.Get("/health", adaptor.HTTPHandler(checks.LiveHandler()))
 
-	// Stats
-	// @Summary Статистика сервиса
-	// @Description Возвращает статистику работы сервиса
+	// Liveness
+	// @Summary Процесс жив
+	// @Description Отвечает 200, пока процесс обрабатывает запросы; зависимости не проверяет
 	// @Tags system
 	// @Produce json
-	// @Success 200 {object} map[string]interface{} "Статистика"
-	// @Router /stats [get]
-	app.Get("/stats", func(c *fiber.Ctx) error {
-		stats := service.GlobalStats.GetStats()
-		return c.JSON(fiber.Map{
-			"total_mailboxes":   stats.TotalMailboxes,
-			"total_messages":    stats.TotalMessages,
-			"total_spam":        stats.TotalSpamMessages,
-			"deleted_mailboxes": stats.DeletedMailboxes,
-			"last_cleanup":      stats.LastCleanup.Format("2006-01-02 15:04:05"),
-		})
-	})
+	// @Success 200 {object} map[string]string "Статус процесса"
+	// @Router /livez [get]
+	app.Get("/livez", adaptor.HTTPHandler(checks.LiveHandler()))
+
+	// Readiness
+	// @Summary Готовность к работе
+	// @Description Проверяет PostgreSQL, версию схемы, запись в БД, Redis, SMTP-порты и очистку ящиков.
+	// @Description Отвечает 503, если хотя бы одна проверка не прошла
+	// @Tags system
+	// @Produce json
+	// @Success 200 {object} health.Report "Все проверки прошли"
+	// @Failure 503 {object} health.Report "Есть неисправные компоненты"
+	// @Router /readyz [get]
+	app.Get("/readyz", adaptor.HTTPHandler(checks.ReadyHandler()))
+
+	// Stats
+	app.Get("/stats", statsHandler.Get)
+
+	// Metrics
+	// @Summary Метрики Prometheus
+	// @Description Счётчики и гистограммы SMTP, HTTP, БД и очистки в текстовом формате Prometheus
+	// @Tags system
+	// @Produce plain
+	// @Success 200 {string} string "Метрики"
+	// @Router /metrics [get]
+	app.Get("/metrics", adaptor.HTTPHandler(m.Handler()))
 }
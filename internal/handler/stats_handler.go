@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/metrics"
+	"tempmail/internal/service"
+)
+
+// Значения по умолчанию для временного ряда /stats
+const (
+	defaultStatsRange  = "24h"
+	defaultStatsBucket = "1h"
+)
+
+// StatsHandler — обработчик статистики сервиса
+type StatsHandler struct {
+	service *service.StatsService
+	metrics *metrics.Metrics
+}
+
+// NewStatsHandler создаёт новый обработчик
+func NewStatsHandler(svc *service.StatsService, m *metrics.Metrics) *StatsHandler {
+	return &StatsHandler{service: svc, metrics: m}
+}
+
+// StatsSeriesResponse — временной ряд статистики
+type StatsSeriesResponse struct {
+	Range  string             `json:"range"`  // Период, например 7d
+	Bucket string             `json:"bucket"` // Интервал точки, например 1h
+	Points []domain.StatPoint `json:"points"` // Точки от старых к новым
+}
+
+// Get возвращает статистику сервиса
+// @Summary Статистика сервиса
+// @Description Возвращает счётчики за всё время работы сервиса (по всем экземплярам).
+// @Description С параметрами range и bucket добавляет временной ряд, например ?range=7d&bucket=1h
+// @Tags system
+// @Produce json
+// @Param range query string false "Период ряда: 24h, 7d, 30d"
+// @Param bucket query string false "Интервал точки, целое число часов: 1h, 6h, 1d"
+// @Success 200 {object} map[string]interface{} "Статистика"
+// @Failure 400 {object} ErrorResponse "Неверные параметры"
+// @Router /stats [get]
+func (h *StatsHandler) Get(c *fiber.Ctx) error {
+	totals, err := h.service.Totals(c.UserContext())
+	if err != nil {
+		return internalError(c, err)
+	}
+	live, err := h.metrics.Snapshot()
+	if err != nil {
+		return internalError(c, err)
+	}
+
+	lastCleanup := ""
+	if !live.LastCleanup.IsZero() {
+		lastCleanup = live.LastCleanup.Format("2006-01-02 15:04:05")
+	}
+	response := fiber.Map{
+		"total_mailboxes":   totals[domain.StatMailboxesCreated],
+		"total_messages":    totals[domain.StatMessagesStored],
+		"total_spam":        totals[domain.StatSpamMessages],
+		"expired_mailboxes": totals[domain.StatMailboxesExpired],
+		"deleted_mailboxes": totals[domain.StatMailboxesDeleted],
+		"removed_mailboxes": totals[domain.StatMailboxesRemoved],
+		"deleted_messages":  totals[domain.StatMessagesDeleted],
+		"last_cleanup":      lastCleanup,
+		"smtp": fiber.Map{
+			"active_sessions":      live.ActiveSMTPSessions,
+			"sessions":             totals[domain.StatSMTPSessions],
+			"rejected_connections": totals[domain.StatConnectionsRejected],
+			"early_talkers":        totals[domain.StatEarlyTalkers],
+			"recipients_accepted":  totals[domain.StatRecipientsAccepted],
+			"recipients_rejected":  totals[domain.StatRecipientsRejected],
+			"rate_limited":         totals[domain.StatRateLimited],
+			"greylisted":           totals[domain.StatGreylisted],
+			"parse_failures":       totals[domain.StatParseFailures],
+		},
+	}
+
+	// Временной ряд — только по запросу
+	if c.Query("range") == "" && c.Query("bucket") == "" {
+		return c.JSON(response)
+	}
+
+	rawRange := c.Query("range", defaultStatsRange)
+	rng, err := parseStatsDuration(rawRange)
+	if err != nil {
+		return badQuery(c, errors.New("range: "+err.Error()))
+	}
+	rawBucket := c.Query("bucket", defaultStatsBucket)
+	bucket, err := parseStatsDuration(rawBucket)
+	if err != nil {
+		return badQuery(c, errors.New("bucket: "+err.Error()))
+	}
+
+	points, err := h.service.Series(c.UserContext(), rng, bucket)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidStatsRange) {
+			return badQuery(c, errors.New("bucket — целое число часов, не больше range; слишком много точек"))
+		}
+		return internalError(c, err)
+	}
+
+	response["series"] = StatsSeriesResponse{Range: rawRange, Bucket: rawBucket, Points: points}
+	return c.JSON(response)
+}
+
+// parseStatsDuration разбирает длительность; кроме формата Go понимает дни: 7d
+func parseStatsDuration(raw string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, errors.New("ожидается число дней, например 7d")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d <= 0 {
+		return 0, errors.New("ожидается длительность, например 24h или 7d")
+	}
+	return d, nil
+}
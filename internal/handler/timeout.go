@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestTimeout ограничивает время обработки запроса (HTTP_REQUEST_TIMEOUT)
+// Срок передаётся через UserContext сервисам и запросам к БД; по его истечении
+// незавершённые запросы к БД отменяются. 0 — без ограничения
+//
+// fasthttp не сообщает об обрыве соединения клиентом, поэтому работа
+// ушедшего клиента прекращается не раньше этого срока
+func RequestTimeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if timeout <= 0 {
+			return c.Next()
+		}
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"tempmail/internal/tracing"
+)
+
+// TracingMiddleware открывает спан на каждый запрос
+// Родитель берётся из заголовка traceparent, если клиент его прислал
+// Спан кладётся в UserContext, откуда его получают сервисы и запросы к БД
+func TracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), propagation.HeaderCarrier(http.Header(c.GetReqHeaders())))
+		ctx, span := tracing.StartServer(ctx, c.Method(),
+			attribute.String("http.request.method", c.Method()),
+			attribute.String("url.path", c.Path()),
+			attribute.String("request_id", requestID(c)),
+		)
+		defer span.End()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+
+		// Маршрут известен только после того, как Fiber выбрал обработчик
+		status := responseStatus(c, err)
+		span.SetName(c.Method() + " " + c.Route().Path)
+		span.SetAttributes(
+			attribute.String("http.route", c.Route().Path),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+		return err
+	}
+}
+
+// traceID возвращает ID трассы запроса или пустую строку, если трасса не пишется
+func traceID(c *fiber.Ctx) string {
+	sc := trace.SpanContextFromContext(c.UserContext())
+	if !sc.IsSampled() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
@@ -0,0 +1,142 @@
+// Package health проверяет готовность сервиса к работе (/livez, /readyz)
+//
+// Компоненты регистрируют проверки в Registry при запуске. /readyz выполняет
+// все проверки параллельно и отвечает 503, если хотя бы одна не прошла:
+// оркестратор перестаёт направлять трафик на экземпляр. /livez проверяет
+// только то, что процесс отвечает, — перезапуск не лечит упавшую БД.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Статусы проверок и отчёта
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Check проверяет один компонент; nil — компонент исправен
+// Проверка должна завершаться при отмене ctx
+type Check func(ctx context.Context) error
+
+// Result — результат одной проверки
+type Result struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report — результаты всех проверок
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// check — зарегистрированная проверка
+type check struct {
+	name string
+	run  Check
+}
+
+// Registry — набор проверок готовности
+type Registry struct {
+	timeout time.Duration // Время на все проверки одного запроса
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+// NewRegistry создаёт пустой набор проверок
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register добавляет проверку компонента name
+func (r *Registry) Register(name string, run Check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, check{name: name, run: run})
+}
+
+// Run выполняет все проверки параллельно
+func (r *Registry) Run(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := append([]check(nil), r.checks...)
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c.run)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for i, c := range checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// runCheck выполняет проверку; зависшая проверка прерывается по ctx
+func runCheck(ctx context.Context, run Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- run(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LiveHandler возвращает обработчик /livez: процесс жив, раз отвечает
+func (r *Registry) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+	})
+}
+
+// ReadyHandler возвращает обработчик /readyz: 200, если все проверки прошли, иначе 503
+func (r *Registry) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Run(req.Context())
+		status := http.StatusOK
+		if report.Status != StatusOK {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, status, report)
+	})
+}
+
+// writeJSON отправляет ответ в JSON
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
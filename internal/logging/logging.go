@@ -0,0 +1,69 @@
+// Package logging настраивает структурированный логгер (log/slog)
+//
+// Логгер создаётся один раз в main и передаётся компонентам явно.
+// Компоненты дополняют его своими атрибутами (request_id, session_id),
+// поэтому все записи одного запроса или SMTP-сессии легко найти.
+// В логи не попадают пароли, токены и содержимое писем.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"tempmail/internal/config"
+)
+
+// New создаёт логгер с уровнем и форматом из конфигурации
+func New(cfg config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, fmt.Errorf("неизвестный уровень логирования %q", cfg.Level)
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(cfg.Format) {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("неизвестный формат логов %q: ожидается text или json", cfg.Format)
+	}
+}
+
+// Discard возвращает логгер, который ничего не пишет
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError + 1}))
+}
+
+// OrDiscard возвращает logger или, если он nil, логгер без вывода
+func OrDiscard(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return Discard()
+	}
+	return logger
+}
+
+// contextKey — ключ логгера в контексте
+type contextKey struct{}
+
+// WithContext сохраняет логгер в контексте
+func WithContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext возвращает логгер из контекста; если его нет — fallback
+func FromContext(ctx context.Context, fallback *slog.Logger) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return OrDiscard(fallback)
+}
+
+// Err — атрибут с текстом ошибки
+func Err(err error) slog.Attr {
+	return slog.Any("error", err)
+}
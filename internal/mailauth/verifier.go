@@ -0,0 +1,224 @@
+package mailauth
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/mail"
+	"strings"
+
+	"blitiri.com.ar/go/spf"
+	"github.com/emersion/go-msgauth/dkim"
+	"github.com/emersion/go-msgauth/dmarc"
+	"golang.org/x/net/publicsuffix"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/resolver"
+)
+
+// maxDKIMSignatures — сколько подписей проверять в одном письме
+const maxDKIMSignatures = 5
+
+// Verifier проверяет SPF, DKIM и DMARC входящих писем
+type Verifier struct {
+	resolver resolver.Resolver // Все DNS-запросы идут через него
+	dns      config.DNSConfig  // Таймаут проверки
+}
+
+// NewVerifier создаёт новый проверяющий
+func NewVerifier(r resolver.Resolver, cfg config.DNSConfig) *Verifier {
+	return &Verifier{
+		resolver: r,
+		dns:      cfg,
+	}
+}
+
+// Input — данные SMTP-сессии и письма, необходимые для проверки
+type Input struct {
+	IP       net.IP // Адрес подключившегося клиента
+	Helo     string // Имя из HELO/EHLO
+	MailFrom string // Адрес из MAIL FROM (может быть пустым для уведомлений о доставке)
+	Raw      []byte // Исходный текст письма
+}
+
+// Verify выполняет все проверки
+// Ошибки DNS не прерывают проверку, а отражаются в результатах как temperror
+func (v *Verifier) Verify(ctx context.Context, in Input) *domain.Authentication {
+	ctx, cancel := resolver.WithTimeout(ctx, v.dns)
+	defer cancel()
+
+	auth := &domain.Authentication{
+		SPF:  v.checkSPF(ctx, in),
+		DKIM: v.checkDKIM(ctx, in.Raw),
+	}
+	auth.DMARC = v.checkDMARC(ctx, headerFromDomain(in.Raw), auth.SPF, auth.DKIM)
+
+	return auth
+}
+
+// checkSPF проверяет, разрешено ли IP отправлять почту от домена MAIL FROM
+// Для пустого MAIL FROM (уведомления о недоставке) проверяется домен из HELO
+func (v *Verifier) checkSPF(ctx context.Context, in Input) domain.SPFResult {
+	result := domain.SPFResult{
+		Domain: addressDomain(in.MailFrom),
+		Result: domain.AuthNone,
+	}
+	if result.Domain == "" {
+		result.Domain = in.Helo
+	}
+	if in.IP == nil {
+		return result
+	}
+	result.IP = in.IP.String()
+
+	res, _ := spf.CheckHostWithSender(in.IP, in.Helo, in.MailFrom,
+		spf.WithResolver(v.resolver),
+		spf.WithContext(ctx),
+	)
+	result.Result = string(res)
+	return result
+}
+
+// checkDKIM проверяет все DKIM-подписи письма
+// Письмо без подписей даёт пустой список
+func (v *Verifier) checkDKIM(ctx context.Context, raw []byte) []domain.DKIMResult {
+	verifications, err := dkim.VerifyWithOptions(bytes.NewReader(raw), &dkim.VerifyOptions{
+		LookupTXT:        v.lookupTXT(ctx),
+		MaxVerifications: maxDKIMSignatures,
+	})
+	if err != nil && !errors.Is(err, dkim.ErrTooManySignatures) {
+		// Письмо не удалось разобрать — подписи проверить невозможно
+		return []domain.DKIMResult{{Result: domain.AuthPermError, Error: err.Error()}}
+	}
+
+	results := make([]domain.DKIMResult, 0, len(verifications))
+	for _, ver := range verifications {
+		res := domain.DKIMResult{
+			Result:     domain.AuthPass,
+			Domain:     ver.Domain,
+			Identifier: ver.Identifier,
+		}
+		if ver.Err != nil {
+			res.Error = ver.Err.Error()
+			switch {
+			case dkim.IsTempFail(ver.Err):
+				res.Result = domain.AuthTempError
+			case dkim.IsPermFail(ver.Err):
+				res.Result = domain.AuthPermError
+			default:
+				res.Result = domain.AuthFail
+			}
+		}
+		results = append(results, res)
+	}
+	return results
+}
+
+// checkDMARC проверяет политику DMARC домена из заголовка From (RFC 7489)
+// Письмо проходит DMARC, если SPF или DKIM прошли для домена, выровненного с From
+func (v *Verifier) checkDMARC(ctx context.Context, fromDomain string, spfRes domain.SPFResult, dkimRes []domain.DKIMResult) domain.DMARCResult {
+	result := domain.DMARCResult{
+		Domain: fromDomain,
+		Result: domain.AuthNone,
+	}
+	if fromDomain == "" {
+		return result
+	}
+
+	record, err := v.lookupDMARC(ctx, fromDomain)
+	if errors.Is(err, dmarc.ErrNoPolicy) {
+		return result
+	}
+	if err != nil {
+		result.Result = domain.AuthPermError
+		if dmarc.IsTempFail(err) {
+			result.Result = domain.AuthTempError
+		}
+		return result
+	}
+	result.Policy = string(record.Policy)
+
+	// Выравнивание SPF: домен MAIL FROM совпадает с доменом From
+	result.SPFAligned = spfRes.Result == domain.AuthPass &&
+		aligned(spfRes.Domain, fromDomain, record.SPFAlignment)
+
+	// Выравнивание DKIM: хотя бы одна валидная подпись домена From
+	for _, sig := range dkimRes {
+		if sig.Result == domain.AuthPass && aligned(sig.Domain, fromDomain, record.DKIMAlignment) {
+			result.DKIMAligned = true
+			break
+		}
+	}
+
+	result.Result = domain.AuthFail
+	if result.SPFAligned || result.DKIMAligned {
+		result.Result = domain.AuthPass
+	}
+	return result
+}
+
+// lookupDMARC ищет запись DMARC для домена, а если её нет — для организационного домена
+func (v *Verifier) lookupDMARC(ctx context.Context, fromDomain string) (*dmarc.Record, error) {
+	opts := &dmarc.LookupOptions{LookupTXT: v.lookupTXT(ctx)}
+
+	record, err := dmarc.LookupWithOptions(fromDomain, opts)
+	if !errors.Is(err, dmarc.ErrNoPolicy) {
+		return record, err
+	}
+
+	org := organizationalDomain(fromDomain)
+	if org == fromDomain {
+		return nil, err
+	}
+	return dmarc.LookupWithOptions(org, opts)
+}
+
+// lookupTXT адаптирует резолвер к сигнатуре, которую ждёт go-msgauth
+func (v *Verifier) lookupTXT(ctx context.Context) func(string) ([]string, error) {
+	return func(name string) ([]string, error) {
+		return v.resolver.LookupTXT(ctx, name)
+	}
+}
+
+// aligned проверяет выравнивание доменов по правилам DMARC:
+// strict — точное совпадение, relaxed — совпадение организационных доменов
+func aligned(a, b string, mode dmarc.AlignmentMode) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if mode == dmarc.AlignmentStrict {
+		return a == b
+	}
+	return organizationalDomain(a) == organizationalDomain(b)
+}
+
+// organizationalDomain возвращает регистрируемый домен: mail.example.co.uk → example.co.uk
+func organizationalDomain(name string) string {
+	org, err := publicsuffix.EffectiveTLDPlusOne(strings.ToLower(name))
+	if err != nil {
+		return strings.ToLower(name)
+	}
+	return org
+}
+
+// headerFromDomain извлекает домен из заголовка From письма
+func headerFromDomain(raw []byte) string {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return ""
+	}
+	addr, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		return ""
+	}
+	return addressDomain(addr.Address)
+}
+
+// addressDomain возвращает домен email-адреса или пустую строку
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(address[at+1:])
+}
@@ -0,0 +1,309 @@
+package mailauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/emersion/go-msgauth/dkim"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+)
+
+// stubResolver отвечает на DNS-запросы из заранее заданных записей
+// Имена без записей дают NXDOMAIN, имена из errs — заданную ошибку
+type stubResolver struct {
+	txt  map[string][]string
+	errs map[string]error
+}
+
+func (r stubResolver) LookupTXT(_ context.Context, name string) ([]string, error) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	if err, ok := r.errs[name]; ok {
+		return nil, err
+	}
+	if txt, ok := r.txt[name]; ok {
+		return txt, nil
+	}
+	return nil, notFound(name)
+}
+
+func (r stubResolver) LookupMX(_ context.Context, name string) ([]*net.MX, error) {
+	return nil, notFound(name)
+}
+
+func (r stubResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	return nil, notFound(host)
+}
+
+func (r stubResolver) LookupAddr(_ context.Context, addr string) ([]string, error) {
+	return nil, notFound(addr)
+}
+
+func (r stubResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	return nil, notFound(host)
+}
+
+func notFound(name string) error {
+	return &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+// tempFail — ошибка DNS, после которой запрос имеет смысл повторить
+func tempFail(name string) error {
+	return &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func newTestVerifier(r stubResolver) *Verifier {
+	return NewVerifier(r, config.DNSConfig{})
+}
+
+func TestCheckSPF(t *testing.T) {
+	r := stubResolver{
+		txt: map[string][]string{
+			"example.com":      {"v=spf1 ip4:192.0.2.0/24 -all"},
+			"helo.example.net": {"v=spf1 ip4:192.0.2.10 -all"},
+		},
+		errs: map[string]error{
+			"broken.example": tempFail("broken.example"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		helo       string
+		mailFrom   string
+		wantResult string
+		wantDomain string
+	}{
+		{"pass", "192.0.2.10", "mx.example.com", "alice@example.com", domain.AuthPass, "example.com"},
+		{"fail", "198.51.100.1", "mx.example.com", "alice@example.com", domain.AuthFail, "example.com"},
+		{"none", "192.0.2.10", "mx.example.org", "bob@example.org", domain.AuthNone, "example.org"},
+		{"temperror", "192.0.2.10", "mx.broken.example", "bob@broken.example", domain.AuthTempError, "broken.example"},
+		{"null sender uses helo", "192.0.2.10", "helo.example.net", "", domain.AuthPass, "helo.example.net"},
+	}
+
+	v := newTestVerifier(r)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.checkSPF(context.Background(), Input{
+				IP:       net.ParseIP(tt.ip),
+				Helo:     tt.helo,
+				MailFrom: tt.mailFrom,
+			})
+			if got.Result != tt.wantResult {
+				t.Errorf("Result = %q, want %q", got.Result, tt.wantResult)
+			}
+			if got.Domain != tt.wantDomain {
+				t.Errorf("Domain = %q, want %q", got.Domain, tt.wantDomain)
+			}
+			if got.IP != tt.ip {
+				t.Errorf("IP = %q, want %q", got.IP, tt.ip)
+			}
+		})
+	}
+}
+
+// signedMessage подписывает письмо ключом селектора s1 домена d
+// и возвращает его вместе с DNS-записью открытого ключа
+func signedMessage(t *testing.T, d, from string) ([]byte, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := "From: " + from + "\r\n" +
+		"To: box@tempmail.dev\r\n" +
+		"Subject: test\r\n" +
+		"\r\n" +
+		"Hello\r\n"
+
+	var signed bytes.Buffer
+	err = dkim.Sign(&signed, strings.NewReader(msg), &dkim.SignOptions{
+		Domain:   d,
+		Selector: "s1",
+		Signer:   priv,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed.Bytes(), "v=DKIM1; k=ed25519; p=" + base64.StdEncoding.EncodeToString(pub)
+}
+
+func TestCheckDKIM(t *testing.T) {
+	raw, key := signedMessage(t, "example.com", "alice@example.com")
+
+	tests := []struct {
+		name       string
+		raw        []byte
+		resolver   stubResolver
+		wantResult string // Пусто — подписей нет
+	}{
+		{
+			name:       "pass",
+			raw:        raw,
+			resolver:   stubResolver{txt: map[string][]string{"s1._domainkey.example.com": {key}}},
+			wantResult: domain.AuthPass,
+		},
+		{
+			name:       "body changed",
+			raw:        bytes.Replace(raw, []byte("Hello"), []byte("Hellx"), 1),
+			resolver:   stubResolver{txt: map[string][]string{"s1._domainkey.example.com": {key}}},
+			wantResult: domain.AuthFail,
+		},
+		{
+			name:       "key not published",
+			raw:        raw,
+			resolver:   stubResolver{},
+			wantResult: domain.AuthPermError,
+		},
+		{
+			name:       "dns temperror",
+			raw:        raw,
+			resolver:   stubResolver{errs: map[string]error{"s1._domainkey.example.com": tempFail("s1._domainkey.example.com")}},
+			wantResult: domain.AuthTempError,
+		},
+		{
+			name:     "unsigned",
+			raw:      []byte("From: alice@example.com\r\nSubject: test\r\n\r\nHello\r\n"),
+			resolver: stubResolver{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := newTestVerifier(tt.resolver).checkDKIM(context.Background(), tt.raw)
+			if tt.wantResult == "" {
+				if len(got) != 0 {
+					t.Fatalf("got %d results, want none: %+v", len(got), got)
+				}
+				return
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d results, want 1: %+v", len(got), got)
+			}
+			if got[0].Result != tt.wantResult {
+				t.Errorf("Result = %q, want %q (%s)", got[0].Result, tt.wantResult, got[0].Error)
+			}
+			if got[0].Domain != "example.com" {
+				t.Errorf("Domain = %q, want example.com", got[0].Domain)
+			}
+		})
+	}
+}
+
+func TestCheckDMARC(t *testing.T) {
+	r := stubResolver{
+		txt: map[string][]string{
+			"_dmarc.example.com": {"v=DMARC1; p=reject"},
+			"_dmarc.strict.org":  {"v=DMARC1; p=quarantine; adkim=s; aspf=s"},
+		},
+		errs: map[string]error{
+			"_dmarc.broken.example": tempFail("_dmarc.broken.example"),
+		},
+	}
+
+	spfPass := func(d string) domain.SPFResult { return domain.SPFResult{Result: domain.AuthPass, Domain: d} }
+	dkimPass := func(d string) []domain.DKIMResult { return []domain.DKIMResult{{Result: domain.AuthPass, Domain: d}} }
+
+	tests := []struct {
+		name            string
+		from            string
+		spf             domain.SPFResult
+		dkim            []domain.DKIMResult
+		wantResult      string
+		wantPolicy      string
+		wantSPFAligned  bool
+		wantDKIMAligned bool
+	}{
+		{
+			name: "relaxed spf subdomain", from: "example.com",
+			spf: spfPass("bounce.example.com"), wantResult: domain.AuthPass, wantPolicy: "reject", wantSPFAligned: true,
+		},
+		{
+			name: "relaxed dkim subdomain", from: "example.com",
+			spf: domain.SPFResult{Result: domain.AuthFail, Domain: "example.com"}, dkim: dkimPass("mail.example.com"),
+			wantResult: domain.AuthPass, wantPolicy: "reject", wantDKIMAligned: true,
+		},
+		{
+			name: "unrelated domains", from: "example.com",
+			spf: spfPass("other.net"), dkim: dkimPass("other.net"), wantResult: domain.AuthFail, wantPolicy: "reject",
+		},
+		{
+			name: "strict rejects subdomain", from: "strict.org",
+			spf: spfPass("bounce.strict.org"), dkim: dkimPass("mail.strict.org"), wantResult: domain.AuthFail, wantPolicy: "quarantine",
+		},
+		{
+			name: "strict exact match", from: "strict.org",
+			dkim: dkimPass("strict.org"), wantResult: domain.AuthPass, wantPolicy: "quarantine", wantDKIMAligned: true,
+		},
+		{
+			name: "organizational domain fallback", from: "news.example.com",
+			dkim: dkimPass("example.com"), wantResult: domain.AuthPass, wantPolicy: "reject", wantDKIMAligned: true,
+		},
+		{
+			name: "no policy", from: "example.org",
+			spf: spfPass("example.org"), wantResult: domain.AuthNone,
+		},
+		{
+			name: "dns temperror", from: "broken.example",
+			spf: spfPass("broken.example"), wantResult: domain.AuthTempError,
+		},
+		{
+			name: "no from domain", from: "",
+			wantResult: domain.AuthNone,
+		},
+	}
+
+	v := newTestVerifier(r)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := v.checkDMARC(context.Background(), tt.from, tt.spf, tt.dkim)
+			if got.Result != tt.wantResult {
+				t.Errorf("Result = %q, want %q", got.Result, tt.wantResult)
+			}
+			if got.Policy != tt.wantPolicy {
+				t.Errorf("Policy = %q, want %q", got.Policy, tt.wantPolicy)
+			}
+			if got.SPFAligned != tt.wantSPFAligned || got.DKIMAligned != tt.wantDKIMAligned {
+				t.Errorf("aligned spf=%v dkim=%v, want spf=%v dkim=%v",
+					got.SPFAligned, got.DKIMAligned, tt.wantSPFAligned, tt.wantDKIMAligned)
+			}
+		})
+	}
+}
+
+// TestVerify проверяет письмо целиком: подпись домена From даёт DMARC pass
+// даже при SPF fail (письмо пришло через пересылку)
+func TestVerify(t *testing.T) {
+	raw, key := signedMessage(t, "example.com", "Alice <alice@example.com>")
+	r := stubResolver{txt: map[string][]string{
+		"example.com":               {"v=spf1 ip4:192.0.2.0/24 -all"},
+		"s1._domainkey.example.com": {key},
+		"_dmarc.example.com":        {"v=DMARC1; p=reject"},
+	}}
+
+	auth := newTestVerifier(r).Verify(context.Background(), Input{
+		IP:       net.ParseIP("203.0.113.5"),
+		Helo:     "forwarder.example.net",
+		MailFrom: "alice@example.com",
+		Raw:      raw,
+	})
+
+	if auth.SPF.Result != domain.AuthFail {
+		t.Errorf("SPF = %q, want fail", auth.SPF.Result)
+	}
+	if len(auth.DKIM) != 1 || auth.DKIM[0].Result != domain.AuthPass {
+		t.Errorf("DKIM = %+v, want one pass", auth.DKIM)
+	}
+	if auth.DMARC.Result != domain.AuthPass || auth.DMARC.Domain != "example.com" || !auth.DMARC.DKIMAligned {
+		t.Errorf("DMARC = %+v, want pass aligned by DKIM for example.com", auth.DMARC)
+	}
+}
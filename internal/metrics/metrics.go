@@ -0,0 +1,367 @@
+// Package metrics собирает метрики сервиса в формате Prometheus
+//
+// Metrics создаётся один раз в main и передаётся компонентам явно.
+// nil-значение допустимо: методы ничего не делают, поэтому компоненты
+// можно создавать и без метрик.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"tempmail/internal/domain"
+)
+
+// namespace — общий префикс имён метрик
+const namespace = "tempmail"
+
+// Причины отказа, которыми помечаются метрики SMTP
+const (
+	ReasonDeniedIP       = "denied_ip"       // IP в SMTP_DENY_IPS
+	ReasonTooManyConns   = "too_many_conns"  // Лимит одновременных соединений
+	ReasonConnRate       = "conn_rate"       // Лимит новых соединений
+	ReasonEarlyTalker    = "early_talker"    // Команда до приветствия
+	ReasonDNSBL          = "dnsbl"           // IP в чёрном списке
+	ReasonAuthRequired   = "auth_required"   // Submission-порт без входа
+	ReasonSenderMismatch = "sender_mismatch" // Отправитель не совпадает с ящиком
+	ReasonRateLimited    = "rate_limited"    // Лимит писем
+	ReasonRelayDenied    = "relay_denied"    // Чужой домен
+	ReasonUnknownMailbox = "unknown_mailbox" // Ящика нет
+	ReasonLookupError    = "lookup_error"    // Ошибка поиска ящика
+	ReasonGreylisted     = "greylisted"      // Отложено грейлистингом
+)
+
+// Метка listener у метрик SMTP-сессий
+const (
+	ListenerMX         = "mx"         // Приём почты из интернета
+	ListenerSubmission = "submission" // Отправка владельцами ящиков
+)
+
+// Recorder — хранилище постоянных счётчиков статистики (domain.Stat*)
+// Через Metrics в него попадают события SMTP; события сервисов
+// хранилище получает само как service.Observer
+type Recorder interface {
+	Add(name string, delta int64)
+}
+
+// Metrics — реестр метрик сервиса
+type Metrics struct {
+	registry *prometheus.Registry
+	recorder Recorder // Постоянная статистика (nil — только Prometheus)
+
+	smtpSessions        *prometheus.CounterVec
+	smtpActiveSessions  *prometheus.GaugeVec
+	smtpSessionDuration *prometheus.HistogramVec
+	smtpConnsRejected   *prometheus.CounterVec
+	smtpSenders         *prometheus.CounterVec
+	smtpRecipients      *prometheus.CounterVec
+	smtpMessageSize     prometheus.Histogram
+	smtpParseFailures   prometheus.Counter
+	smtpAuthFailures    prometheus.Counter
+
+	messagesStored   *prometheus.CounterVec
+	messagesDeleted  prometheus.Counter
+	mailboxesCreated prometheus.Counter
+	mailboxesDeleted prometheus.Counter
+
+	cleanupRuns      *prometheus.CounterVec
+	cleanupDuration  prometheus.Histogram
+	cleanupMailboxes *prometheus.CounterVec
+	cleanupLast      prometheus.Gauge
+
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+}
+
+// New создаёт реестр со всеми метриками сервиса,
+// а также стандартными метриками процесса и среды Go
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		smtpSessions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "sessions_total",
+			Help: "SMTP-сессии, дошедшие до HELO/EHLO; STARTTLS новой сессии не начинает",
+		}, []string{"listener"}),
+		smtpActiveSessions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "active_sessions",
+			Help: "Открытые SMTP-сессии",
+		}, []string{"listener"}),
+		smtpSessionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "session_duration_seconds",
+			Help:    "Длительность SMTP-сессий: от первого HELO/EHLO до закрытия соединения",
+			Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+		}, []string{"listener"}),
+		smtpConnsRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "connections_rejected_total",
+			Help: "Отклонённые SMTP-соединения по причинам",
+		}, []string{"reason"}),
+		smtpSenders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "senders_total",
+			Help: "Команды MAIL FROM: принятые и отклонённые по причинам",
+		}, []string{"result", "reason"}),
+		smtpRecipients: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "recipients_total",
+			Help: "Команды RCPT TO: принятые и отклонённые по причинам",
+		}, []string{"result", "reason"}),
+		smtpMessageSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "message_size_bytes",
+			Help:    "Размер принятых писем",
+			Buckets: prometheus.ExponentialBuckets(1024, 4, 8), // 1 КБ … 16 МБ
+		}),
+		smtpParseFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "parse_failures_total",
+			Help: "Письма, которые не удалось разобрать",
+		}),
+		smtpAuthFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "smtp", Name: "auth_failures_total",
+			Help: "Неудачные попытки входа на submission-порт",
+		}),
+
+		messagesStored: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Name: "messages_stored_total",
+			Help: "Сохранённые письма (по копии на получателя)",
+		}, []string{"spam"}),
+		messagesDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "messages_deleted_total",
+			Help: "Письма, удалённые владельцами",
+		}),
+		mailboxesCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "mailboxes_created_total",
+			Help: "Созданные ящики",
+		}),
+		mailboxesDeleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace, Name: "mailboxes_deleted_total",
+			Help: "Ящики, удалённые владельцами и администратором",
+		}),
+
+		cleanupRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cleanup", Name: "runs_total",
+			Help: "Запуски очистки истёкших ящиков",
+		}, []string{"result"}),
+		cleanupDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "cleanup", Name: "duration_seconds",
+			Help:    "Длительность очистки истёкших ящиков",
+			Buckets: prometheus.DefBuckets,
+		}),
+		cleanupMailboxes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace, Subsystem: "cleanup", Name: "mailboxes_total",
+			Help: "Ящики, деактивированные и удалённые очисткой",
+		}, []string{"action"}),
+		cleanupLast: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace, Subsystem: "cleanup", Name: "last_success_timestamp_seconds",
+			Help: "Время последней успешной очистки (Unix)",
+		}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+			Help:    "Время обработки HTTP-запросов по маршрутам",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace, Subsystem: "db", Name: "query_duration_seconds",
+			Help:    "Время выполнения запросов к БД",
+			Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+		}, []string{"operation", "table"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+
+		m.smtpSessions, m.smtpActiveSessions, m.smtpSessionDuration,
+		m.smtpConnsRejected, m.smtpSenders, m.smtpRecipients,
+		m.smtpMessageSize, m.smtpParseFailures, m.smtpAuthFailures,
+		m.messagesStored, m.messagesDeleted, m.mailboxesCreated, m.mailboxesDeleted,
+		m.cleanupRuns, m.cleanupDuration, m.cleanupMailboxes, m.cleanupLast,
+		m.httpDuration, m.dbDuration,
+	)
+	return m
+}
+
+// SetRecorder включает запись постоянных счётчиков в r
+// Вызывается в main до запуска серверов
+func (m *Metrics) SetRecorder(r Recorder) {
+	if m == nil {
+		return
+	}
+	m.recorder = r
+}
+
+// record прибавляет delta к постоянному счётчику name
+func (m *Metrics) record(name string, delta int64) {
+	if m.recorder != nil && delta != 0 {
+		m.recorder.Add(name, delta)
+	}
+}
+
+// Handler возвращает HTTP-обработчик /metrics
+func (m *Metrics) Handler() http.Handler {
+	if m == nil {
+		return http.NotFoundHandler()
+	}
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// SMTPSessionStarted учитывает начало SMTP-сессии
+func (m *Metrics) SMTPSessionStarted(listener string) {
+	if m == nil {
+		return
+	}
+	m.smtpSessions.WithLabelValues(listener).Inc()
+	m.smtpActiveSessions.WithLabelValues(listener).Inc()
+	m.record(domain.StatSMTPSessions, 1)
+}
+
+// SMTPSessionEnded учитывает завершение SMTP-сессии
+func (m *Metrics) SMTPSessionEnded(listener string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.smtpActiveSessions.WithLabelValues(listener).Dec()
+	m.smtpSessionDuration.WithLabelValues(listener).Observe(duration.Seconds())
+}
+
+// SMTPConnectionRejected учитывает отклонённое соединение
+func (m *Metrics) SMTPConnectionRejected(reason string) {
+	if m == nil {
+		return
+	}
+	m.smtpConnsRejected.WithLabelValues(reason).Inc()
+	if reason == ReasonEarlyTalker {
+		m.record(domain.StatEarlyTalkers, 1)
+	} else {
+		m.record(domain.StatConnectionsRejected, 1)
+	}
+}
+
+// SMTPSender учитывает команду MAIL FROM; пустая причина — команда принята
+func (m *Metrics) SMTPSender(reason string) {
+	if m == nil {
+		return
+	}
+	m.smtpSenders.WithLabelValues(result(reason), reason).Inc()
+	if reason == ReasonRateLimited {
+		m.record(domain.StatRateLimited, 1)
+	}
+}
+
+// SMTPRecipient учитывает команду RCPT TO; пустая причина — получатель принят
+func (m *Metrics) SMTPRecipient(reason string) {
+	if m == nil {
+		return
+	}
+	m.smtpRecipients.WithLabelValues(result(reason), reason).Inc()
+	switch reason {
+	case "":
+		m.record(domain.StatRecipientsAccepted, 1)
+		return
+	case ReasonRateLimited:
+		m.record(domain.StatRateLimited, 1)
+	case ReasonGreylisted:
+		m.record(domain.StatGreylisted, 1)
+	}
+	m.record(domain.StatRecipientsRejected, 1)
+}
+
+// SMTPMessageReceived учитывает принятое письмо
+func (m *Metrics) SMTPMessageReceived(size int) {
+	if m == nil {
+		return
+	}
+	m.smtpMessageSize.Observe(float64(size))
+}
+
+// SMTPParseFailure учитывает письмо, которое не удалось разобрать
+func (m *Metrics) SMTPParseFailure() {
+	if m == nil {
+		return
+	}
+	m.smtpParseFailures.Inc()
+	m.record(domain.StatParseFailures, 1)
+}
+
+// SMTPAuthFailure учитывает неудачный вход на submission-порт
+func (m *Metrics) SMTPAuthFailure() {
+	if m == nil {
+		return
+	}
+	m.smtpAuthFailures.Inc()
+}
+
+// MessageStored учитывает сохранённое письмо
+func (m *Metrics) MessageStored(spam bool) {
+	if m == nil {
+		return
+	}
+	m.messagesStored.WithLabelValues(strconv.FormatBool(spam)).Inc()
+}
+
+// MailboxCreated учитывает созданный ящик
+func (m *Metrics) MailboxCreated() {
+	if m == nil {
+		return
+	}
+	m.mailboxesCreated.Inc()
+}
+
+// MessageDeleted учитывает письмо, удалённое владельцем
+func (m *Metrics) MessageDeleted() {
+	if m == nil {
+		return
+	}
+	m.messagesDeleted.Inc()
+}
+
+// MailboxDeleted учитывает ящик, удалённый владельцем или администратором
+func (m *Metrics) MailboxDeleted() {
+	if m == nil {
+		return
+	}
+	m.mailboxesDeleted.Inc()
+}
+
+// CleanupFinished учитывает запуск очистки истёкших ящиков
+func (m *Metrics) CleanupFinished(duration time.Duration, deactivated, deleted int64, err error) {
+	if m == nil {
+		return
+	}
+	m.cleanupDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.cleanupRuns.WithLabelValues("error").Inc()
+		return
+	}
+	m.cleanupRuns.WithLabelValues("ok").Inc()
+	m.cleanupMailboxes.WithLabelValues("deactivated").Add(float64(deactivated))
+	m.cleanupMailboxes.WithLabelValues("deleted").Add(float64(deleted))
+	m.cleanupLast.SetToCurrentTime()
+}
+
+// ObserveHTTP учитывает HTTP-запрос; route — шаблон маршрута, а не путь
+func (m *Metrics) ObserveHTTP(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+}
+
+// ObserveDB учитывает запрос к БД
+func (m *Metrics) ObserveDB(operation, table string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.dbDuration.WithLabelValues(operation, table).Observe(duration.Seconds())
+}
+
+// result превращает причину отказа в значение метки result
+func result(reason string) string {
+	if reason == "" {
+		return "accepted"
+	}
+	return "rejected"
+}
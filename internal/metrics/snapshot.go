@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Snapshot — текущее состояние процесса для /stats
+// Накопительные счётчики /stats берутся из постоянной статистики (Recorder)
+type Snapshot struct {
+	LastCleanup        time.Time // Последняя успешная очистка (нулевое — ещё не было)
+	ActiveSMTPSessions int64     // Открытых SMTP-сессий
+}
+
+// Snapshot собирает сводку из реестра
+func (m *Metrics) Snapshot() (Snapshot, error) {
+	if m == nil {
+		return Snapshot{}, nil
+	}
+	families, err := m.registry.Gather()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	sum := func(name string, labels ...string) int64 {
+		return int64(sumFamily(byName[namespace+"_"+name], labels...))
+	}
+
+	snapshot := Snapshot{
+		ActiveSMTPSessions: sum("smtp_active_sessions"),
+	}
+	if last := sum("cleanup_last_success_timestamp_seconds"); last > 0 {
+		snapshot.LastCleanup = time.Unix(last, 0)
+	}
+	return snapshot, nil
+}
+
+// sumFamily складывает значения счётчиков и датчиков семейства
+// labels — пары имя/значение, которым должна соответствовать метрика
+func sumFamily(family *dto.MetricFamily, labels ...string) float64 {
+	if family == nil {
+		return 0
+	}
+
+	var total float64
+	for _, metric := range family.GetMetric() {
+		if !hasLabels(metric, labels) {
+			continue
+		}
+		switch {
+		case metric.GetCounter() != nil:
+			total += metric.GetCounter().GetValue()
+		case metric.GetGauge() != nil:
+			total += metric.GetGauge().GetValue()
+		}
+	}
+	return total
+}
+
+// hasLabels проверяет, что у метрики есть все метки из пар имя/значение
+func hasLabels(metric *dto.Metric, labels []string) bool {
+	for i := 0; i+1 < len(labels); i += 2 {
+		found := false
+		for _, pair := range metric.GetLabel() {
+			if pair.GetName() == labels[i] && pair.GetValue() == labels[i+1] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
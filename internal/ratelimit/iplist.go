@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPList — список адресов и подсетей
+type IPList []*net.IPNet
+
+// ParseIPList разбирает список из отдельных адресов (192.0.2.1) и подсетей (192.0.2.0/24)
+func ParseIPList(items []string) (IPList, error) {
+	list := make(IPList, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		// Отдельный адрес превращаем в подсеть из одного адреса
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("некорректный IP-адрес: %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			list = append(list, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная подсеть: %q", item)
+		}
+		list = append(list, network)
+	}
+	return list, nil
+}
+
+// Contains проверяет, входит ли адрес в список
+func (l IPList) Contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range l {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IPKey возвращает ключ счётчика для адреса клиента
+// Клиенту IPv6 обычно выдаётся целая подсеть /64, поэтому считаем по ней
+func IPKey(ip net.IP) string {
+	if ip == nil {
+		return "unknown"
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
@@ -0,0 +1,85 @@
+// Package ratelimit ограничивает частоту событий: соединений, писем, запросов
+// Счётчики хранятся в памяти процесса или в Redis (см. Store)
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"tempmail/internal/config"
+)
+
+// Result — решение ограничителя для одного события
+type Result struct {
+	Allowed    bool          // Событие разрешено
+	Limit      int           // Сколько событий разрешено за окно
+	Remaining  int           // Сколько ещё осталось в текущем окне
+	Reset      time.Duration // Через сколько начнётся следующее окно
+	RetryAfter time.Duration // Через сколько повторить, если событие запрещено
+}
+
+// Limiter — ограничитель частоты со скользящим окном
+//
+// Окно приближается двумя соседними фиксированными окнами: счётчик
+// предыдущего окна учитывается с весом, пропорциональным той его части,
+// которая ещё попадает в скользящее окно. Так не нужно хранить время
+// каждого события, а всплеск на границе окон не удваивает лимит.
+type Limiter struct {
+	store Store       // Хранилище счётчиков
+	name  string      // Имя ограничителя — префикс ключей
+	rate  config.Rate // Лимит и длина окна
+}
+
+// New создаёт ограничитель; name отделяет его счётчики от счётчиков других ограничителей
+func New(store Store, name string, rate config.Rate) *Limiter {
+	return &Limiter{store: store, name: name, rate: rate}
+}
+
+// Rate возвращает настроенное ограничение
+func (l *Limiter) Rate() config.Rate {
+	return l.rate
+}
+
+// Allow учитывает событие для ключа key и сообщает, укладывается ли оно в лимит
+// Выключенный ограничитель разрешает всё
+func (l *Limiter) Allow(ctx context.Context, key string) (Result, error) {
+	if l == nil || !l.rate.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := time.Now()
+	window := l.rate.Window
+	index := now.UnixNano() / int64(window)
+	elapsed := time.Duration(now.UnixNano() - index*int64(window))
+
+	// Счётчик живёт два окна: в следующем окне он ещё нужен как предыдущий
+	previous, err := l.store.Get(ctx, l.key(key, index-1))
+	if err != nil {
+		return Result{}, err
+	}
+	current, err := l.store.Incr(ctx, l.key(key, index), 2*window)
+	if err != nil {
+		return Result{}, err
+	}
+
+	weight := 1 - float64(elapsed)/float64(window)
+	estimate := float64(previous)*weight + float64(current)
+
+	result := Result{
+		Allowed:   estimate <= float64(l.rate.Limit),
+		Limit:     l.rate.Limit,
+		Remaining: max(0, l.rate.Limit-int(math.Ceil(estimate))),
+		Reset:     window - elapsed,
+	}
+	if !result.Allowed {
+		result.RetryAfter = result.Reset
+	}
+	return result, nil
+}
+
+// key формирует ключ счётчика для одного фиксированного окна
+func (l *Limiter) key(key string, index int64) string {
+	return fmt.Sprintf("ratelimit:%s:%s:%d", l.name, key, index)
+}
@@ -0,0 +1,141 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"tempmail/internal/config"
+)
+
+// Store — хранилище счётчиков
+type Store interface {
+	// Incr увеличивает счётчик на единицу и возвращает новое значение
+	// Счётчик удаляется через ttl после последнего увеличения
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+
+	// Get возвращает значение счётчика; отсутствующий счётчик равен нулю
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+// NewStore создаёт хранилище, выбранное в конфигурации
+// client нужен только для хранилища redis
+func NewStore(cfg config.RateLimitConfig, client *redis.Client) (Store, error) {
+	switch cfg.Store {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "redis":
+		if client == nil {
+			return nil, errors.New("хранилище счётчиков redis требует подключения к Redis")
+		}
+		return NewRedisStore(client), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище счётчиков: %q", cfg.Store)
+	}
+}
+
+// sweepInterval — как часто MemoryStore удаляет устаревшие счётчики
+const sweepInterval = time.Minute
+
+// memoryCounter — счётчик в памяти
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// MemoryStore хранит счётчики в памяти процесса
+// Подходит для одного экземпляра сервиса
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	lastSweep time.Time
+}
+
+// NewMemoryStore создаёт хранилище в памяти
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]memoryCounter),
+		lastSweep: time.Now(),
+	}
+}
+
+// Incr увеличивает счётчик в памяти
+func (m *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	counter := m.counters[key]
+	if now.After(counter.expires) {
+		counter.value = 0
+	}
+	counter.value++
+	counter.expires = now.Add(ttl)
+	m.counters[key] = counter
+
+	return counter.value, nil
+}
+
+// Get возвращает значение счётчика в памяти
+func (m *MemoryStore) Get(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counter, ok := m.counters[key]
+	if !ok || time.Now().After(counter.expires) {
+		return 0, nil
+	}
+	return counter.value, nil
+}
+
+// sweep удаляет устаревшие счётчики, чтобы карта не росла бесконечно
+// Вызывается под мьютексом
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, counter := range m.counters {
+		if now.After(counter.expires) {
+			delete(m.counters, key)
+		}
+	}
+}
+
+// RedisStore хранит счётчики в Redis
+// Лимиты общие для всех экземпляров сервиса
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore создаёт хранилище поверх готового клиента Redis
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Incr увеличивает счётчик в Redis и продлевает его срок жизни
+func (r *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.PExpire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Get возвращает значение счётчика из Redis
+func (r *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	value, err := r.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return value, err
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// conditions собирает условие WHERE из необязательных фильтров
+// Знак ? в условии заменяется на очередной плейсхолдер ($1, $2, ...)
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+// add добавляет условие с одним параметром
+func (c *conditions) add(clause string, arg any) {
+	c.clauses = append(c.clauses, strings.ReplaceAll(clause, "?", c.param(arg)))
+}
+
+// param добавляет параметр и возвращает его плейсхолдер
+func (c *conditions) param(arg any) string {
+	c.args = append(c.args, arg)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// where возвращает WHERE со всеми условиями или пустую строку
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(c.clauses, " AND ")
+}
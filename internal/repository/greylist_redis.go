@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"tempmail/internal/domain"
+)
+
+// RedisGreylist хранит триплеты грейлистинга в Redis
+// Устаревшие записи Redis удаляет сам по сроку жизни ключей
+type RedisGreylist struct {
+	client *redis.Client
+}
+
+// NewRedisGreylist создаёт хранилище поверх готового клиента Redis
+func NewRedisGreylist(client *redis.Client) *RedisGreylist {
+	return &RedisGreylist{client: client}
+}
+
+// tripletKey возвращает ключ триплета
+func tripletKey(t domain.GreylistTriplet) string {
+	return "greylist:triplet:" + t.Network + ":" + t.Sender + ":" + t.Recipient
+}
+
+// whitelistKey возвращает ключ записи белого списка
+func whitelistKey(network string) string {
+	return "greylist:whitelist:" + network
+}
+
+// Attempt регистрирует попытку доставки и возвращает время первой попытки
+// и признак того, что триплет уже прошёл грейлистинг
+func (r *RedisGreylist) Attempt(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) (time.Time, bool, error) {
+	key := tripletKey(t)
+
+	// Запись, срок жизни и чтение — одна транзакция MULTI: ключ не может
+	// остаться без срока, если соединение оборвётся между командами.
+	// EXPIRE NX (Redis 7+) задаёт срок только новой записи, чтобы повторы его не продлевали
+	var read *redis.SliceCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSetNX(ctx, key, "first_seen", time.Now().Unix())
+		pipe.ExpireNX(ctx, key, ttl)
+		read = pipe.HMGet(ctx, key, "first_seen", "passed")
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	fields := read.Val()
+	firstSeen, ok := fields[0].(string)
+	if !ok {
+		return time.Time{}, false, errors.New("запись грейлистинга удалена во время проверки")
+	}
+	seconds, err := strconv.ParseInt(firstSeen, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	passed := fields[1] == "1"
+	return time.Unix(seconds, 0), passed, nil
+}
+
+// Pass отмечает триплет как прошедший; запись живёт ещё ttl
+func (r *RedisGreylist) Pass(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) error {
+	key := tripletKey(t)
+
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, "passed", "1")
+	pipe.Expire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Whitelist добавляет сеть в белый список на ttl
+func (r *RedisGreylist) Whitelist(ctx context.Context, network string, ttl time.Duration) error {
+	return r.client.Set(ctx, whitelistKey(network), 1, ttl).Err()
+}
+
+// Whitelisted проверяет, находится ли сеть в белом списке
+func (r *RedisGreylist) Whitelisted(ctx context.Context, network string) (bool, error) {
+	count, err := r.client.Exists(ctx, whitelistKey(network)).Result()
+	return count > 0, err
+}
+
+// DeleteExpired ничего не делает: ключи удаляются по сроку жизни
+func (r *RedisGreylist) DeleteExpired(ctx context.Context) (int64, error) {
+	return 0, nil
+}
@@ -1,14 +1,47 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	"tempmail/internal/domain"
 )
 
+// mailboxColumns — список колонок ящика в порядке, который ожидает scanMailbox
+const mailboxColumns = `id, address, created_at, expires_at, is_active, is_wildcard, max_expires_at, auto_extend_seconds, token_hash, api_key_id`
+
+// scanMailbox читает ящик из строки результата
+func scanMailbox(row rowScanner) (*domain.Mailbox, error) {
+	mailbox := &domain.Mailbox{}
+	var autoExtendSeconds int64
+	var tokenHash, apiKeyID sql.NullString
+	err := row.Scan(
+		&mailbox.ID,
+		&mailbox.Address,
+		&mailbox.CreatedAt,
+		&mailbox.ExpiresAt,
+		&mailbox.IsActive,
+		&mailbox.IsWildcard,
+		&mailbox.MaxExpiresAt,
+		&autoExtendSeconds,
+		&tokenHash,
+		&apiKeyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// В БД длительность хранится в секундах
+	mailbox.AutoExtend = time.Duration(autoExtendSeconds) * time.Second
+	// У ящиков, созданных до появления токенов, хеша нет
+	mailbox.TokenHash = tokenHash.String
+	mailbox.APIKeyID = apiKeyID.String
+	return mailbox, nil
+}
+
 // MailboxRepository — репозиторий для работы с почтовыми ящиками
 type MailboxRepository struct {
 	db *sql.DB // Подключение к базе данных
@@ -19,61 +52,59 @@ func NewMailboxRepository(db *sql.DB) *MailboxRepository {
 	return &MailboxRepository{db: db}
 }
 
-// Create создаёт новый почтовый ящик
-func (r *MailboxRepository) Create(address string, ttl time.Duration) (*domain.Mailbox, error) {
+// Create сохраняет новый почтовый ящик
+// ID и дата создания заполняются, если не заданы
+// Если адрес уже занят, возвращается ErrDuplicate
+func (r *MailboxRepository) Create(ctx context.Context, mailbox *domain.Mailbox) error {
 	// Генерируем уникальный ID
-	id := uuid.New().String()
-
-	// Вычисляем время истечения
-	now := time.Now()
-	expiresAt := now.Add(ttl)
+	if mailbox.ID == "" {
+		mailbox.ID = uuid.New().String()
+	}
+	if mailbox.CreatedAt.IsZero() {
+		mailbox.CreatedAt = time.Now()
+	}
 
 	// SQL-запрос для вставки записи
-	// $1, $2, $3, $4 — это плейсхолдеры для параметров
+	// $1, $2, ... — это плейсхолдеры для параметров
 	// Они защищают от SQL-инъекций
 	query := `
-        INSERT INTO mailboxes (id, address, created_at, expires_at, is_active)
-        VALUES ($1, $2, $3, $4, $5)
+        INSERT INTO mailboxes (id, address, created_at, expires_at, is_active, is_wildcard, max_expires_at, auto_extend_seconds, token_hash, api_key_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
     `
 
 	// Выполняем запрос
 	// Exec используется для запросов, которые не возвращают данные (INSERT, UPDATE, DELETE)
-	_, err := r.db.Exec(query, id, address, now, expiresAt, true)
-	if err != nil {
-		return nil, err
+	_, err := r.db.ExecContext(ctx, query,
+		mailbox.ID,
+		mailbox.Address,
+		mailbox.CreatedAt,
+		mailbox.ExpiresAt,
+		mailbox.IsActive,
+		mailbox.IsWildcard,
+		mailbox.MaxExpiresAt,
+		int64(mailbox.AutoExtend/time.Second),
+		sql.NullString{String: mailbox.TokenHash, Valid: mailbox.TokenHash != ""},
+		sql.NullString{String: mailbox.APIKeyID, Valid: mailbox.APIKeyID != ""},
+	)
+	if isUniqueViolation(err) {
+		// Адрес уже занят другим ящиком
+		return ErrDuplicate
 	}
-
-	// Возвращаем созданный ящик
-	return &domain.Mailbox{
-		ID:        id,
-		Address:   address,
-		CreatedAt: now,
-		ExpiresAt: expiresAt,
-		IsActive:  true,
-	}, nil
+	return err
 }
 
 // GetByID находит ящик по ID
-func (r *MailboxRepository) GetByID(id string) (*domain.Mailbox, error) {
+func (r *MailboxRepository) GetByID(ctx context.Context, id string) (*domain.Mailbox, error) {
 	// SQL-запрос для выборки одной записи
 	query := `
-        SELECT id, address, created_at, expires_at, is_active
+        SELECT ` + mailboxColumns + `
         FROM mailboxes
         WHERE id = $1
     `
 
-	// Создаём пустую структуру для результата
-	mailbox := &domain.Mailbox{}
-
 	// QueryRow выполняет запрос и возвращает одну строку
-	// Scan читает значения из строки в поля структуры
-	err := r.db.QueryRow(query, id).Scan(
-		&mailbox.ID,
-		&mailbox.Address,
-		&mailbox.CreatedAt,
-		&mailbox.ExpiresAt,
-		&mailbox.IsActive,
-	)
+	// scanMailbox читает значения из строки в поля структуры
+	mailbox, err := scanMailbox(r.db.QueryRowContext(ctx, query, id))
 
 	// Проверяем ошибки
 	if err == sql.ErrNoRows {
@@ -88,22 +119,14 @@ func (r *MailboxRepository) GetByID(id string) (*domain.Mailbox, error) {
 }
 
 // GetByAddress находит ящик по email-адресу
-func (r *MailboxRepository) GetByAddress(address string) (*domain.Mailbox, error) {
+func (r *MailboxRepository) GetByAddress(ctx context.Context, address string) (*domain.Mailbox, error) {
 	query := `
-        SELECT id, address, created_at, expires_at, is_active
+        SELECT ` + mailboxColumns + `
         FROM mailboxes
         WHERE address = $1 AND is_active = true
     `
 
-	mailbox := &domain.Mailbox{}
-	err := r.db.QueryRow(query, address).Scan(
-		&mailbox.ID,
-		&mailbox.Address,
-		&mailbox.CreatedAt,
-		&mailbox.ExpiresAt,
-		&mailbox.IsActive,
-	)
-
+	mailbox, err := scanMailbox(r.db.QueryRowContext(ctx, query, address))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -114,19 +137,207 @@ func (r *MailboxRepository) GetByAddress(address string) (*domain.Mailbox, error
 	return mailbox, nil
 }
 
+// GetWildcardsByDomain возвращает активные ящики-шаблоны указанного домена
+// Сначала идут более старые ящики — при равной точности шаблона побеждает старший
+func (r *MailboxRepository) GetWildcardsByDomain(ctx context.Context, domainName string) ([]*domain.Mailbox, error) {
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        WHERE is_wildcard = true AND is_active = true
+          AND lower(split_part(address, '@', 2)) = lower($1)
+        ORDER BY created_at
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, domainName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mailbox, err := scanMailbox(rows)
+		if err != nil {
+			return nil, err
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mailboxes, nil
+}
+
+// ListByAPIKey возвращает ящики ключа, новые первыми
+// Ящики после льготного периода удаляются очисткой, поэтому в список не попадают
+func (r *MailboxRepository) ListByAPIKey(ctx context.Context, apiKeyID string) ([]*domain.Mailbox, error) {
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        WHERE api_key_id = $1
+        ORDER BY created_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mailbox, err := scanMailbox(rows)
+		if err != nil {
+			return nil, err
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mailboxes, nil
+}
+
+// CountCreatedSince возвращает, сколько ящиков ключ создал начиная с since
+func (r *MailboxRepository) CountCreatedSince(ctx context.Context, apiKeyID string, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM mailboxes WHERE api_key_id = $1 AND created_at >= $2`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, apiKeyID, since).Scan(&count)
+	return count, err
+}
+
+// CountActiveByAPIKey возвращает число активных ящиков ключа
+func (r *MailboxRepository) CountActiveByAPIKey(ctx context.Context, apiKeyID string) (int, error) {
+	query := `SELECT COUNT(*) FROM mailboxes WHERE api_key_id = $1 AND is_active = true AND expires_at > NOW()`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, apiKeyID).Scan(&count)
+	return count, err
+}
+
+// searchConditions превращает условия поиска ящиков в WHERE
+func searchConditions(filter domain.MailboxSearch) *conditions {
+	cond := &conditions{}
+	if len(filter.IDs) > 0 {
+		cond.add("id = ANY(?)", pq.Array(filter.IDs))
+	}
+	if filter.Query != "" {
+		cond.add("strpos(lower(address), lower(?)) > 0", filter.Query)
+	}
+	if filter.Active != nil {
+		cond.add("is_active = ?", *filter.Active)
+	}
+	if filter.APIKeyID != "" {
+		cond.add("api_key_id = ?", filter.APIKeyID)
+	}
+	return cond
+}
+
+// Search ищет ящики по всему сервису, новые первыми
+// Возвращает страницу ящиков и общее число подходящих ящиков
+func (r *MailboxRepository) Search(ctx context.Context, filter domain.MailboxSearch) ([]*domain.Mailbox, int, error) {
+	cond := searchConditions(filter)
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mailboxes `+cond.where(), cond.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        ` + cond.where() + `
+        ORDER BY created_at DESC
+        LIMIT ` + cond.param(filter.Limit) + ` OFFSET ` + cond.param(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, cond.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mailbox, err := scanMailbox(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return mailboxes, total, nil
+}
+
+// ExpireMatching досрочно завершает срок активных ящиков, подходящих под условия
+// Ящики переходят в льготный период, как при обычном истечении срока
+func (r *MailboxRepository) ExpireMatching(ctx context.Context, filter domain.MailboxSearch) (int64, error) {
+	ctx = Maintenance(ctx)
+	cond := searchConditions(filter)
+	cond.add("is_active = ?", true)
+
+	query := `UPDATE mailboxes SET is_active = false, expires_at = LEAST(expires_at, NOW()) ` + cond.where()
+
+	result, err := r.db.ExecContext(ctx, query, cond.args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// UpdateExpiresAt устанавливает новый срок действия ящика
+func (r *MailboxRepository) UpdateExpiresAt(ctx context.Context, id string, expiresAt time.Time) error {
+	query := `UPDATE mailboxes SET expires_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, expiresAt)
+	return err
+}
+
 // Delete удаляет почтовый ящик
-func (r *MailboxRepository) Delete(id string) error {
+func (r *MailboxRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM mailboxes WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
-// DeleteExpired удаляет все истёкшие ящики
-func (r *MailboxRepository) DeleteExpired() (int64, error) {
-	query := `DELETE FROM mailboxes WHERE expires_at < NOW()`
+// Restore снова активирует ящик с новым сроком действия
+func (r *MailboxRepository) Restore(ctx context.Context, id string, expiresAt time.Time) error {
+	query := `UPDATE mailboxes SET is_active = true, expires_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, expiresAt)
+	return err
+}
+
+// DeactivateExpired деактивирует ящики с истёкшим сроком
+// Письма остаются на месте до окончания льготного периода
+func (r *MailboxRepository) DeactivateExpired(ctx context.Context) (int64, error) {
+	ctx = Maintenance(ctx)
+	query := `UPDATE mailboxes SET is_active = false WHERE is_active = true AND expires_at < NOW()`
+
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteExpired удаляет ящики, срок которых истёк раньше before
+// Письма удаляются каскадно (ON DELETE CASCADE)
+func (r *MailboxRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx = Maintenance(ctx)
+	query := `DELETE FROM mailboxes WHERE expires_at < $1`
 
 	// Exec возвращает Result, из которого можно узнать количество затронутых строк
-	result, err := r.db.Exec(query)
+	result, err := r.db.ExecContext(ctx, query, before)
 	if err != nil {
 		return 0, err
 	}
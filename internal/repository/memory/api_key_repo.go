@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// storedAPIKey — ключ и порядок его добавления
+type storedAPIKey struct {
+	key domain.APIKey
+	seq int64
+}
+
+// APIKeyRepository — API-ключи в памяти
+type APIKeyRepository struct {
+	db *DB
+}
+
+// NewAPIKeyRepository создаёт новый репозиторий
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create сохраняет новый ключ
+// ID и дата создания заполняются, если не заданы
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.apiKeys[key.ID]; ok {
+		return repository.ErrDuplicate
+	}
+	for _, stored := range r.db.apiKeys {
+		if stored.key.KeyHash == key.KeyHash {
+			return repository.ErrDuplicate
+		}
+	}
+
+	// Счётчики использования нового ключа начинаются с нуля; сам ключ не хранится
+	stored := &storedAPIKey{key: *key, seq: r.db.next()}
+	stored.key.Key = ""
+	stored.key.LastUsedAt = nil
+	stored.key.Requests = 0
+	stored.key.MailboxesCreated = 0
+	r.db.apiKeys[key.ID] = stored
+	return nil
+}
+
+// GetByID находит ключ по ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.apiKeys[id]
+	if !ok {
+		return nil, nil
+	}
+	return cloneAPIKey(&stored.key), nil
+}
+
+// GetByHash находит ключ по SHA-256
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, stored := range r.db.apiKeys {
+		if stored.key.KeyHash == hash {
+			return cloneAPIKey(&stored.key), nil
+		}
+	}
+	return nil, nil
+}
+
+// List возвращает все ключи, новые первыми
+func (r *APIKeyRepository) List(ctx context.Context) ([]*domain.APIKey, error) {
+	r.db.mu.RLock()
+	found := make([]*storedAPIKey, 0, len(r.db.apiKeys))
+	for _, stored := range r.db.apiKeys {
+		found = append(found, &storedAPIKey{key: *cloneAPIKey(&stored.key), seq: stored.seq})
+	}
+	r.db.mu.RUnlock()
+
+	sortByTime(found, func(s *storedAPIKey) (time.Time, int64) {
+		return s.key.CreatedAt, s.seq
+	}, true)
+
+	var keys []*domain.APIKey
+	for _, stored := range found {
+		keys = append(keys, &stored.key)
+	}
+	return keys, nil
+}
+
+// Update сохраняет название, квоты и признак активности ключа
+func (r *APIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	r.update(key.ID, func(k *domain.APIKey) {
+		k.Name = key.Name
+		k.MaxMailboxesPerHour = key.MaxMailboxesPerHour
+		k.MaxActiveMailboxes = key.MaxActiveMailboxes
+		k.IsActive = key.IsActive
+	})
+	return nil
+}
+
+// Delete удаляет ключ; его ящики остаются, но становятся анонимными
+func (r *APIKeyRepository) Delete(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.apiKeys, id)
+	// Как ON DELETE SET NULL у mailboxes.api_key_id
+	for _, stored := range r.db.mailboxes {
+		if stored.mailbox.APIKeyID == id {
+			stored.mailbox.APIKeyID = ""
+		}
+	}
+	return nil
+}
+
+// RecordRequest учитывает запрос с ключом
+func (r *APIKeyRepository) RecordRequest(ctx context.Context, id string) error {
+	now := time.Now()
+	r.update(id, func(k *domain.APIKey) {
+		k.Requests++
+		k.LastUsedAt = &now
+	})
+	return nil
+}
+
+// RecordMailboxCreated учитывает созданный ящик
+func (r *APIKeyRepository) RecordMailboxCreated(ctx context.Context, id string) error {
+	r.update(id, func(k *domain.APIKey) {
+		k.MailboxesCreated++
+	})
+	return nil
+}
+
+// update меняет ключ с указанным ID, если он есть
+func (r *APIKeyRepository) update(id string, change func(*domain.APIKey)) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if stored, ok := r.db.apiKeys[id]; ok {
+		change(&stored.key)
+	}
+}
+
+// cloneAPIKey копирует ключ вместе с временем последнего запроса
+func cloneAPIKey(key *domain.APIKey) *domain.APIKey {
+	clone := *key
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		clone.LastUsedAt = &lastUsedAt
+	}
+	return &clone
+}
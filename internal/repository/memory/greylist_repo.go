@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// greylistEntry — состояние триплета грейлистинга
+type greylistEntry struct {
+	firstSeen time.Time
+	passed    bool
+	expiresAt time.Time
+}
+
+// GreylistRepository хранит триплеты грейлистинга в памяти
+type GreylistRepository struct {
+	db *DB
+}
+
+// NewGreylistRepository создаёт новый репозиторий
+func NewGreylistRepository(db *DB) *GreylistRepository {
+	return &GreylistRepository{db: db}
+}
+
+// Attempt регистрирует попытку доставки и возвращает время первой попытки
+// и признак того, что триплет уже прошёл грейлистинг
+// Устаревшая запись начинается заново, как будто её не было
+func (r *GreylistRepository) Attempt(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) (time.Time, bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := r.db.greylist[t]
+	if !ok || !entry.expiresAt.After(now) {
+		entry = &greylistEntry{firstSeen: now, expiresAt: now.Add(ttl)}
+		r.db.greylist[t] = entry
+	}
+	return entry.firstSeen, entry.passed, nil
+}
+
+// Pass отмечает триплет как прошедший; запись живёт ещё ttl
+func (r *GreylistRepository) Pass(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if entry, ok := r.db.greylist[t]; ok {
+		entry.passed = true
+		entry.expiresAt = time.Now().Add(ttl)
+	}
+	return nil
+}
+
+// Whitelist добавляет сеть в белый список на ttl
+func (r *GreylistRepository) Whitelist(ctx context.Context, network string, ttl time.Duration) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.whitelist[network] = time.Now().Add(ttl)
+	return nil
+}
+
+// Whitelisted проверяет, находится ли сеть в белом списке
+func (r *GreylistRepository) Whitelisted(ctx context.Context, network string) (bool, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	expiresAt, ok := r.db.whitelist[network]
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+// DeleteExpired удаляет устаревшие триплеты и записи белого списка
+func (r *GreylistRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for t, entry := range r.db.greylist {
+		if !entry.expiresAt.After(now) {
+			delete(r.db.greylist, t)
+			deleted++
+		}
+	}
+	for network, expiresAt := range r.db.whitelist {
+		if !expiresAt.After(now) {
+			delete(r.db.whitelist, network)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
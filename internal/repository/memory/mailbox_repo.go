@@ -0,0 +1,290 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// errUnknownAPIKey — ящик ссылается на несуществующий ключ (в PostgreSQL — нарушение внешнего ключа)
+var errUnknownAPIKey = errors.New("API-ключ ящика не найден")
+
+// storedMailbox — ящик и порядок его добавления
+type storedMailbox struct {
+	mailbox domain.Mailbox
+	seq     int64
+}
+
+// MailboxRepository — почтовые ящики в памяти
+type MailboxRepository struct {
+	db *DB
+}
+
+// NewMailboxRepository создаёт новый репозиторий
+func NewMailboxRepository(db *DB) *MailboxRepository {
+	return &MailboxRepository{db: db}
+}
+
+// Create сохраняет новый почтовый ящик
+// ID и дата создания заполняются, если не заданы
+// Если адрес или ID уже заняты, возвращается ErrDuplicate
+func (r *MailboxRepository) Create(ctx context.Context, mailbox *domain.Mailbox) error {
+	if mailbox.ID == "" {
+		mailbox.ID = uuid.New().String()
+	}
+	if mailbox.CreatedAt.IsZero() {
+		mailbox.CreatedAt = time.Now()
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.mailboxes[mailbox.ID]; ok {
+		return repository.ErrDuplicate
+	}
+	for _, stored := range r.db.mailboxes {
+		if stored.mailbox.Address == mailbox.Address {
+			return repository.ErrDuplicate
+		}
+	}
+	if mailbox.APIKeyID != "" {
+		if _, ok := r.db.apiKeys[mailbox.APIKeyID]; !ok {
+			return errUnknownAPIKey
+		}
+	}
+
+	// Токен хранится только хешем
+	stored := &storedMailbox{mailbox: *mailbox, seq: r.db.next()}
+	stored.mailbox.Token = ""
+	r.db.mailboxes[mailbox.ID] = stored
+	return nil
+}
+
+// GetByID находит ящик по ID
+func (r *MailboxRepository) GetByID(ctx context.Context, id string) (*domain.Mailbox, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.mailboxes[id]
+	if !ok {
+		return nil, nil
+	}
+	mailbox := stored.mailbox
+	return &mailbox, nil
+}
+
+// GetByAddress находит активный ящик по email-адресу
+func (r *MailboxRepository) GetByAddress(ctx context.Context, address string) (*domain.Mailbox, error) {
+	found := r.find(func(m *domain.Mailbox) bool {
+		return m.Address == address && m.IsActive
+	})
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return &found[0].mailbox, nil
+}
+
+// GetWildcardsByDomain возвращает активные ящики-шаблоны указанного домена
+// Сначала идут более старые ящики — при равной точности шаблона побеждает старший
+func (r *MailboxRepository) GetWildcardsByDomain(ctx context.Context, domainName string) ([]*domain.Mailbox, error) {
+	mailboxes := r.find(func(m *domain.Mailbox) bool {
+		_, host, _ := strings.Cut(m.Address, "@")
+		return m.IsWildcard && m.IsActive && strings.EqualFold(host, domainName)
+	})
+	return r.sorted(mailboxes, false), nil
+}
+
+// ListByAPIKey возвращает ящики ключа, новые первыми
+func (r *MailboxRepository) ListByAPIKey(ctx context.Context, apiKeyID string) ([]*domain.Mailbox, error) {
+	mailboxes := r.find(func(m *domain.Mailbox) bool {
+		return m.APIKeyID == apiKeyID
+	})
+	return r.sorted(mailboxes, true), nil
+}
+
+// CountCreatedSince возвращает, сколько ящиков ключ создал начиная с since
+func (r *MailboxRepository) CountCreatedSince(ctx context.Context, apiKeyID string, since time.Time) (int, error) {
+	mailboxes := r.find(func(m *domain.Mailbox) bool {
+		return m.APIKeyID == apiKeyID && !m.CreatedAt.Before(since)
+	})
+	return len(mailboxes), nil
+}
+
+// CountActiveByAPIKey возвращает число активных ящиков ключа
+func (r *MailboxRepository) CountActiveByAPIKey(ctx context.Context, apiKeyID string) (int, error) {
+	now := time.Now()
+	mailboxes := r.find(func(m *domain.Mailbox) bool {
+		return m.APIKeyID == apiKeyID && m.IsActive && m.ExpiresAt.After(now)
+	})
+	return len(mailboxes), nil
+}
+
+// matchSearch проверяет ящик по условиям поиска
+func matchSearch(m *domain.Mailbox, filter domain.MailboxSearch) bool {
+	if len(filter.IDs) > 0 && !contains(filter.IDs, m.ID) {
+		return false
+	}
+	if filter.Query != "" && !containsFold(m.Address, filter.Query) {
+		return false
+	}
+	if filter.Active != nil && m.IsActive != *filter.Active {
+		return false
+	}
+	if filter.APIKeyID != "" && m.APIKeyID != filter.APIKeyID {
+		return false
+	}
+	return true
+}
+
+// contains проверяет, есть ли value в списке
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Search ищет ящики по всему сервису, новые первыми
+// Возвращает страницу ящиков и общее число подходящих ящиков
+func (r *MailboxRepository) Search(ctx context.Context, filter domain.MailboxSearch) ([]*domain.Mailbox, int, error) {
+	mailboxes := r.sorted(r.find(func(m *domain.Mailbox) bool {
+		return matchSearch(m, filter)
+	}), true)
+	return page(mailboxes, filter.Limit, filter.Offset), len(mailboxes), nil
+}
+
+// ExpireMatching досрочно завершает срок активных ящиков, подходящих под условия
+// Ящики переходят в льготный период, как при обычном истечении срока
+func (r *MailboxRepository) ExpireMatching(ctx context.Context, filter domain.MailboxSearch) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var expired int64
+	for _, stored := range r.db.mailboxes {
+		m := &stored.mailbox
+		if !m.IsActive || !matchSearch(m, filter) {
+			continue
+		}
+		m.IsActive = false
+		if m.ExpiresAt.After(now) {
+			m.ExpiresAt = now
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// UpdateExpiresAt устанавливает новый срок действия ящика
+func (r *MailboxRepository) UpdateExpiresAt(ctx context.Context, id string, expiresAt time.Time) error {
+	r.update(id, func(m *domain.Mailbox) {
+		m.ExpiresAt = expiresAt
+	})
+	return nil
+}
+
+// Delete удаляет почтовый ящик вместе с его письмами
+func (r *MailboxRepository) Delete(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.deleteMailbox(id)
+	return nil
+}
+
+// Restore снова активирует ящик с новым сроком действия
+func (r *MailboxRepository) Restore(ctx context.Context, id string, expiresAt time.Time) error {
+	r.update(id, func(m *domain.Mailbox) {
+		m.IsActive = true
+		m.ExpiresAt = expiresAt
+	})
+	return nil
+}
+
+// DeactivateExpired деактивирует ящики с истёкшим сроком
+// Письма остаются на месте до окончания льготного периода
+func (r *MailboxRepository) DeactivateExpired(ctx context.Context) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var deactivated int64
+	for _, stored := range r.db.mailboxes {
+		if stored.mailbox.IsActive && stored.mailbox.ExpiresAt.Before(now) {
+			stored.mailbox.IsActive = false
+			deactivated++
+		}
+	}
+	return deactivated, nil
+}
+
+// DeleteExpired удаляет ящики, срок которых истёк раньше before, вместе с письмами
+func (r *MailboxRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deleted int64
+	for id, stored := range r.db.mailboxes {
+		if stored.mailbox.ExpiresAt.Before(before) {
+			r.db.deleteMailbox(id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// deleteMailbox удаляет ящик и его письма (как ON DELETE CASCADE)
+// Вызывается под блокировкой на запись
+func (db *DB) deleteMailbox(id string) {
+	delete(db.mailboxes, id)
+	for msgID, stored := range db.messages {
+		if stored.msg.MailboxID == id {
+			delete(db.messages, msgID)
+		}
+	}
+}
+
+// find возвращает копии ящиков, подходящих под match
+func (r *MailboxRepository) find(match func(*domain.Mailbox) bool) []*storedMailbox {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var found []*storedMailbox
+	for _, stored := range r.db.mailboxes {
+		if match(&stored.mailbox) {
+			found = append(found, &storedMailbox{mailbox: stored.mailbox, seq: stored.seq})
+		}
+	}
+	return found
+}
+
+// sorted упорядочивает ящики по дате создания и возвращает их
+func (r *MailboxRepository) sorted(found []*storedMailbox, desc bool) []*domain.Mailbox {
+	sortByTime(found, func(s *storedMailbox) (time.Time, int64) {
+		return s.mailbox.CreatedAt, s.seq
+	}, desc)
+
+	mailboxes := make([]*domain.Mailbox, len(found))
+	for i, stored := range found {
+		mailboxes[i] = &stored.mailbox
+	}
+	return mailboxes
+}
+
+// update меняет ящик с указанным ID, если он есть
+func (r *MailboxRepository) update(id string, change func(*domain.Mailbox)) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if stored, ok := r.db.mailboxes[id]; ok {
+		change(&stored.mailbox)
+	}
+}
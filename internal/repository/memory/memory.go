@@ -0,0 +1,85 @@
+// Package memory хранит данные сервиса в памяти процесса
+//
+// Хранилища повторяют поведение репозиториев PostgreSQL: тот же порядок выдачи,
+// ErrDuplicate для занятых адресов и ключей, каскадное удаление писем вместе
+// с ящиком и отвязку ящиков от удалённого ключа. Так сервер можно запустить
+// без внешних зависимостей (STORAGE_BACKEND=memory) — для локальной разработки
+// и интеграционных тестов. Данные теряются при остановке процесса и не видны
+// другим процессам, поэтому API и отдельный SMTP-сервер с этим хранилищем
+// не работают вместе.
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// DB — общее состояние всех хранилищ
+// Одна блокировка на всё: связи между ящиками, письмами и ключами
+// меняются атомарно, как в транзакции
+type DB struct {
+	mu sync.RWMutex
+
+	mailboxes map[string]*storedMailbox // По ID
+	messages  map[string]*storedMessage // По ID
+	apiKeys   map[string]*storedAPIKey  // По ID
+
+	statsHourly map[statKey]int64
+	statsDaily  map[statKey]int64
+
+	greylist  map[domain.GreylistTriplet]*greylistEntry
+	whitelist map[string]time.Time // Сеть клиента → срок записи
+
+	seq int64 // Порядок добавления: при равном времени записи выдаются по нему
+}
+
+// NewDB создаёт пустое хранилище
+func NewDB() *DB {
+	return &DB{
+		mailboxes:   make(map[string]*storedMailbox),
+		messages:    make(map[string]*storedMessage),
+		apiKeys:     make(map[string]*storedAPIKey),
+		statsHourly: make(map[statKey]int64),
+		statsDaily:  make(map[statKey]int64),
+		greylist:    make(map[domain.GreylistTriplet]*greylistEntry),
+		whitelist:   make(map[string]time.Time),
+	}
+}
+
+// next возвращает следующий порядковый номер записи
+// Вызывается под блокировкой на запись
+func (db *DB) next() int64 {
+	db.seq++
+	return db.seq
+}
+
+// containsFold проверяет, что s содержит substr без учёта регистра
+// (как strpos(lower(s), lower(substr)) > 0 в PostgreSQL)
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// page возвращает страницу [offset, offset+limit) среза, как LIMIT/OFFSET в SQL
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) || limit <= 0 {
+		return nil
+	}
+	offset = max(offset, 0)
+	return items[offset:min(offset+limit, len(items))]
+}
+
+// sortByTime упорядочивает записи по времени, при равенстве — по порядку добавления
+func sortByTime[T any](items []T, key func(T) (time.Time, int64), desc bool) {
+	sort.SliceStable(items, func(i, j int) bool {
+		ti, si := key(items[i])
+		tj, sj := key(items[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj) != desc
+		}
+		return (si < sj) != desc
+	})
+}
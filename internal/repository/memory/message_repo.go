@@ -0,0 +1,253 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// errUnknownMailbox — письмо для несуществующего ящика (в PostgreSQL — нарушение внешнего ключа)
+var errUnknownMailbox = errors.New("ящик письма не найден")
+
+// storedMessage — письмо, его конверт, исходный текст и порядок добавления
+// Конверт и исходный текст хранятся отдельно: как и в PostgreSQL,
+// списки писем возвращаются без них
+type storedMessage struct {
+	msg      domain.Message
+	envelope *domain.Envelope
+	raw      []byte
+	seq      int64
+}
+
+// MessageRepository — письма в памяти
+type MessageRepository struct {
+	db *DB
+}
+
+// NewMessageRepository создаёт новый репозиторий
+func NewMessageRepository(db *DB) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+// Create создаёт новое письмо вместе с SMTP-конвертом, если он задан
+func (r *MessageRepository) Create(ctx context.Context, msg *domain.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now()
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.messages[msg.ID]; ok {
+		return repository.ErrDuplicate
+	}
+	if _, ok := r.db.mailboxes[msg.MailboxID]; !ok {
+		return errUnknownMailbox
+	}
+
+	stored := &storedMessage{msg: cloneMessage(msg), seq: r.db.next()}
+	// Флаг карантина ставит только администратор
+	stored.msg.IsQuarantined = false
+	if msg.Envelope != nil {
+		msg.Envelope.MessageID = msg.ID
+		envelope := *msg.Envelope
+		stored.envelope = &envelope
+	}
+	if msg.RawSource != nil {
+		stored.raw = append([]byte(nil), msg.RawSource...)
+	}
+	r.db.messages[msg.ID] = stored
+	return nil
+}
+
+// GetByMailboxID возвращает письма указанного ящика с учётом фильтра, новые первыми
+// Письма в карантине владельцу не показываем
+func (r *MessageRepository) GetByMailboxID(ctx context.Context, mailboxID string, filter domain.MessageFilter) ([]*domain.Message, error) {
+	return r.find(func(m *domain.Message) bool {
+		return m.MailboxID == mailboxID && (filter.Tag == "" || m.Tag == filter.Tag) && !m.IsQuarantined
+	}), nil
+}
+
+// GetByID находит письмо по ID вместе с конвертом
+func (r *MessageRepository) GetByID(ctx context.Context, id string) (*domain.Message, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.messages[id]
+	if !ok {
+		return nil, nil
+	}
+	msg := cloneMessage(&stored.msg)
+	if stored.envelope != nil {
+		envelope := *stored.envelope
+		msg.Envelope = &envelope
+	}
+	return &msg, nil
+}
+
+// GetRawSource возвращает исходный текст письма
+// nil без ошибки — письма нет, оно в карантине или сохранено без исходника
+func (r *MessageRepository) GetRawSource(ctx context.Context, id string) ([]byte, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.messages[id]
+	if !ok || stored.msg.IsQuarantined || stored.raw == nil {
+		return nil, nil
+	}
+	return append([]byte(nil), stored.raw...), nil
+}
+
+// Search ищет письма по всему сервису, новые первыми
+// Возвращает страницу писем и общее число подходящих писем
+func (r *MessageRepository) Search(ctx context.Context, filter domain.MessageSearch) ([]*domain.Message, int, error) {
+	messages := r.find(func(m *domain.Message) bool {
+		switch {
+		case filter.MailboxID != "" && m.MailboxID != filter.MailboxID:
+			return false
+		case filter.From != "" && !containsFold(m.FromAddress, filter.From):
+			return false
+		case filter.Spam != nil && m.IsSpam != *filter.Spam:
+			return false
+		case filter.Quarantined != nil && m.IsQuarantined != *filter.Quarantined:
+			return false
+		case !filter.Since.IsZero() && m.ReceivedAt.Before(filter.Since):
+			return false
+		}
+		return true
+	})
+	return page(messages, filter.Limit, filter.Offset), len(messages), nil
+}
+
+// SetQuarantined помещает письмо в карантин или возвращает из него
+// false без ошибки — письма нет
+func (r *MessageRepository) SetQuarantined(ctx context.Context, id string, quarantined bool) (bool, error) {
+	return r.update(id, func(m *domain.Message) {
+		m.IsQuarantined = quarantined
+	}), nil
+}
+
+// TopSenders возвращает отправителей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopSenders(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(func(m *domain.Message) string { return m.FromAddress }, since, limit), nil
+}
+
+// TopRecipients возвращает получателей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopRecipients(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(func(m *domain.Message) string { return m.Recipient }, since, limit), nil
+}
+
+// topAddresses считает письма по адресам (без учёта регистра), самые частые первыми
+func (r *MessageRepository) topAddresses(address func(*domain.Message) string, since time.Time, limit int) []domain.AddressCount {
+	r.db.mu.RLock()
+	counts := make(map[string]int)
+	for _, stored := range r.db.messages {
+		addr := address(&stored.msg)
+		if addr != "" && !stored.msg.ReceivedAt.Before(since) {
+			counts[strings.ToLower(addr)]++
+		}
+	}
+	r.db.mu.RUnlock()
+
+	var top []domain.AddressCount
+	for addr, count := range counts {
+		top = append(top, domain.AddressCount{Address: addr, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Address < top[j].Address
+	})
+	return page(top, limit, 0)
+}
+
+// MarkAsRead помечает письмо как прочитанное
+func (r *MessageRepository) MarkAsRead(ctx context.Context, id string) error {
+	r.update(id, func(m *domain.Message) {
+		m.IsRead = true
+	})
+	return nil
+}
+
+// Delete удаляет письмо
+func (r *MessageRepository) Delete(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.messages, id)
+	return nil
+}
+
+// CountByMailboxID возвращает количество писем в ящике
+func (r *MessageRepository) CountByMailboxID(ctx context.Context, mailboxID string) (int, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	count := 0
+	for _, stored := range r.db.messages {
+		if stored.msg.MailboxID == mailboxID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// find возвращает копии писем, подходящих под match, новые первыми
+// Конверт и исходный текст в списки не попадают
+func (r *MessageRepository) find(match func(*domain.Message) bool) []*domain.Message {
+	r.db.mu.RLock()
+	var found []*storedMessage
+	for _, stored := range r.db.messages {
+		if match(&stored.msg) {
+			found = append(found, &storedMessage{msg: cloneMessage(&stored.msg), seq: stored.seq})
+		}
+	}
+	r.db.mu.RUnlock()
+
+	sortByTime(found, func(s *storedMessage) (time.Time, int64) {
+		return s.msg.ReceivedAt, s.seq
+	}, true)
+
+	messages := make([]*domain.Message, len(found))
+	for i, stored := range found {
+		messages[i] = &stored.msg
+	}
+	return messages
+}
+
+// update меняет письмо с указанным ID; false — письма нет
+func (r *MessageRepository) update(id string, change func(*domain.Message)) bool {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.messages[id]
+	if ok {
+		change(&stored.msg)
+	}
+	return ok
+}
+
+// cloneMessage копирует письмо без конверта и исходного текста
+// Результаты проверок копируются целиком, чтобы вызывающий не менял хранимое письмо
+func cloneMessage(msg *domain.Message) domain.Message {
+	clone := *msg
+	clone.Envelope = nil
+	clone.RawSource = nil
+	if msg.Authentication != nil {
+		authentication := *msg.Authentication
+		authentication.DKIM = append([]domain.DKIMResult(nil), msg.Authentication.DKIM...)
+		clone.Authentication = &authentication
+	}
+	return clone
+}
@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// statsDay — длина суток для суточных итогов (в UTC, как date_trunc в PostgreSQL)
+const statsDay = 24 * time.Hour
+
+// statKey — значение счётчика name за интервал, начинающийся в unix
+type statKey struct {
+	unix int64
+	name string
+}
+
+// StatsRepository — счётчики статистики в памяти
+type StatsRepository struct {
+	db *DB
+}
+
+// NewStatsRepository создаёт новый репозиторий
+func NewStatsRepository(db *DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// Add прибавляет значения к часовым счётчикам
+// Time каждого значения должно быть началом часа
+func (r *StatsRepository) Add(ctx context.Context, values []domain.StatValue) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, v := range values {
+		r.db.statsHourly[statKey{unix: v.Time.Unix(), name: v.Name}] += v.Value
+	}
+	return nil
+}
+
+// Rollup пересчитывает суточные итоги для суток, начиная с since
+// since должно быть началом суток: за эти сутки должны сохраниться все часовые значения
+func (r *StatsRepository) Rollup(ctx context.Context, since time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	daily := make(map[statKey]int64)
+	for key, value := range r.db.statsHourly {
+		if key.unix < since.Unix() {
+			continue
+		}
+		day := time.Unix(key.unix, 0).UTC().Truncate(statsDay)
+		daily[statKey{unix: day.Unix(), name: key.name}] += value
+	}
+	// Итоги пересчитанных суток заменяются целиком
+	for key, value := range daily {
+		r.db.statsDaily[key] = value
+	}
+	return nil
+}
+
+// DeleteHourlyBefore удаляет часовые значения старше before
+func (r *StatsRepository) DeleteHourlyBefore(ctx context.Context, before time.Time) (int64, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var deleted int64
+	for key := range r.db.statsHourly {
+		if key.unix < before.Unix() {
+			delete(r.db.statsHourly, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Series возвращает суммы счётчиков за интервалы длиной step в [from, to)
+// Начиная со split берутся часовые значения, раньше — суточные
+// Интервалы выровнены по Unix-времени: суточные начинаются в полночь UTC
+func (r *StatsRepository) Series(ctx context.Context, from, to, split time.Time, step time.Duration) ([]domain.StatValue, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	seconds := int64(step / time.Second)
+	points := make(map[statKey]int64)
+	add := func(key statKey, value int64) {
+		points[statKey{unix: key.unix / seconds * seconds, name: key.name}] += value
+	}
+	for key, value := range r.db.statsHourly {
+		if key.unix >= max(from.Unix(), split.Unix()) && key.unix < to.Unix() {
+			add(key, value)
+		}
+	}
+	for key, value := range r.db.statsDaily {
+		if key.unix >= from.Unix() && key.unix < min(to.Unix(), split.Unix()) {
+			add(key, value)
+		}
+	}
+
+	values := make([]domain.StatValue, 0, len(points))
+	for key, value := range points {
+		values = append(values, domain.StatValue{Time: time.Unix(key.unix, 0).UTC(), Name: key.name, Value: value})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].Time.Before(values[j].Time)
+	})
+	return values, nil
+}
+
+// Totals возвращает суммы счётчиков за всё время
+// Начиная со split берутся часовые значения, раньше — суточные
+func (r *StatsRepository) Totals(ctx context.Context, split time.Time) (map[string]int64, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	totals := make(map[string]int64)
+	for key, value := range r.db.statsHourly {
+		if key.unix >= split.Unix() {
+			totals[key.name] += value
+		}
+	}
+	for key, value := range r.db.statsDaily {
+		if key.unix < split.Unix() {
+			totals[key.name] += value
+		}
+	}
+	return totals, nil
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +11,47 @@ import (
 	"tempmail/internal/domain"
 )
 
+// messageColumns — список колонок письма в порядке, который ожидает scanMessage
+const messageColumns = `id, mailbox_id, from_address, recipient, subject, body_text, body_html, tag, received_at, is_read, is_spam, is_quarantined, authentication`
+
+// rowScanner — общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage читает письмо из строки результата
+func scanMessage(row rowScanner) (*domain.Message, error) {
+	msg := &domain.Message{}
+	var authentication []byte
+	err := row.Scan(
+		&msg.ID,
+		&msg.MailboxID,
+		&msg.FromAddress,
+		&msg.Recipient,
+		&msg.Subject,
+		&msg.BodyText,
+		&msg.BodyHTML,
+		&msg.Tag,
+		&msg.ReceivedAt,
+		&msg.IsRead,
+		&msg.IsSpam,
+		&msg.IsQuarantined,
+		&authentication,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Результаты проверок хранятся в JSONB; NULL — проверка не выполнялась
+	if authentication != nil {
+		msg.Authentication = &domain.Authentication{}
+		if err := json.Unmarshal(authentication, msg.Authentication); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
 // MessageRepository — репозиторий для работы с письмами
 type MessageRepository struct {
 	db *sql.DB
@@ -19,8 +62,8 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	return &MessageRepository{db: db}
 }
 
-// Create создаёт новое письмо
-func (r *MessageRepository) Create(msg *domain.Message) error {
+// Create создаёт новое письмо вместе с SMTP-конвертом, если он задан
+func (r *MessageRepository) Create(ctx context.Context, msg *domain.Message) error {
 	// Генерируем ID, если не задан
 	if msg.ID == "" {
 		msg.ID = uuid.New().String()
@@ -31,37 +74,135 @@ func (r *MessageRepository) Create(msg *domain.Message) error {
 		msg.ReceivedAt = time.Now()
 	}
 
+	// nil превращается в NULL, иначе сохраняем результаты проверок как JSON
+	// Передаём строкой: []byte драйвер отправил бы как bytea
+	var authentication sql.NullString
+	if msg.Authentication != nil {
+		data, err := json.Marshal(msg.Authentication)
+		if err != nil {
+			return err
+		}
+		authentication = sql.NullString{String: string(data), Valid: true}
+	}
+
+	// Письмо и его конверт сохраняем в одной транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	// Rollback после Commit ничего не делает, поэтому его можно вызывать всегда
+	defer tx.Rollback()
+
 	query := `
-        INSERT INTO messages (id, mailbox_id, from_address, subject, body_text, body_html, received_at, is_read, is_spam)
-        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        INSERT INTO messages (id, mailbox_id, from_address, recipient, subject, body_text, body_html, tag, received_at, is_read, is_spam, authentication, raw_source)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
     `
 
-	_, err := r.db.Exec(query,
+	_, err = tx.ExecContext(ctx, query,
 		msg.ID,
 		msg.MailboxID,
 		msg.FromAddress,
+		msg.Recipient,
 		msg.Subject,
 		msg.BodyText,
 		msg.BodyHTML,
+		msg.Tag,
 		msg.ReceivedAt,
 		msg.IsRead,
 		msg.IsSpam,
+		authentication,
+		msg.RawSource,
 	)
+	if err != nil {
+		return err
+	}
+
+	if msg.Envelope != nil {
+		msg.Envelope.MessageID = msg.ID
+		if err := insertEnvelope(ctx, tx, msg.Envelope); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
 
+// insertEnvelope сохраняет SMTP-конверт письма
+func insertEnvelope(ctx context.Context, tx *sql.Tx, env *domain.Envelope) error {
+	query := `
+        INSERT INTO message_envelopes (message_id, queue_id, remote_ip, helo, tls, tls_version, tls_cipher,
+            mail_from, rcpt_to, body_type, smtputf8, declared_size, size, received_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    `
+
+	_, err := tx.ExecContext(ctx, query,
+		env.MessageID,
+		env.QueueID,
+		env.RemoteIP,
+		env.Helo,
+		env.TLS,
+		env.TLSVersion,
+		env.TLSCipher,
+		env.MailFrom,
+		env.RcptTo,
+		env.BodyType,
+		env.SMTPUTF8,
+		env.DeclaredSize,
+		env.Size,
+		env.ReceivedAt,
+	)
 	return err
 }
 
-// GetByMailboxID возвращает все письма для указанного ящика
-func (r *MessageRepository) GetByMailboxID(mailboxID string) ([]*domain.Message, error) {
+// getEnvelope возвращает SMTP-конверт письма или nil, если его нет
+func (r *MessageRepository) getEnvelope(ctx context.Context, messageID string) (*domain.Envelope, error) {
 	query := `
-        SELECT id, mailbox_id, from_address, subject, body_text, body_html, received_at, is_read, is_spam
+        SELECT message_id, queue_id, remote_ip, helo, tls, tls_version, tls_cipher,
+            mail_from, rcpt_to, body_type, smtputf8, declared_size, size, received_at
+        FROM message_envelopes
+        WHERE message_id = $1
+    `
+
+	env := &domain.Envelope{}
+	err := r.db.QueryRowContext(ctx, query, messageID).Scan(
+		&env.MessageID,
+		&env.QueueID,
+		&env.RemoteIP,
+		&env.Helo,
+		&env.TLS,
+		&env.TLSVersion,
+		&env.TLSCipher,
+		&env.MailFrom,
+		&env.RcptTo,
+		&env.BodyType,
+		&env.SMTPUTF8,
+		&env.DeclaredSize,
+		&env.Size,
+		&env.ReceivedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// GetByMailboxID возвращает письма указанного ящика с учётом фильтра
+func (r *MessageRepository) GetByMailboxID(ctx context.Context, mailboxID string, filter domain.MessageFilter) ([]*domain.Message, error) {
+	// Пустой тег ($2 = '') означает «без фильтра по тегу»
+	// Письма в карантине владельцу не показываем
+	query := `
+        SELECT ` + messageColumns + `
         FROM messages
-        WHERE mailbox_id = $1
+        WHERE mailbox_id = $1 AND ($2::text = '' OR tag = $2) AND NOT is_quarantined
         ORDER BY received_at DESC
     `
 
 	// Query возвращает несколько строк
-	rows, err := r.db.Query(query, mailboxID)
+	rows, err := r.db.QueryContext(ctx, query, mailboxID, filter.Tag)
 	if err != nil {
 		return nil, err
 	}
@@ -73,18 +214,7 @@ func (r *MessageRepository) GetByMailboxID(mailboxID string) ([]*domain.Message,
 
 	// Перебираем все строки результата
 	for rows.Next() {
-		msg := &domain.Message{}
-		err := rows.Scan(
-			&msg.ID,
-			&msg.MailboxID,
-			&msg.FromAddress,
-			&msg.Subject,
-			&msg.BodyText,
-			&msg.BodyHTML,
-			&msg.ReceivedAt,
-			&msg.IsRead,
-			&msg.IsSpam,
-		)
+		msg, err := scanMessage(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -101,26 +231,14 @@ func (r *MessageRepository) GetByMailboxID(mailboxID string) ([]*domain.Message,
 }
 
 // GetByID находит письмо по ID
-func (r *MessageRepository) GetByID(id string) (*domain.Message, error) {
+func (r *MessageRepository) GetByID(ctx context.Context, id string) (*domain.Message, error) {
 	query := `
-        SELECT id, mailbox_id, from_address, subject, body_text, body_html, received_at, is_read, is_spam
+        SELECT ` + messageColumns + `
         FROM messages
         WHERE id = $1
     `
 
-	msg := &domain.Message{}
-	err := r.db.QueryRow(query, id).Scan(
-		&msg.ID,
-		&msg.MailboxID,
-		&msg.FromAddress,
-		&msg.Subject,
-		&msg.BodyText,
-		&msg.BodyHTML,
-		&msg.ReceivedAt,
-		&msg.IsRead,
-		&msg.IsSpam,
-	)
-
+	msg, err := scanMessage(r.db.QueryRowContext(ctx, query, id))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -128,29 +246,164 @@ func (r *MessageRepository) GetByID(id string) (*domain.Message, error) {
 		return nil, err
 	}
 
+	// Конверт нужен только при просмотре одного письма
+	msg.Envelope, err = r.getEnvelope(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
 	return msg, nil
 }
 
+// GetRawSource возвращает исходный текст письма
+// nil без ошибки — письма нет, оно в карантине или сохранено до появления исходников
+func (r *MessageRepository) GetRawSource(ctx context.Context, id string) ([]byte, error) {
+	query := `SELECT raw_source FROM messages WHERE id = $1 AND NOT is_quarantined`
+
+	var raw []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Search ищет письма по всему сервису, новые первыми
+// Возвращает страницу писем и общее число подходящих писем
+func (r *MessageRepository) Search(ctx context.Context, filter domain.MessageSearch) ([]*domain.Message, int, error) {
+	var cond conditions
+	if filter.MailboxID != "" {
+		cond.add("mailbox_id = ?", filter.MailboxID)
+	}
+	if filter.From != "" {
+		cond.add("strpos(lower(from_address), lower(?)) > 0", filter.From)
+	}
+	if filter.Spam != nil {
+		cond.add("is_spam = ?", *filter.Spam)
+	}
+	if filter.Quarantined != nil {
+		cond.add("is_quarantined = ?", *filter.Quarantined)
+	}
+	if !filter.Since.IsZero() {
+		cond.add("received_at >= ?", filter.Since)
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages `+cond.where(), cond.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT ` + messageColumns + `
+        FROM messages
+        ` + cond.where() + `
+        ORDER BY received_at DESC
+        LIMIT ` + cond.param(filter.Limit) + ` OFFSET ` + cond.param(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, cond.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return messages, total, nil
+}
+
+// SetQuarantined помещает письмо в карантин или возвращает из него
+// false без ошибки — письма нет
+func (r *MessageRepository) SetQuarantined(ctx context.Context, id string, quarantined bool) (bool, error) {
+	query := `UPDATE messages SET is_quarantined = $2 WHERE id = $1`
+
+	result, err := r.db.ExecContext(ctx, query, id, quarantined)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// TopSenders возвращает отправителей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopSenders(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(ctx, "from_address", since, limit)
+}
+
+// TopRecipients возвращает получателей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopRecipients(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(ctx, "recipient", since, limit)
+}
+
+// topAddresses считает письма по адресам в колонке column
+// column подставляется в запрос как есть, поэтому передаётся только из кода
+func (r *MessageRepository) topAddresses(ctx context.Context, column string, since time.Time, limit int) ([]domain.AddressCount, error) {
+	query := `
+        SELECT lower(` + column + `) AS address, COUNT(*) AS count
+        FROM messages
+        WHERE received_at >= $1 AND ` + column + ` <> ''
+        GROUP BY address
+        ORDER BY count DESC, address
+        LIMIT $2
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []domain.AddressCount
+	for rows.Next() {
+		var entry domain.AddressCount
+		if err := rows.Scan(&entry.Address, &entry.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
 // MarkAsRead помечает письмо как прочитанное
-func (r *MessageRepository) MarkAsRead(id string) error {
+func (r *MessageRepository) MarkAsRead(ctx context.Context, id string) error {
 	query := `UPDATE messages SET is_read = true WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
 // Delete удаляет письмо
-func (r *MessageRepository) Delete(id string) error {
+func (r *MessageRepository) Delete(ctx context.Context, id string) error {
 	query := `DELETE FROM messages WHERE id = $1`
-	_, err := r.db.Exec(query, id)
+	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
 
 // CountByMailboxID возвращает количество писем в ящике
-func (r *MessageRepository) CountByMailboxID(mailboxID string) (int, error) {
+func (r *MessageRepository) CountByMailboxID(ctx context.Context, mailboxID string) (int, error) {
 	query := `SELECT COUNT(*) FROM messages WHERE mailbox_id = $1`
 
 	var count int
-	err := r.db.QueryRow(query, mailboxID).Scan(&count)
+	err := r.db.QueryRowContext(ctx, query, mailboxID).Scan(&count)
 	if err != nil {
 		return 0, err
 	}
@@ -1,22 +1,43 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 
-	// Импортируем драйвер PostgreSQL
-	_ "github.com/lib/pq"
+	// Драйвер PostgreSQL; тип pq.Error нужен для разбора кодов ошибок
+	"github.com/lib/pq"
 
 	"tempmail/internal/config"
+	"tempmail/internal/metrics"
 )
 
+// ErrDuplicate — запись с таким уникальным значением уже существует
+var ErrDuplicate = errors.New("запись уже существует")
+
+// SchemaVersion — номер последней миграции, которую ожидает код
+// Увеличивается вместе с каждой новой миграцией
+const SchemaVersion = 14
+
+// uniqueViolation — код ошибки PostgreSQL при нарушении UNIQUE
+const uniqueViolation = "23505"
+
+// isUniqueViolation проверяет, что ошибка вызвана нарушением ограничения UNIQUE
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+}
+
 // PostgresDB — обёртка над подключением к PostgreSQL
 type PostgresDB struct {
 	DB *sql.DB // Стандартный интерфейс Go для работы с БД
 }
 
 // NewPostgresDB создаёт новое подключение к PostgreSQL
-func NewPostgresDB(cfg config.DatabaseConfig) (*PostgresDB, error) {
+// Время каждого запроса попадает в метрики m (nil — без метрик)
+// и ограничено DB_QUERY_TIMEOUT (фоновые массовые запросы — DB_MAINTENANCE_TIMEOUT)
+func NewPostgresDB(cfg config.DatabaseConfig, m *metrics.Metrics) (*PostgresDB, error) {
 	// Формируем строку подключения
 	connStr := fmt.Sprintf(
 		"postgres://%s:%s@%s:%d/%s?sslmode=disable",
@@ -26,14 +47,14 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*PostgresDB, error) {
 		cfg.Port,
 		cfg.Name,
 	)
-	fmt.Printf("DEBUG connStr: postgres://%s:***@%s:%d/%s?sslmode=disable\n", cfg.User, cfg.Host, cfg.Port, cfg.Name)
 
-	// Открываем соединение с базой данных
-	// sql.Open не устанавливает соединение сразу, только проверяет параметры
-	db, err := sql.Open("postgres", connStr)
+	// Создаём пул соединений с базой данных
+	// NewConnector не устанавливает соединение сразу, только проверяет параметры
+	connector, err := pq.NewConnector(connStr)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка открытия БД: %w", err)
 	}
+	db := OpenInstrumented(connector, "postgresql", cfg, m)
 
 	// Проверяем, что соединение работает
 	// Ping отправляет запрос к БД и ждёт ответа
@@ -50,3 +71,28 @@ func NewPostgresDB(cfg config.DatabaseConfig) (*PostgresDB, error) {
 func (p *PostgresDB) Close() error {
 	return p.DB.Close()
 }
+
+// CheckSchema проверяет, что к БД применены все миграции, которые ожидает код
+func (p *PostgresDB) CheckSchema(ctx context.Context) error {
+	var version int
+	err := p.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("версия схемы неизвестна: %w", err)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("схема БД устарела: версия %d, нужна %d", version, SchemaVersion)
+	}
+	return nil
+}
+
+// CheckWritable проверяет, что БД принимает запись (не реплика и не режим только чтения)
+func (p *PostgresDB) CheckWritable(ctx context.Context) error {
+	var readOnly string
+	if err := p.DB.QueryRowContext(ctx, `SHOW transaction_read_only`).Scan(&readOnly); err != nil {
+		return err
+	}
+	if readOnly != "off" {
+		return errors.New("БД доступна только для чтения")
+	}
+	return nil
+}
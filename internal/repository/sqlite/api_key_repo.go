@@ -0,0 +1,156 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// apiKeyColumns — список колонок ключа в порядке, который ожидает scanAPIKey
+const apiKeyColumns = `id, name, key_hash, prefix, max_mailboxes_per_hour, max_active_mailboxes, is_active, created_at, last_used_at, requests, mailboxes_created`
+
+// scanAPIKey читает ключ из строки результата
+func scanAPIKey(row rowScanner) (*domain.APIKey, error) {
+	key := &domain.APIKey{}
+	var lastUsedAt nullTimestamp
+	err := row.Scan(
+		&key.ID,
+		&key.Name,
+		&key.KeyHash,
+		&key.Prefix,
+		&key.MaxMailboxesPerHour,
+		&key.MaxActiveMailboxes,
+		&key.IsActive,
+		(*timestamp)(&key.CreatedAt),
+		&lastUsedAt,
+		&key.Requests,
+		&key.MailboxesCreated,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.LastUsedAt = lastUsedAt.Time
+	return key, nil
+}
+
+// APIKeyRepository — API-ключи в SQLite
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewAPIKeyRepository создаёт новый репозиторий
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create сохраняет новый ключ
+// ID и дата создания заполняются, если не заданы
+func (r *APIKeyRepository) Create(ctx context.Context, key *domain.APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+
+	query := `
+        INSERT INTO api_keys (id, name, key_hash, prefix, max_mailboxes_per_hour, max_active_mailboxes, is_active, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+    `
+
+	_, err := r.db.ExecContext(ctx, query,
+		key.ID,
+		key.Name,
+		key.KeyHash,
+		key.Prefix,
+		key.MaxMailboxesPerHour,
+		key.MaxActiveMailboxes,
+		key.IsActive,
+		key.CreatedAt.UnixMicro(),
+	)
+	if isUniqueViolation(err) {
+		return repository.ErrDuplicate
+	}
+	return err
+}
+
+// GetByID находит ключ по ID
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+}
+
+// GetByHash находит ключ по SHA-256
+func (r *APIKeyRepository) GetByHash(ctx context.Context, hash string) (*domain.APIKey, error) {
+	return r.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash)
+}
+
+// get возвращает ключ, найденный запросом, или nil, если его нет
+func (r *APIKeyRepository) get(ctx context.Context, query string, arg any) (*domain.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, arg))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// List возвращает все ключи, новые первыми
+func (r *APIKeyRepository) List(ctx context.Context) ([]*domain.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*domain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Update сохраняет название, квоты и признак активности ключа
+func (r *APIKeyRepository) Update(ctx context.Context, key *domain.APIKey) error {
+	query := `
+        UPDATE api_keys
+        SET name = $2, max_mailboxes_per_hour = $3, max_active_mailboxes = $4, is_active = $5
+        WHERE id = $1
+    `
+	_, err := r.db.ExecContext(ctx, query, key.ID, key.Name, key.MaxMailboxesPerHour, key.MaxActiveMailboxes, key.IsActive)
+	return err
+}
+
+// Delete удаляет ключ; его ящики остаются, но становятся анонимными (ON DELETE SET NULL)
+func (r *APIKeyRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	return err
+}
+
+// RecordRequest учитывает запрос с ключом
+func (r *APIKeyRepository) RecordRequest(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET requests = requests + 1, last_used_at = $2 WHERE id = $1`
+	_, err := r.db.ExecContext(ctx, query, id, time.Now().UnixMicro())
+	return err
+}
+
+// RecordMailboxCreated учитывает созданный ящик
+func (r *APIKeyRepository) RecordMailboxCreated(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE api_keys SET mailboxes_created = mailboxes_created + 1 WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// GreylistRepository хранит триплеты грейлистинга в SQLite
+type GreylistRepository struct {
+	db *sql.DB
+}
+
+// NewGreylistRepository создаёт новый репозиторий
+func NewGreylistRepository(db *sql.DB) *GreylistRepository {
+	return &GreylistRepository{db: db}
+}
+
+// Attempt регистрирует попытку доставки и возвращает время первой попытки
+// и признак того, что триплет уже прошёл грейлистинг
+// Устаревшая запись начинается заново, как будто её не было
+func (r *GreylistRepository) Attempt(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) (time.Time, bool, error) {
+	query := `
+        INSERT INTO greylist (client_net, sender, recipient, first_seen, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (client_net, sender, recipient) DO UPDATE SET
+            first_seen = CASE WHEN greylist.expires_at <= $4 THEN excluded.first_seen ELSE greylist.first_seen END,
+            passed = CASE WHEN greylist.expires_at <= $4 THEN 0 ELSE greylist.passed END,
+            expires_at = CASE WHEN greylist.expires_at <= $4 THEN excluded.expires_at ELSE greylist.expires_at END
+        RETURNING first_seen, passed
+    `
+
+	now := time.Now()
+	var firstSeen time.Time
+	var passed bool
+	err := r.db.QueryRowContext(ctx, query, t.Network, t.Sender, t.Recipient, now.UnixMicro(), now.Add(ttl).UnixMicro()).
+		Scan((*timestamp)(&firstSeen), &passed)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return firstSeen, passed, nil
+}
+
+// Pass отмечает триплет как прошедший; запись живёт ещё ttl
+func (r *GreylistRepository) Pass(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) error {
+	query := `
+        UPDATE greylist SET passed = 1, expires_at = $4
+        WHERE client_net = $1 AND sender = $2 AND recipient = $3
+    `
+	_, err := r.db.ExecContext(ctx, query, t.Network, t.Sender, t.Recipient, time.Now().Add(ttl).UnixMicro())
+	return err
+}
+
+// Whitelist добавляет сеть в белый список на ttl
+func (r *GreylistRepository) Whitelist(ctx context.Context, network string, ttl time.Duration) error {
+	query := `
+        INSERT INTO greylist_whitelist (client_net, expires_at)
+        VALUES ($1, $2)
+        ON CONFLICT (client_net) DO UPDATE SET expires_at = excluded.expires_at
+    `
+	_, err := r.db.ExecContext(ctx, query, network, time.Now().Add(ttl).UnixMicro())
+	return err
+}
+
+// Whitelisted проверяет, находится ли сеть в белом списке
+func (r *GreylistRepository) Whitelisted(ctx context.Context, network string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM greylist_whitelist WHERE client_net = $1 AND expires_at > $2)`
+
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, network, time.Now().UnixMicro()).Scan(&exists)
+	return exists, err
+}
+
+// DeleteExpired удаляет устаревшие триплеты и записи белого списка
+func (r *GreylistRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx = repository.Maintenance(ctx)
+	now := time.Now().UnixMicro()
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM greylist WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = r.db.ExecContext(ctx, `DELETE FROM greylist_whitelist WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, err
+	}
+	whitelisted, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted + whitelisted, nil
+}
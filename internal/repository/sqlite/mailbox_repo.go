@@ -0,0 +1,292 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+)
+
+// mailboxColumns — список колонок ящика в порядке, который ожидает scanMailbox
+const mailboxColumns = `id, address, created_at, expires_at, is_active, is_wildcard, max_expires_at, auto_extend_seconds, token_hash, api_key_id`
+
+// scanMailbox читает ящик из строки результата
+func scanMailbox(row rowScanner) (*domain.Mailbox, error) {
+	mailbox := &domain.Mailbox{}
+	var autoExtendSeconds int64
+	var tokenHash, apiKeyID sql.NullString
+	err := row.Scan(
+		&mailbox.ID,
+		&mailbox.Address,
+		(*timestamp)(&mailbox.CreatedAt),
+		(*timestamp)(&mailbox.ExpiresAt),
+		&mailbox.IsActive,
+		&mailbox.IsWildcard,
+		(*timestamp)(&mailbox.MaxExpiresAt),
+		&autoExtendSeconds,
+		&tokenHash,
+		&apiKeyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	mailbox.AutoExtend = time.Duration(autoExtendSeconds) * time.Second
+	mailbox.TokenHash = tokenHash.String
+	mailbox.APIKeyID = apiKeyID.String
+	return mailbox, nil
+}
+
+// scanMailboxes читает все ящики результата запроса
+func scanMailboxes(rows *sql.Rows) ([]*domain.Mailbox, error) {
+	defer rows.Close()
+
+	var mailboxes []*domain.Mailbox
+	for rows.Next() {
+		mailbox, err := scanMailbox(rows)
+		if err != nil {
+			return nil, err
+		}
+		mailboxes = append(mailboxes, mailbox)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return mailboxes, nil
+}
+
+// MailboxRepository — почтовые ящики в SQLite
+type MailboxRepository struct {
+	db *sql.DB
+}
+
+// NewMailboxRepository создаёт новый репозиторий
+func NewMailboxRepository(db *sql.DB) *MailboxRepository {
+	return &MailboxRepository{db: db}
+}
+
+// Create сохраняет новый почтовый ящик
+// ID и дата создания заполняются, если не заданы
+// Если адрес уже занят, возвращается ErrDuplicate
+func (r *MailboxRepository) Create(ctx context.Context, mailbox *domain.Mailbox) error {
+	if mailbox.ID == "" {
+		mailbox.ID = uuid.New().String()
+	}
+	if mailbox.CreatedAt.IsZero() {
+		mailbox.CreatedAt = time.Now()
+	}
+
+	query := `
+        INSERT INTO mailboxes (id, address, created_at, expires_at, is_active, is_wildcard, max_expires_at, auto_extend_seconds, token_hash, api_key_id)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+    `
+
+	_, err := r.db.ExecContext(ctx, query,
+		mailbox.ID,
+		mailbox.Address,
+		mailbox.CreatedAt.UnixMicro(),
+		mailbox.ExpiresAt.UnixMicro(),
+		mailbox.IsActive,
+		mailbox.IsWildcard,
+		mailbox.MaxExpiresAt.UnixMicro(),
+		int64(mailbox.AutoExtend/time.Second),
+		sql.NullString{String: mailbox.TokenHash, Valid: mailbox.TokenHash != ""},
+		sql.NullString{String: mailbox.APIKeyID, Valid: mailbox.APIKeyID != ""},
+	)
+	if isUniqueViolation(err) {
+		return repository.ErrDuplicate
+	}
+	return err
+}
+
+// GetByID находит ящик по ID
+func (r *MailboxRepository) GetByID(ctx context.Context, id string) (*domain.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes WHERE id = $1`
+
+	mailbox, err := scanMailbox(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mailbox, nil
+}
+
+// GetByAddress находит активный ящик по email-адресу
+func (r *MailboxRepository) GetByAddress(ctx context.Context, address string) (*domain.Mailbox, error) {
+	query := `SELECT ` + mailboxColumns + ` FROM mailboxes WHERE address = $1 AND is_active`
+
+	mailbox, err := scanMailbox(r.db.QueryRowContext(ctx, query, address))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mailbox, nil
+}
+
+// GetWildcardsByDomain возвращает активные ящики-шаблоны указанного домена
+// Сначала идут более старые ящики — при равной точности шаблона побеждает старший
+func (r *MailboxRepository) GetWildcardsByDomain(ctx context.Context, domainName string) ([]*domain.Mailbox, error) {
+	// Домен — всё после «@» (split_part в PostgreSQL)
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        WHERE is_wildcard AND is_active
+          AND lower(substr(address, instr(address, '@') + 1)) = lower($1)
+        ORDER BY created_at
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, domainName)
+	if err != nil {
+		return nil, err
+	}
+	return scanMailboxes(rows)
+}
+
+// ListByAPIKey возвращает ящики ключа, новые первыми
+func (r *MailboxRepository) ListByAPIKey(ctx context.Context, apiKeyID string) ([]*domain.Mailbox, error) {
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        WHERE api_key_id = $1
+        ORDER BY created_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return scanMailboxes(rows)
+}
+
+// CountCreatedSince возвращает, сколько ящиков ключ создал начиная с since
+func (r *MailboxRepository) CountCreatedSince(ctx context.Context, apiKeyID string, since time.Time) (int, error) {
+	query := `SELECT COUNT(*) FROM mailboxes WHERE api_key_id = $1 AND created_at >= $2`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, apiKeyID, since.UnixMicro()).Scan(&count)
+	return count, err
+}
+
+// CountActiveByAPIKey возвращает число активных ящиков ключа
+func (r *MailboxRepository) CountActiveByAPIKey(ctx context.Context, apiKeyID string) (int, error) {
+	query := `SELECT COUNT(*) FROM mailboxes WHERE api_key_id = $1 AND is_active AND expires_at > $2`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, apiKeyID, time.Now().UnixMicro()).Scan(&count)
+	return count, err
+}
+
+// searchConditions превращает условия поиска ящиков в WHERE
+func searchConditions(filter domain.MailboxSearch) *conditions {
+	cond := &conditions{}
+	if len(filter.IDs) > 0 {
+		cond.add("id IN (SELECT value FROM json_each(?))", jsonArray(filter.IDs))
+	}
+	if filter.Query != "" {
+		cond.add("instr(lower(address), lower(?)) > 0", filter.Query)
+	}
+	if filter.Active != nil {
+		cond.add("is_active = ?", *filter.Active)
+	}
+	if filter.APIKeyID != "" {
+		cond.add("api_key_id = ?", filter.APIKeyID)
+	}
+	return cond
+}
+
+// Search ищет ящики по всему сервису, новые первыми
+// Возвращает страницу ящиков и общее число подходящих ящиков
+func (r *MailboxRepository) Search(ctx context.Context, filter domain.MailboxSearch) ([]*domain.Mailbox, int, error) {
+	cond := searchConditions(filter)
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM mailboxes `+cond.where(), cond.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT ` + mailboxColumns + `
+        FROM mailboxes
+        ` + cond.where() + `
+        ORDER BY created_at DESC
+        LIMIT ` + cond.param(filter.Limit) + ` OFFSET ` + cond.param(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, cond.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	mailboxes, err := scanMailboxes(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return mailboxes, total, nil
+}
+
+// ExpireMatching досрочно завершает срок активных ящиков, подходящих под условия
+// Ящики переходят в льготный период, как при обычном истечении срока
+func (r *MailboxRepository) ExpireMatching(ctx context.Context, filter domain.MailboxSearch) (int64, error) {
+	ctx = repository.Maintenance(ctx)
+	cond := searchConditions(filter)
+	cond.add("is_active = ?", true)
+	now := cond.param(time.Now().UnixMicro())
+
+	query := `UPDATE mailboxes SET is_active = 0, expires_at = min(expires_at, ` + now + `) ` + cond.where()
+
+	result, err := r.db.ExecContext(ctx, query, cond.args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// UpdateExpiresAt устанавливает новый срок действия ящика
+func (r *MailboxRepository) UpdateExpiresAt(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE mailboxes SET expires_at = $2 WHERE id = $1`, id, expiresAt.UnixMicro())
+	return err
+}
+
+// Delete удаляет почтовый ящик вместе с письмами
+func (r *MailboxRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM mailboxes WHERE id = $1`, id)
+	return err
+}
+
+// Restore снова активирует ящик с новым сроком действия
+func (r *MailboxRepository) Restore(ctx context.Context, id string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE mailboxes SET is_active = 1, expires_at = $2 WHERE id = $1`, id, expiresAt.UnixMicro())
+	return err
+}
+
+// DeactivateExpired деактивирует ящики с истёкшим сроком
+// Письма остаются на месте до окончания льготного периода
+func (r *MailboxRepository) DeactivateExpired(ctx context.Context) (int64, error) {
+	ctx = repository.Maintenance(ctx)
+	query := `UPDATE mailboxes SET is_active = 0 WHERE is_active AND expires_at < $1`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now().UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DeleteExpired удаляет ящики, срок которых истёк раньше before
+// Письма удаляются каскадно (ON DELETE CASCADE)
+func (r *MailboxRepository) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	ctx = repository.Maintenance(ctx)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM mailboxes WHERE expires_at < $1`, before.UnixMicro())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,374 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"tempmail/internal/domain"
+)
+
+// messageColumns — список колонок письма в порядке, который ожидает scanMessage
+const messageColumns = `id, mailbox_id, from_address, recipient, subject, body_text, body_html, tag, received_at, is_read, is_spam, is_quarantined, authentication`
+
+// scanMessage читает письмо из строки результата
+func scanMessage(row rowScanner) (*domain.Message, error) {
+	msg := &domain.Message{}
+	var authentication sql.NullString
+	err := row.Scan(
+		&msg.ID,
+		&msg.MailboxID,
+		&msg.FromAddress,
+		&msg.Recipient,
+		&msg.Subject,
+		&msg.BodyText,
+		&msg.BodyHTML,
+		&msg.Tag,
+		(*timestamp)(&msg.ReceivedAt),
+		&msg.IsRead,
+		&msg.IsSpam,
+		&msg.IsQuarantined,
+		&authentication,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Результаты проверок хранятся как JSON; NULL — проверка не выполнялась
+	if authentication.Valid {
+		msg.Authentication = &domain.Authentication{}
+		if err := json.Unmarshal([]byte(authentication.String), msg.Authentication); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// scanMessages читает все письма результата запроса
+func scanMessages(rows *sql.Rows) ([]*domain.Message, error) {
+	defer rows.Close()
+
+	var messages []*domain.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MessageRepository — письма в SQLite
+type MessageRepository struct {
+	db *sql.DB
+}
+
+// NewMessageRepository создаёт новый репозиторий
+func NewMessageRepository(db *sql.DB) *MessageRepository {
+	return &MessageRepository{db: db}
+}
+
+// Create создаёт новое письмо вместе с SMTP-конвертом, если он задан
+func (r *MessageRepository) Create(ctx context.Context, msg *domain.Message) error {
+	if msg.ID == "" {
+		msg.ID = uuid.New().String()
+	}
+	if msg.ReceivedAt.IsZero() {
+		msg.ReceivedAt = time.Now()
+	}
+
+	var authentication sql.NullString
+	if msg.Authentication != nil {
+		data, err := json.Marshal(msg.Authentication)
+		if err != nil {
+			return err
+		}
+		authentication = sql.NullString{String: string(data), Valid: true}
+	}
+
+	// Письмо и его конверт сохраняем в одной транзакции
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO messages (id, mailbox_id, from_address, recipient, subject, body_text, body_html, tag, received_at, is_read, is_spam, authentication, raw_source)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+    `
+
+	_, err = tx.ExecContext(ctx, query,
+		msg.ID,
+		msg.MailboxID,
+		msg.FromAddress,
+		msg.Recipient,
+		msg.Subject,
+		msg.BodyText,
+		msg.BodyHTML,
+		msg.Tag,
+		msg.ReceivedAt.UnixMicro(),
+		msg.IsRead,
+		msg.IsSpam,
+		authentication,
+		msg.RawSource,
+	)
+	if err != nil {
+		return err
+	}
+
+	if msg.Envelope != nil {
+		msg.Envelope.MessageID = msg.ID
+		if err := insertEnvelope(ctx, tx, msg.Envelope); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertEnvelope сохраняет SMTP-конверт письма
+func insertEnvelope(ctx context.Context, tx *sql.Tx, env *domain.Envelope) error {
+	query := `
+        INSERT INTO message_envelopes (message_id, queue_id, remote_ip, helo, tls, tls_version, tls_cipher,
+            mail_from, rcpt_to, body_type, smtputf8, declared_size, size, received_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+    `
+
+	_, err := tx.ExecContext(ctx, query,
+		env.MessageID,
+		env.QueueID,
+		env.RemoteIP,
+		env.Helo,
+		env.TLS,
+		env.TLSVersion,
+		env.TLSCipher,
+		env.MailFrom,
+		env.RcptTo,
+		env.BodyType,
+		env.SMTPUTF8,
+		env.DeclaredSize,
+		env.Size,
+		env.ReceivedAt.UnixMicro(),
+	)
+	return err
+}
+
+// getEnvelope возвращает SMTP-конверт письма или nil, если его нет
+func (r *MessageRepository) getEnvelope(ctx context.Context, messageID string) (*domain.Envelope, error) {
+	query := `
+        SELECT message_id, queue_id, remote_ip, helo, tls, tls_version, tls_cipher,
+            mail_from, rcpt_to, body_type, smtputf8, declared_size, size, received_at
+        FROM message_envelopes
+        WHERE message_id = $1
+    `
+
+	env := &domain.Envelope{}
+	err := r.db.QueryRowContext(ctx, query, messageID).Scan(
+		&env.MessageID,
+		&env.QueueID,
+		&env.RemoteIP,
+		&env.Helo,
+		&env.TLS,
+		&env.TLSVersion,
+		&env.TLSCipher,
+		&env.MailFrom,
+		&env.RcptTo,
+		&env.BodyType,
+		&env.SMTPUTF8,
+		&env.DeclaredSize,
+		&env.Size,
+		(*timestamp)(&env.ReceivedAt),
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// GetByMailboxID возвращает письма указанного ящика с учётом фильтра
+func (r *MessageRepository) GetByMailboxID(ctx context.Context, mailboxID string, filter domain.MessageFilter) ([]*domain.Message, error) {
+	// Пустой тег ($2 = '') означает «без фильтра по тегу»
+	// Письма в карантине владельцу не показываем
+	query := `
+        SELECT ` + messageColumns + `
+        FROM messages
+        WHERE mailbox_id = $1 AND ($2 = '' OR tag = $2) AND NOT is_quarantined
+        ORDER BY received_at DESC
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, mailboxID, filter.Tag)
+	if err != nil {
+		return nil, err
+	}
+	return scanMessages(rows)
+}
+
+// GetByID находит письмо по ID
+func (r *MessageRepository) GetByID(ctx context.Context, id string) (*domain.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE id = $1`
+
+	msg, err := scanMessage(r.db.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Конверт нужен только при просмотре одного письма
+	msg.Envelope, err = r.getEnvelope(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// GetRawSource возвращает исходный текст письма
+// nil без ошибки — письма нет или оно в карантине
+func (r *MessageRepository) GetRawSource(ctx context.Context, id string) ([]byte, error) {
+	query := `SELECT raw_source FROM messages WHERE id = $1 AND NOT is_quarantined`
+
+	var raw []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// Search ищет письма по всему сервису, новые первыми
+// Возвращает страницу писем и общее число подходящих писем
+func (r *MessageRepository) Search(ctx context.Context, filter domain.MessageSearch) ([]*domain.Message, int, error) {
+	var cond conditions
+	if filter.MailboxID != "" {
+		cond.add("mailbox_id = ?", filter.MailboxID)
+	}
+	if filter.From != "" {
+		cond.add("instr(lower(from_address), lower(?)) > 0", filter.From)
+	}
+	if filter.Spam != nil {
+		cond.add("is_spam = ?", *filter.Spam)
+	}
+	if filter.Quarantined != nil {
+		cond.add("is_quarantined = ?", *filter.Quarantined)
+	}
+	if !filter.Since.IsZero() {
+		cond.add("received_at >= ?", filter.Since.UnixMicro())
+	}
+
+	var total int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages `+cond.where(), cond.args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+        SELECT ` + messageColumns + `
+        FROM messages
+        ` + cond.where() + `
+        ORDER BY received_at DESC
+        LIMIT ` + cond.param(filter.Limit) + ` OFFSET ` + cond.param(filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, cond.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	messages, err := scanMessages(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return messages, total, nil
+}
+
+// SetQuarantined помещает письмо в карантин или возвращает из него
+// false без ошибки — письма нет
+func (r *MessageRepository) SetQuarantined(ctx context.Context, id string, quarantined bool) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `UPDATE messages SET is_quarantined = $2 WHERE id = $1`, id, quarantined)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// TopSenders возвращает отправителей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopSenders(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(ctx, "from_address", since, limit)
+}
+
+// TopRecipients возвращает получателей с наибольшим числом писем начиная с since
+func (r *MessageRepository) TopRecipients(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error) {
+	return r.topAddresses(ctx, "recipient", since, limit)
+}
+
+// topAddresses считает письма по адресам в колонке column
+// column подставляется в запрос как есть, поэтому передаётся только из кода
+func (r *MessageRepository) topAddresses(ctx context.Context, column string, since time.Time, limit int) ([]domain.AddressCount, error) {
+	query := `
+        SELECT lower(` + column + `) AS address, COUNT(*) AS count
+        FROM messages
+        WHERE received_at >= $1 AND ` + column + ` <> ''
+        GROUP BY address
+        ORDER BY count DESC, address
+        LIMIT $2
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, since.UnixMicro(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []domain.AddressCount
+	for rows.Next() {
+		var entry domain.AddressCount
+		if err := rows.Scan(&entry.Address, &entry.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// MarkAsRead помечает письмо как прочитанное
+func (r *MessageRepository) MarkAsRead(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE messages SET is_read = 1 WHERE id = $1`, id)
+	return err
+}
+
+// Delete удаляет письмо
+func (r *MessageRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM messages WHERE id = $1`, id)
+	return err
+}
+
+// CountByMailboxID возвращает количество писем в ящике
+func (r *MessageRepository) CountByMailboxID(ctx context.Context, mailboxID string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE mailbox_id = $1`, mailboxID).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,114 @@
+-- Схема SQLite: то же, что миграции PostgreSQL 001–014, одним файлом
+-- Время хранится целым числом микросекунд Unix (UTC), логические значения — 0/1
+
+-- API-ключи
+CREATE TABLE IF NOT EXISTS api_keys (
+    id TEXT PRIMARY KEY,                           -- Уникальный идентификатор (UUID)
+    name TEXT NOT NULL,                            -- Название (команда, сервис)
+    key_hash TEXT NOT NULL UNIQUE,                 -- SHA-256 ключа
+    prefix TEXT NOT NULL,                          -- Начало ключа, чтобы узнать его в списке
+    max_mailboxes_per_hour INTEGER NOT NULL DEFAULT 0, -- Ящиков в час (0 — без ограничения)
+    max_active_mailboxes INTEGER NOT NULL DEFAULT 0,   -- Активных ящиков одновременно (0 — без ограничения)
+    is_active INTEGER NOT NULL DEFAULT 1,          -- Отозванный ключ не принимается
+    created_at INTEGER NOT NULL,                   -- Дата создания
+    last_used_at INTEGER,                          -- Последний запрос с ключом
+    requests INTEGER NOT NULL DEFAULT 0,           -- Запросов с ключом
+    mailboxes_created INTEGER NOT NULL DEFAULT 0   -- Создано ящиков
+);
+
+-- Почтовые ящики
+CREATE TABLE IF NOT EXISTS mailboxes (
+    id TEXT PRIMARY KEY,                           -- Уникальный идентификатор (UUID)
+    address TEXT NOT NULL UNIQUE,                  -- Email адрес или шаблон
+    created_at INTEGER NOT NULL,                   -- Дата создания
+    expires_at INTEGER NOT NULL,                   -- Дата истечения
+    is_active INTEGER NOT NULL DEFAULT 1,          -- Активен ли ящик
+    is_wildcard INTEGER NOT NULL DEFAULT 0,        -- Адрес — шаблон со звёздочкой
+    max_expires_at INTEGER NOT NULL,               -- Дальше этой даты срок продлить нельзя
+    auto_extend_seconds INTEGER NOT NULL DEFAULT 0, -- Продление при активности
+    token_hash TEXT,                               -- SHA-256 токена ящика
+    api_key_id TEXT REFERENCES api_keys(id) ON DELETE SET NULL -- Ключ, создавший ящик
+);
+
+CREATE INDEX IF NOT EXISTS idx_mailboxes_expires ON mailboxes(expires_at);
+CREATE INDEX IF NOT EXISTS idx_mailboxes_api_key_id ON mailboxes(api_key_id, created_at);
+
+-- Письма
+CREATE TABLE IF NOT EXISTS messages (
+    id TEXT PRIMARY KEY,                           -- Уникальный идентификатор (UUID)
+    mailbox_id TEXT NOT NULL REFERENCES mailboxes(id) ON DELETE CASCADE, -- Связь с ящиком
+    from_address TEXT NOT NULL,                    -- Адрес отправителя
+    recipient TEXT NOT NULL DEFAULT '',            -- Адрес из RCPT TO
+    subject TEXT NOT NULL DEFAULT '',              -- Тема письма
+    body_text TEXT NOT NULL DEFAULT '',            -- Текстовое содержимое
+    body_html TEXT NOT NULL DEFAULT '',            -- HTML содержимое
+    tag TEXT NOT NULL DEFAULT '',                  -- Тег подадреса
+    received_at INTEGER NOT NULL,                  -- Дата получения
+    is_read INTEGER NOT NULL DEFAULT 0,            -- Прочитано ли
+    is_spam INTEGER NOT NULL DEFAULT 0,            -- Спам ли
+    is_quarantined INTEGER NOT NULL DEFAULT 0,     -- В карантине (скрыто от владельца)
+    authentication TEXT,                           -- Результаты SPF/DKIM/DMARC (JSON)
+    raw_source BLOB                                -- Исходный текст письма
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_mailbox ON messages(mailbox_id, received_at);
+CREATE INDEX IF NOT EXISTS idx_messages_received ON messages(received_at);
+
+-- SMTP-конверты писем
+CREATE TABLE IF NOT EXISTS message_envelopes (
+    message_id TEXT PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE, -- Связь с письмом
+    queue_id TEXT NOT NULL DEFAULT '',             -- ID письма на нашем сервере
+    remote_ip TEXT NOT NULL DEFAULT '',            -- IP-адрес клиента
+    helo TEXT NOT NULL DEFAULT '',                 -- Имя из HELO/EHLO
+    tls INTEGER NOT NULL DEFAULT 0,                -- Передано ли по TLS
+    tls_version TEXT NOT NULL DEFAULT '',          -- Версия TLS
+    tls_cipher TEXT NOT NULL DEFAULT '',           -- Набор шифров
+    mail_from TEXT NOT NULL DEFAULT '',            -- MAIL FROM
+    rcpt_to TEXT NOT NULL DEFAULT '',              -- RCPT TO
+    body_type TEXT NOT NULL DEFAULT '',            -- BODY=
+    smtputf8 INTEGER NOT NULL DEFAULT 0,           -- Параметр SMTPUTF8
+    declared_size INTEGER NOT NULL DEFAULT 0,      -- SIZE= из MAIL FROM
+    size INTEGER NOT NULL DEFAULT 0,               -- Фактический размер
+    received_at INTEGER NOT NULL                   -- Время приёма
+);
+
+-- Вложения
+CREATE TABLE IF NOT EXISTS attachments (
+    id TEXT PRIMARY KEY,                           -- Уникальный идентификатор
+    message_id TEXT NOT NULL REFERENCES messages(id) ON DELETE CASCADE, -- Связь с письмом
+    filename TEXT NOT NULL,                        -- Имя файла
+    content_type TEXT NOT NULL DEFAULT '',         -- MIME-тип
+    size_bytes INTEGER NOT NULL DEFAULT 0,         -- Размер в байтах
+    storage_path TEXT NOT NULL DEFAULT ''          -- Путь к файлу
+);
+
+-- Грейлистинг
+CREATE TABLE IF NOT EXISTS greylist (
+    client_net TEXT NOT NULL,                      -- Сеть клиента (/24 или /64)
+    sender TEXT NOT NULL,                          -- MAIL FROM ("<>" — null sender)
+    recipient TEXT NOT NULL,                       -- RCPT TO
+    first_seen INTEGER NOT NULL,                   -- Первая попытка доставки
+    passed INTEGER NOT NULL DEFAULT 0,             -- Повтор после задержки принят
+    expires_at INTEGER NOT NULL,                   -- Когда запись можно удалить
+    PRIMARY KEY (client_net, sender, recipient)
+);
+
+CREATE TABLE IF NOT EXISTS greylist_whitelist (
+    client_net TEXT PRIMARY KEY,                   -- Сеть клиента (/24 или /64)
+    expires_at INTEGER NOT NULL                    -- Когда запись можно удалить
+);
+
+-- Постоянная статистика
+CREATE TABLE IF NOT EXISTS stats_hourly (
+    bucket INTEGER NOT NULL,                       -- Начало часа
+    name TEXT NOT NULL,                            -- Имя счётчика
+    value INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (bucket, name)
+);
+
+CREATE TABLE IF NOT EXISTS stats_daily (
+    day INTEGER NOT NULL,                          -- Начало суток (UTC)
+    name TEXT NOT NULL,
+    value INTEGER NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, name)
+);
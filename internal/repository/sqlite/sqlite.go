@@ -0,0 +1,262 @@
+// Package sqlite хранит данные сервиса в одном файле SQLite
+//
+// Хранилища повторяют репозитории PostgreSQL: те же запросы, тот же порядок
+// выдачи, ErrDuplicate для занятых адресов и ключей, каскадное удаление писем
+// вместе с ящиком. Так API можно запустить одним бинарником без внешней БД
+// (STORAGE_BACKEND=sqlite) — для локальной разработки и небольших CI.
+// Схема создаётся встроенными миграциями при открытии файла.
+//
+// Время хранится целым числом микросекунд Unix: SQLite не знает типа
+// «время», а числа сравниваются и сортируются без учёта часового пояса.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"tempmail/internal/config"
+	"tempmail/internal/metrics"
+	"tempmail/internal/repository"
+)
+
+// SchemaVersion — номер последней встроенной миграции
+// Увеличивается вместе с каждой новой миграцией
+const SchemaVersion = 1
+
+// migrations — схема SQLite; файлы применяются по порядку номеров
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// connParams — параметры каждого соединения:
+// внешние ключи для каскадного удаления, ожидание блокировки вместо ошибки
+// SQLITE_BUSY, журнал WAL (чтение не ждёт записи) и блокировка на запись
+// с начала транзакции, чтобы две транзакции не ждали друг друга
+const connParams = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+
+// isUniqueViolation проверяет, что ошибка вызвана нарушением UNIQUE или PRIMARY KEY
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+}
+
+// DB — подключение к файлу SQLite
+type DB struct {
+	DB *sql.DB
+}
+
+// Open открывает файл БД (создаёт, если его нет) и применяет к нему миграции
+// Время каждого запроса попадает в метрики m (nil — без метрик)
+// и ограничено DB_QUERY_TIMEOUT, как у PostgreSQL
+// Каждое соединение открывает файл заново, поэтому ":memory:" не подходит
+func Open(file string, cfg config.DatabaseConfig, m *metrics.Metrics) (*DB, error) {
+	connector := dsnConnector{dsn: file + "?" + connParams, driver: &sqlite.Driver{}}
+	db := &DB{DB: repository.OpenInstrumented(connector, "sqlite", cfg, m)}
+
+	if err := db.DB.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("ошибка открытия БД %s: %w", file, err)
+	}
+	if err := db.Migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// dsnConnector открывает соединения драйвера SQLite с одним и тем же DSN
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+// Connect открывает новое соединение
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver возвращает драйвер SQLite
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Close закрывает базу данных
+func (db *DB) Close() error {
+	return db.DB.Close()
+}
+
+// Migrate применяет встроенные миграции, которых ещё нет в schema_migrations
+// Каждая миграция выполняется в своей транзакции вместе с записью её номера
+func (db *DB) Migrate(ctx context.Context) error {
+	ctx = repository.Maintenance(ctx)
+	_, err := db.DB.ExecContext(ctx, `
+        CREATE TABLE IF NOT EXISTS schema_migrations (
+            version INTEGER PRIMARY KEY,
+            applied_at INTEGER NOT NULL
+        )
+    `)
+	if err != nil {
+		return fmt.Errorf("ошибка создания schema_migrations: %w", err)
+	}
+
+	current, err := db.version(ctx)
+	if err != nil {
+		return err
+	}
+
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		// Номер миграции — число до первого «_» в имени файла
+		name := path.Base(file)
+		number, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			return fmt.Errorf("миграция %s: нет номера в имени файла", name)
+		}
+		if version <= current {
+			continue
+		}
+		if err := db.apply(ctx, file, version); err != nil {
+			return fmt.Errorf("миграция %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// apply выполняет одну миграцию и записывает её номер
+func (db *DB) apply(ctx context.Context, file string, version int) error {
+	script, err := migrations.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES ($1, $2)`, version, time.Now().UnixMicro())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// version возвращает номер последней применённой миграции (0 — пустая БД)
+func (db *DB) version(ctx context.Context) (int, error) {
+	var version int
+	err := db.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("версия схемы неизвестна: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema проверяет, что к БД применены все миграции, которые ожидает код
+func (db *DB) CheckSchema(ctx context.Context) error {
+	version, err := db.version(ctx)
+	if err != nil {
+		return err
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("схема БД устарела: версия %d, нужна %d", version, SchemaVersion)
+	}
+	return nil
+}
+
+// rowScanner — общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// timestamp читает время, сохранённое в микросекундах Unix
+// Поле time.Time передаётся в Scan как (*timestamp)(&field)
+type timestamp time.Time
+
+// Scan реализует sql.Scanner
+func (t *timestamp) Scan(src any) error {
+	micros, ok := src.(int64)
+	if !ok {
+		return fmt.Errorf("время хранится как %T, а не как число", src)
+	}
+	*t = timestamp(time.UnixMicro(micros))
+	return nil
+}
+
+// nullTimestamp читает необязательное время; NULL превращается в nil
+type nullTimestamp struct {
+	Time *time.Time
+}
+
+// Scan реализует sql.Scanner
+func (t *nullTimestamp) Scan(src any) error {
+	if src == nil {
+		t.Time = nil
+		return nil
+	}
+	var value time.Time
+	if err := (*timestamp)(&value).Scan(src); err != nil {
+		return err
+	}
+	t.Time = &value
+	return nil
+}
+
+// conditions собирает условие WHERE из необязательных фильтров
+// Знак ? в условии заменяется на очередной плейсхолдер ($1, $2, ...)
+type conditions struct {
+	clauses []string
+	args    []any
+}
+
+// add добавляет условие с одним параметром
+func (c *conditions) add(clause string, arg any) {
+	c.clauses = append(c.clauses, strings.ReplaceAll(clause, "?", c.param(arg)))
+}
+
+// param добавляет параметр и возвращает его плейсхолдер
+func (c *conditions) param(arg any) string {
+	c.args = append(c.args, arg)
+	return fmt.Sprintf("$%d", len(c.args))
+}
+
+// where возвращает WHERE со всеми условиями или пустую строку
+func (c *conditions) where() string {
+	if len(c.clauses) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(c.clauses, " AND ")
+}
+
+// jsonArray передаёт список одним параметром: в запросе его разворачивает json_each
+// Замена ANY($1) из PostgreSQL — в SQLite нет параметров-массивов
+func jsonArray(values []string) string {
+	data, _ := json.Marshal(values) // Срез строк всегда сериализуется
+	return string(data)
+}
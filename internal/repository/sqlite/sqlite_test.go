@@ -0,0 +1,112 @@
+package sqlite
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"tempmail/internal/config"
+)
+
+// TestSchemaVersion проверяет, что SchemaVersion не забыли увеличить
+// вместе с новой миграцией
+func TestSchemaVersion(t *testing.T) {
+	files, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	latest := 0
+	for _, file := range files {
+		number, _, _ := strings.Cut(path.Base(file), "_")
+		version, err := strconv.Atoi(number)
+		if err != nil {
+			t.Fatalf("migration %s: no number in file name", file)
+		}
+		latest = max(latest, version)
+	}
+	if SchemaVersion != latest {
+		t.Errorf("SchemaVersion = %d, latest migration is %d", SchemaVersion, latest)
+	}
+}
+
+// TestMigrateTwice открывает один файл дважды: повторный запуск
+// не применяет миграции заново и не теряет данные
+func TestMigrateTwice(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "tempmail.db")
+
+	db, err := Open(file, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema after first open: %v", err)
+	}
+	_, err = db.DB.ExecContext(ctx, `INSERT INTO stats_hourly (bucket, name, value) VALUES (0, 'kept', 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open(file, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatalf("second open: %v", err)
+	}
+	defer db.Close()
+
+	// Явный повторный вызов тоже ничего не меняет
+	if err := db.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate on migrated database: %v", err)
+	}
+	if err := db.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema after second open: %v", err)
+	}
+
+	var applied, version int
+	err = db.DB.QueryRowContext(ctx, `SELECT COUNT(*), MAX(version) FROM schema_migrations`).Scan(&applied, &version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("schema version = %d, want %d", version, SchemaVersion)
+	}
+	files, _ := fs.Glob(migrations, "migrations/*.sql")
+	if applied != len(files) {
+		t.Errorf("schema_migrations has %d rows, want one per migration (%d)", applied, len(files))
+	}
+
+	var kept int
+	if err := db.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM stats_hourly WHERE name = 'kept'`).Scan(&kept); err != nil {
+		t.Fatal(err)
+	}
+	if kept != 1 {
+		t.Errorf("rows written before reopening: %d, want 1", kept)
+	}
+}
+
+// TestCheckSchemaOutdated проверяет, что БД со старой схемой не считается готовой
+func TestCheckSchemaOutdated(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "tempmail.db"), config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if _, err := db.DB.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, SchemaVersion); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CheckSchema(ctx); err == nil {
+		t.Error("CheckSchema passed with the latest migration missing")
+	}
+}
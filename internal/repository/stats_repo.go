@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// StatsRepository — постоянные счётчики статистики
+// Часовые значения пишут все экземпляры сервиса, суточные собираются из часовых
+type StatsRepository struct {
+	db *sql.DB
+}
+
+// NewStatsRepository создаёт новый репозиторий
+func NewStatsRepository(db *sql.DB) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// Add прибавляет значения к часовым счётчикам
+// Time каждого значения должно быть началом часа
+func (r *StatsRepository) Add(ctx context.Context, values []domain.StatValue) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+        INSERT INTO stats_hourly (bucket, name, value)
+        VALUES ($1, $2, $3)
+        ON CONFLICT (bucket, name) DO UPDATE SET value = stats_hourly.value + EXCLUDED.value
+    `
+
+	for _, v := range values {
+		if _, err := tx.ExecContext(ctx, query, v.Time, v.Name, v.Value); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Rollup пересчитывает суточные итоги для суток, начиная с since
+// since должно быть началом суток: за эти сутки должны сохраниться все часовые значения
+func (r *StatsRepository) Rollup(ctx context.Context, since time.Time) error {
+	ctx = Maintenance(ctx)
+	query := `
+        INSERT INTO stats_daily (day, name, value)
+        SELECT date_trunc('day', bucket, 'UTC'), name, SUM(value)
+        FROM stats_hourly
+        WHERE bucket >= $1
+        GROUP BY 1, 2
+        ON CONFLICT (day, name) DO UPDATE SET value = EXCLUDED.value
+    `
+
+	_, err := r.db.ExecContext(ctx, query, since)
+	return err
+}
+
+// DeleteHourlyBefore удаляет часовые значения старше before
+func (r *StatsRepository) DeleteHourlyBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx = Maintenance(ctx)
+	result, err := r.db.ExecContext(ctx, `DELETE FROM stats_hourly WHERE bucket < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Series возвращает суммы счётчиков за интервалы длиной step в [from, to)
+// Начиная со split берутся часовые значения, раньше — суточные
+// Интервалы выровнены по Unix-времени: суточные начинаются в полночь UTC
+func (r *StatsRepository) Series(ctx context.Context, from, to, split time.Time, step time.Duration) ([]domain.StatValue, error) {
+	query := `
+        SELECT to_timestamp((floor(extract(epoch FROM t) / $4) * $4)::double precision) AS point, name, SUM(value)
+        FROM (
+            SELECT bucket AS t, name, value FROM stats_hourly
+            WHERE bucket >= GREATEST($1, $3) AND bucket < $2
+            UNION ALL
+            SELECT day, name, value FROM stats_daily
+            WHERE day >= $1 AND day < LEAST($2, $3)
+        ) s
+        GROUP BY point, name
+        ORDER BY point
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, from, to, split, int64(step/time.Second))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []domain.StatValue
+	for rows.Next() {
+		var v domain.StatValue
+		if err := rows.Scan(&v.Time, &v.Name, &v.Value); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Totals возвращает суммы счётчиков за всё время
+// Начиная со split берутся часовые значения, раньше — суточные
+func (r *StatsRepository) Totals(ctx context.Context, split time.Time) (map[string]int64, error) {
+	query := `
+        SELECT name, SUM(value)
+        FROM (
+            SELECT name, value FROM stats_hourly WHERE bucket >= $1
+            UNION ALL
+            SELECT name, value FROM stats_daily WHERE day < $1
+        ) s
+        GROUP BY name
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, split)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		totals[name] = value
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"tempmail/internal/config"
+	"tempmail/internal/metrics"
+	"tempmail/internal/tracing"
+)
+
+// queryObserver получает время выполнения каждого запроса к БД
+type queryObserver func(operation, table string, duration time.Duration)
+
+// timedConnector оборачивает соединения драйвера: ограничивает и замеряет время запросов
+// и записывает по спану на запрос
+// Так это работает для всех репозиториев без изменения их кода
+type timedConnector struct {
+	driver.Connector
+	system   string // СУБД для спанов: postgresql, sqlite
+	observe  queryObserver
+	timeouts queryTimeouts
+}
+
+// OpenInstrumented открывает пул соединений connector, в котором каждый запрос
+// ограничен по времени (DB_QUERY_TIMEOUT), замерен для метрик m и записан в трассу
+// system — имя СУБД в спанах (postgresql, sqlite)
+func OpenInstrumented(connector driver.Connector, system string, cfg config.DatabaseConfig, m *metrics.Metrics) *sql.DB {
+	return sql.OpenDB(timedConnector{
+		Connector: connector,
+		system:    system,
+		observe:   m.ObserveDB,
+		timeouts:  queryTimeouts{query: cfg.QueryTimeout, maintenance: cfg.MaintenanceTimeout},
+	})
+}
+
+// Connect открывает соединение с замером времени запросов
+func (c timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{Conn: conn, system: c.system, observe: c.observe, timeouts: c.timeouts}, nil
+}
+
+// timedConn — соединение, ограничивающее и замеряющее время запросов и пишущее их спаны
+// Необязательные интерфейсы драйвера передаются исходному соединению
+type timedConn struct {
+	driver.Conn
+	system   string
+	observe  queryObserver
+	timeouts queryTimeouts
+}
+
+// QueryContext выполняет запрос, возвращающий строки
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	limited, cancel := c.timeouts.limit(ctx)
+	traced, done := c.start(limited, query)
+	rows, err := queryer.QueryContext(traced, query, args)
+	err = queryError(limited, ctx, err)
+	done(err)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &limitedRows{Rows: rows, ctx: limited, parent: ctx, cancel: cancel}, nil
+}
+
+// ExecContext выполняет запрос без строк результата
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	limited, cancel := c.timeouts.limit(ctx)
+	defer cancel()
+	traced, done := c.start(limited, query)
+	result, err := execer.ExecContext(traced, query, args)
+	err = queryError(limited, ctx, err)
+	done(err)
+	return result, err
+}
+
+// PrepareContext подготавливает запрос
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx начинает транзакцию
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() // Запасной путь для драйверов без BeginTx
+}
+
+// Ping проверяет соединение
+func (c *timedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession готовит соединение к повторному использованию из пула
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid сообщает, можно ли вернуть соединение в пул
+func (c *timedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+// start начинает спан запроса (дочерний к спану из ctx)
+// done передаёт время запроса наблюдателю и закрывает спан
+// Текст запроса параметризован, значения аргументов в спан не попадают
+func (c *timedConn) start(ctx context.Context, query string) (context.Context, func(error)) {
+	operation, table := describeQuery(query)
+	began := time.Now()
+	ctx, span := tracing.Start(ctx, strings.TrimSpace("db "+operation+" "+table),
+		attribute.String("db.system.name", c.system),
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.collection.name", table),
+		attribute.String("db.query.text", query),
+	)
+	return ctx, func(err error) {
+		c.observe(operation, table, time.Since(began))
+		if !errors.Is(err, driver.ErrSkip) {
+			tracing.Fail(span, err)
+		}
+		span.End()
+	}
+}
+
+// queryTable находит первую таблицу запроса после FROM, INTO или UPDATE
+var queryTable = regexp.MustCompile(`(?i)\b(?:from|into|update)\s+([a-z_][a-z0-9_]*)`)
+
+// describeQuery возвращает вид запроса (select, insert, ...) и его таблицу
+// Метки берутся из текста запроса, а не из параметров, поэтому их немного
+func describeQuery(query string) (operation, table string) {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "unknown", ""
+	}
+	operation = strings.ToLower(fields[0])
+	if match := queryTable.FindStringSubmatch(query); match != nil {
+		table = strings.ToLower(match[1])
+	}
+	return operation, table
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ErrQueryTimeout — запрос к БД не уложился в отведённое время (DB_QUERY_TIMEOUT)
+var ErrQueryTimeout = errors.New("превышено время запроса к БД")
+
+// queryTimeouts — ограничения времени одного запроса к БД (0 — без ограничения)
+type queryTimeouts struct {
+	query       time.Duration // Обычные запросы (DB_QUERY_TIMEOUT)
+	maintenance time.Duration // Фоновые массовые запросы (DB_MAINTENANCE_TIMEOUT)
+}
+
+// maintenanceKey отмечает контекст фоновых массовых запросов
+type maintenanceKey struct{}
+
+// Maintenance отмечает запросы, которые обрабатывают много строк за раз
+// (очистка, пересчёт статистики): им отводится DB_MAINTENANCE_TIMEOUT
+func Maintenance(ctx context.Context) context.Context {
+	return context.WithValue(ctx, maintenanceKey{}, true)
+}
+
+// limit ограничивает время запроса с контекстом ctx
+// Срок вызывающего, если он ближе, сохраняется
+func (t queryTimeouts) limit(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := t.query
+	if bulk, _ := ctx.Value(maintenanceKey{}).(bool); bulk {
+		timeout = t.maintenance
+	}
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryError дополняет ошибку драйвера причиной отмены запроса
+// Драйвер сообщает только, что запрос отменён; по ErrQueryTimeout и ошибкам контекста
+// вызывающие отличают медленную БД от ушедшего клиента
+func queryError(ctx, parent context.Context, err error) error {
+	if err == nil || errors.Is(err, driver.ErrSkip) || ctx.Err() == nil {
+		return err
+	}
+	if parent.Err() != nil {
+		return fmt.Errorf("%w: %w", parent.Err(), err)
+	}
+	return fmt.Errorf("%w: %w: %w", ErrQueryTimeout, context.DeadlineExceeded, err)
+}
+
+// limitedRows — строки результата, срок запроса которых истекает вместе с ними
+// Драйвер читает строки уже после возврата из QueryContext, поэтому
+// контекст отменяется только при закрытии строк
+type limitedRows struct {
+	driver.Rows
+	ctx    context.Context
+	parent context.Context
+	cancel context.CancelFunc
+}
+
+// Next читает следующую строку
+func (r *limitedRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err == io.EOF {
+		return err
+	}
+	return queryError(r.ctx, r.parent, err)
+}
+
+// Close закрывает строки и освобождает контекст запроса
+func (r *limitedRows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
@@ -0,0 +1,46 @@
+package resolver
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"tempmail/internal/config"
+)
+
+// Resolver — DNS-запросы, которые нужны для проверки входящей почты
+// Интерфейс совместим с *net.Resolver, поэтому в тестах его легко подменить
+// заглушкой или направить на локальный DNS-сервер (DNS_SERVER)
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// New создаёт резолвер по настройкам из конфигурации
+// Если DNS_SERVER не задан, используется системный резолвер
+func New(cfg config.DNSConfig) Resolver {
+	if cfg.Server == "" {
+		return net.DefaultResolver
+	}
+
+	// Все запросы отправляем на указанный сервер, игнорируя /etc/resolv.conf
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: cfg.Timeout}
+			return d.DialContext(ctx, network, cfg.Server)
+		},
+	}
+}
+
+// WithTimeout возвращает контекст с таймаутом DNS-запросов из конфигурации
+func WithTimeout(ctx context.Context, cfg config.DNSConfig) (context.Context, context.CancelFunc) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return context.WithTimeout(ctx, timeout)
+}
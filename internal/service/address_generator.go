@@ -0,0 +1,166 @@
+package service
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"tempmail/internal/config"
+)
+
+// Стили генерации адресов (ADDRESS_STYLE)
+const (
+	AddressStyleRandom        = "random"        // k3x9q2m7za
+	AddressStylePronounceable = "pronounceable" // bonemakira
+	AddressStyleWords         = "words"         // brave-otter-417
+	AddressStylePrefix        = "prefix"        // qa-k3x9q2m7
+)
+
+// AddressGenerator генерирует локальную часть адреса (до @)
+type AddressGenerator interface {
+	Generate() (string, error)
+}
+
+// NewAddressGenerator создаёт генератор по настройкам из конфигурации
+func NewAddressGenerator(cfg config.MailConfig) (AddressGenerator, error) {
+	length := cfg.AddressLength
+	if length <= 0 {
+		return nil, fmt.Errorf("ADDRESS_LENGTH должен быть положительным, получено %d", length)
+	}
+
+	switch cfg.AddressStyle {
+	case AddressStyleRandom, "":
+		return randomGenerator{length: length}, nil
+	case AddressStylePronounceable:
+		return pronounceableGenerator{length: length}, nil
+	case AddressStyleWords:
+		return wordsGenerator{}, nil
+	case AddressStylePrefix:
+		if cfg.AddressPrefix == "" {
+			return nil, fmt.Errorf("для стиля %q нужно задать ADDRESS_PREFIX", AddressStylePrefix)
+		}
+		return prefixGenerator{prefix: cfg.AddressPrefix, random: randomGenerator{length: length}}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный стиль адресов ADDRESS_STYLE=%q", cfg.AddressStyle)
+	}
+}
+
+// randomGenerator — случайные строчные буквы и цифры
+type randomGenerator struct {
+	length int
+}
+
+func (g randomGenerator) Generate() (string, error) {
+	return randomString("abcdefghijklmnopqrstuvwxyz0123456789", g.length)
+}
+
+// pronounceableGenerator — чередование согласных и гласных, которое легко прочитать вслух
+type pronounceableGenerator struct {
+	length int
+}
+
+func (g pronounceableGenerator) Generate() (string, error) {
+	const (
+		consonants = "bdfghjklmnprstvz"
+		vowels     = "aeiou"
+	)
+
+	var b strings.Builder
+	for i := 0; i < g.length; i++ {
+		alphabet := consonants
+		if i%2 == 1 {
+			alphabet = vowels
+		}
+		c, err := randomString(alphabet, 1)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(c)
+	}
+	return b.String(), nil
+}
+
+// wordsGenerator — прилагательное, существительное и число: brave-otter-417
+type wordsGenerator struct{}
+
+func (wordsGenerator) Generate() (string, error) {
+	adjective, err := randomItem(adjectives)
+	if err != nil {
+		return "", err
+	}
+	noun, err := randomItem(nouns)
+	if err != nil {
+		return "", err
+	}
+	// Число расширяет пространство адресов, чтобы коллизии были редкими
+	number, err := randomInt(1000)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s-%03d", adjective, noun, number), nil
+}
+
+// prefixGenerator — фиксированный префикс и случайный хвост: qa-k3x9q2m7za
+type prefixGenerator struct {
+	prefix string
+	random randomGenerator
+}
+
+func (g prefixGenerator) Generate() (string, error) {
+	tail, err := g.random.Generate()
+	if err != nil {
+		return "", err
+	}
+	return g.prefix + tail, nil
+}
+
+// randomString собирает строку длины n из символов alphabet
+// Используется crypto/rand, поэтому адреса нельзя предсказать
+func randomString(alphabet string, n int) (string, error) {
+	result := make([]byte, n)
+	for i := range result {
+		idx, err := randomInt(len(alphabet))
+		if err != nil {
+			return "", err
+		}
+		result[i] = alphabet[idx]
+	}
+	return string(result), nil
+}
+
+// randomItem возвращает случайный элемент списка
+func randomItem(items []string) (string, error) {
+	idx, err := randomInt(len(items))
+	if err != nil {
+		return "", err
+	}
+	return items[idx], nil
+}
+
+// randomInt возвращает равномерно распределённое случайное число из [0, n)
+func randomInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("ошибка генератора случайных чисел: %w", err)
+	}
+	return int(v.Int64()), nil
+}
+
+// Словари для стиля words
+var (
+	adjectives = []string{
+		"amber", "bold", "brave", "bright", "calm", "clever", "cosmic", "crisp",
+		"eager", "fancy", "fast", "fuzzy", "gentle", "golden", "happy", "jolly",
+		"keen", "lucky", "mellow", "merry", "misty", "noble", "polar", "proud",
+		"quick", "quiet", "rapid", "rusty", "shiny", "silent", "silver", "sleepy",
+		"smart", "snowy", "sunny", "swift", "tidy", "vivid", "warm", "witty",
+	}
+	nouns = []string{
+		"badger", "beaver", "bison", "breeze", "canyon", "cedar", "comet", "coral",
+		"crane", "dolphin", "falcon", "fern", "finch", "forest", "fox", "glacier",
+		"harbor", "heron", "island", "koala", "lagoon", "lynx", "maple", "meadow",
+		"moose", "orbit", "otter", "panda", "pebble", "pine", "planet", "raven",
+		"river", "robin", "salmon", "summit", "tiger", "valley", "walrus", "willow",
+	}
+)
@@ -0,0 +1,208 @@
+package service
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"tempmail/internal/config"
+)
+
+// Ошибки проверки адреса
+var (
+	ErrInvalidAddress  = errors.New("недопустимый адрес")
+	ErrReservedAddress = errors.New("адрес зарезервирован")
+)
+
+// Режимы обработки точек в локальной части (ADDRESS_DOTS)
+const (
+	DotsKeep   = "keep"   // a.b и ab — разные адреса
+	DotsIgnore = "ignore" // точки не значимы: a.b@domain и ab@domain — один ящик
+)
+
+// Ограничения RFC 5321 (раздел 4.5.3.1)
+const (
+	maxLocalPartLength = 64
+	maxAddressLength   = 254
+)
+
+// AddressPolicy проверяет и нормализует адреса ящиков
+type AddressPolicy struct {
+	reserved   map[string]struct{} // Служебные имена (postmaster, abuse, ...)
+	blocked    []string            // Запрещённые подстроки (например, нецензурные слова)
+	dots       string              // Режим обработки точек
+	separators string              // Разделители подадреса — в именах ящиков запрещены
+}
+
+// NewAddressPolicy создаёт политику по настройкам из конфигурации
+// Список запрещённых слов читается из BLOCKED_WORDS_FILE: по одному слову на строку,
+// пустые строки и строки, начинающиеся с #, пропускаются
+func NewAddressPolicy(cfg config.MailConfig) (*AddressPolicy, error) {
+	switch cfg.AddressDots {
+	case DotsKeep, DotsIgnore:
+	default:
+		return nil, fmt.Errorf("неизвестный режим ADDRESS_DOTS=%q", cfg.AddressDots)
+	}
+
+	p := &AddressPolicy{
+		reserved:   make(map[string]struct{}, len(cfg.ReservedAddresses)),
+		dots:       cfg.AddressDots,
+		separators: cfg.SubaddressSeparators,
+	}
+
+	for _, name := range cfg.ReservedAddresses {
+		name = p.normalizeLocal(strings.TrimSpace(name))
+		if name != "" {
+			p.reserved[name] = struct{}{}
+		}
+	}
+
+	if cfg.BlockedWordsFile != "" {
+		words, err := readWordList(cfg.BlockedWordsFile)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка чтения BLOCKED_WORDS_FILE: %w", err)
+		}
+		p.blocked = words
+	}
+
+	return p, nil
+}
+
+// Validate проверяет локальную часть адреса, которую пользователь хочет занять
+// wildcard разрешает звёздочки (ящик-шаблон)
+// Ошибки оборачивают ErrInvalidAddress или ErrReservedAddress и содержат причину
+func (p *AddressPolicy) Validate(local string, wildcard bool) error {
+	if local == "" {
+		return fmt.Errorf("%w: пустое имя", ErrInvalidAddress)
+	}
+	if len(local) > maxLocalPartLength {
+		return fmt.Errorf("%w: имя длиннее %d символов", ErrInvalidAddress, maxLocalPartLength)
+	}
+
+	// dot-atom: точки не могут стоять в начале, в конце и подряд
+	if strings.HasPrefix(local, ".") || strings.HasSuffix(local, ".") || strings.Contains(local, "..") {
+		return fmt.Errorf("%w: точка в начале, в конце или две точки подряд", ErrInvalidAddress)
+	}
+
+	for _, r := range local {
+		if r == '*' && wildcard {
+			continue
+		}
+		if !isAtext(r) && r != '.' {
+			return fmt.Errorf("%w: недопустимый символ %q", ErrInvalidAddress, r)
+		}
+	}
+
+	if p.separators != "" && strings.ContainsAny(local, p.separators) {
+		return fmt.Errorf("%w: символы %q зарезервированы для подадресов", ErrInvalidAddress, p.separators)
+	}
+
+	// Шаблон из одних звёздочек и точек перехватил бы всю почту домена
+	if wildcard && strings.Trim(local, "*.") == "" {
+		return fmt.Errorf("%w: в шаблоне кроме звёздочек должна быть хотя бы одна буква или цифра", ErrInvalidAddress)
+	}
+
+	return p.checkReserved(local)
+}
+
+// IsReserved проверяет, что локальная часть адреса — служебное или запрещённое имя
+// Такие адреса нельзя занять, поэтому и шаблоны их не перехватывают
+func (p *AddressPolicy) IsReserved(local string) bool {
+	return p.checkReserved(local) != nil
+}
+
+// ValidateDomain проверяет доменную часть адреса (метки из букв, цифр и дефиса)
+func (p *AddressPolicy) ValidateDomain(name string) error {
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return fmt.Errorf("%w: некорректный домен %q", ErrInvalidAddress, name)
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return fmt.Errorf("%w: некорректный домен %q", ErrInvalidAddress, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAddress проверяет длину полного адреса
+func (p *AddressPolicy) ValidateAddress(address string) error {
+	if len(address) > maxAddressLength {
+		return fmt.Errorf("%w: адрес длиннее %d символов", ErrInvalidAddress, maxAddressLength)
+	}
+	return nil
+}
+
+// NormalizeAddress приводит адрес к каноническому виду, в котором он хранится:
+// нижний регистр, а в режиме DotsIgnore — без точек в локальной части
+func (p *AddressPolicy) NormalizeAddress(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return p.normalizeLocal(address)
+	}
+	return p.normalizeLocal(address[:at]) + strings.ToLower(address[at:])
+}
+
+// normalizeLocal нормализует локальную часть адреса
+func (p *AddressPolicy) normalizeLocal(local string) string {
+	local = strings.ToLower(local)
+	if p.dots == DotsIgnore {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	return local
+}
+
+// checkReserved проверяет имя по спискам служебных имён и запрещённых слов
+func (p *AddressPolicy) checkReserved(local string) error {
+	normalized := p.normalizeLocal(local)
+
+	// Служебные имена сравниваем и с точками, и без: "post.master" тоже postmaster
+	if _, ok := p.reserved[normalized]; ok {
+		return fmt.Errorf("%w: имя %q зарезервировано", ErrReservedAddress, local)
+	}
+	if _, ok := p.reserved[strings.ReplaceAll(normalized, ".", "")]; ok {
+		return fmt.Errorf("%w: имя %q зарезервировано", ErrReservedAddress, local)
+	}
+
+	// Запрещённые слова ищем без разделителей, чтобы не обходили через b.a.d или b-a-d
+	compact := strings.NewReplacer(".", "", "-", "", "_", "").Replace(normalized)
+	for _, word := range p.blocked {
+		if strings.Contains(compact, word) {
+			return fmt.Errorf("%w: имя содержит запрещённое слово", ErrReservedAddress)
+		}
+	}
+
+	return nil
+}
+
+// isAtext проверяет, что символ разрешён в dot-atom (RFC 5322, раздел 3.2.3)
+func isAtext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r)
+}
+
+// readWordList читает список слов из файла
+func readWordList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
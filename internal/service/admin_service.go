@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// ErrEmptyFilter — массовая операция без условий затронула бы все ящики
+var ErrEmptyFilter = errors.New("не заданы условия выбора ящиков")
+
+const (
+	defaultPageSize = 50  // Размер страницы по умолчанию
+	maxPageSize     = 500 // Наибольший размер страницы
+
+	defaultTopSize   = 10             // Размер отчёта по умолчанию
+	maxTopSize       = 100            // Наибольший размер отчёта
+	defaultTopPeriod = 24 * time.Hour // Период отчёта по умолчанию
+)
+
+// AdminService — операции администратора над всеми ящиками и письмами сервиса
+// В отличие от MailboxService и MessageService не учитывает владельцев,
+// льготный период и карантин
+type AdminService struct {
+	mailboxRepo MailboxStore
+	msgRepo     MessageStore
+	observer    Observer
+}
+
+// NewAdminService создаёт новый сервис
+func NewAdminService(
+	mailboxRepo MailboxStore,
+	msgRepo MessageStore,
+	observer Observer,
+) *AdminService {
+	return &AdminService{mailboxRepo: mailboxRepo, msgRepo: msgRepo, observer: observerOrNop(observer)}
+}
+
+// SearchMailboxes ищет ящики; возвращает страницу и общее число найденных
+func (s *AdminService) SearchMailboxes(ctx context.Context, filter domain.MailboxSearch) ([]*domain.Mailbox, int, error) {
+	filter.Limit, filter.Offset = page(filter.Limit, filter.Offset)
+	return s.mailboxRepo.Search(ctx, filter)
+}
+
+// DeleteMailbox удаляет ящик вместе с письмами, в каком бы состоянии он ни был
+func (s *AdminService) DeleteMailbox(ctx context.Context, id string) error {
+	mailbox, err := s.mailboxRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if mailbox == nil {
+		return ErrMailboxNotFound
+	}
+
+	if err := s.mailboxRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.observer.MailboxDeleted()
+	return nil
+}
+
+// ExpireMailboxes досрочно завершает срок подходящих активных ящиков
+// Возвращает число ящиков, переведённых в льготный период
+func (s *AdminService) ExpireMailboxes(ctx context.Context, filter domain.MailboxSearch) (int64, error) {
+	if filter.IsEmpty() {
+		return 0, ErrEmptyFilter
+	}
+	return s.mailboxRepo.ExpireMatching(ctx, filter)
+}
+
+// SearchMessages ищет письма; возвращает страницу и общее число найденных
+func (s *AdminService) SearchMessages(ctx context.Context, filter domain.MessageSearch) ([]*domain.Message, int, error) {
+	filter.Limit, filter.Offset = page(filter.Limit, filter.Offset)
+	return s.msgRepo.Search(ctx, filter)
+}
+
+// SetQuarantined помещает письмо в карантин или возвращает его владельцу
+func (s *AdminService) SetQuarantined(ctx context.Context, id string, quarantined bool) error {
+	found, err := s.msgRepo.SetQuarantined(ctx, id, quarantined)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrMessageNotFound
+	}
+	return nil
+}
+
+// TopSenders возвращает самых активных отправителей за период
+func (s *AdminService) TopSenders(ctx context.Context, period time.Duration, limit int) ([]domain.AddressCount, error) {
+	since, limit := topWindow(period, limit)
+	return s.msgRepo.TopSenders(ctx, since, limit)
+}
+
+// TopRecipients возвращает адреса, получившие больше всего писем за период
+func (s *AdminService) TopRecipients(ctx context.Context, period time.Duration, limit int) ([]domain.AddressCount, error) {
+	since, limit := topWindow(period, limit)
+	return s.msgRepo.TopRecipients(ctx, since, limit)
+}
+
+// page приводит параметры страницы к допустимым значениям
+func page(limit, offset int) (int, int) {
+	if limit <= 0 {
+		limit = defaultPageSize
+	}
+	return min(limit, maxPageSize), max(offset, 0)
+}
+
+// topWindow приводит параметры отчёта к допустимым значениям
+func topWindow(period time.Duration, limit int) (time.Time, int) {
+	if period <= 0 {
+		period = defaultTopPeriod
+	}
+	if limit <= 0 {
+		limit = defaultTopSize
+	}
+	return time.Now().Add(-period), min(limit, maxTopSize)
+}
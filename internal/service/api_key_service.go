@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"tempmail/internal/domain"
+)
+
+// Ошибки сервиса ключей
+var (
+	ErrAPIKeyNotFound = errors.New("API-ключ не найден")
+	ErrInvalidAPIKey  = errors.New("неверный или отозванный API-ключ")
+	ErrInvalidQuota   = errors.New("недопустимое значение квоты")
+	ErrEmptyKeyName   = errors.New("не указано название ключа")
+)
+
+const (
+	apiKeyPrefix = "tm_" // Начало всех ключей: так их легче найти в коде и логах
+	apiKeyBytes  = 24    // Длина случайной части ключа в байтах
+	apiKeyShown  = 11    // Сколько символов ключа хранить для отображения (tm_ + 8)
+)
+
+// APIKeyOptions — параметры создания и изменения ключа
+// nil-поля при изменении остаются прежними
+type APIKeyOptions struct {
+	Name                *string
+	MaxMailboxesPerHour *int
+	MaxActiveMailboxes  *int
+	IsActive            *bool
+}
+
+// APIKeyService — сервис для работы с API-ключами
+type APIKeyService struct {
+	repo APIKeyStore
+}
+
+// NewAPIKeyService создаёт новый сервис
+func NewAPIKeyService(repo APIKeyStore) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// Create создаёт ключ; сам ключ возвращается в поле Key только сейчас
+func (s *APIKeyService) Create(ctx context.Context, opts APIKeyOptions) (*domain.APIKey, error) {
+	key := &domain.APIKey{IsActive: true}
+	if err := applyAPIKeyOptions(key, opts); err != nil {
+		return nil, err
+	}
+	if key.Name == "" {
+		return nil, ErrEmptyKeyName
+	}
+
+	buf := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	key.Key = apiKeyPrefix + hex.EncodeToString(buf)
+	key.KeyHash = hashToken(key.Key)
+	key.Prefix = key.Key[:apiKeyShown]
+
+	if err := s.repo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// List возвращает все ключи со счётчиками использования
+func (s *APIKeyService) List(ctx context.Context) ([]*domain.APIKey, error) {
+	return s.repo.List(ctx)
+}
+
+// GetByID возвращает ключ по ID
+func (s *APIKeyService) GetByID(ctx context.Context, id string) (*domain.APIKey, error) {
+	key, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// Update меняет название, квоты или активность ключа
+func (s *APIKeyService) Update(ctx context.Context, id string, opts APIKeyOptions) (*domain.APIKey, error) {
+	key, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyAPIKeyOptions(key, opts); err != nil {
+		return nil, err
+	}
+	if key.Name == "" {
+		return nil, ErrEmptyKeyName
+	}
+
+	if err := s.repo.Update(ctx, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Delete удаляет ключ; созданные им ящики становятся анонимными
+func (s *APIKeyService) Delete(ctx context.Context, id string) error {
+	if _, err := s.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// Authenticate находит активный ключ по его значению и учитывает запрос
+func (s *APIKeyService) Authenticate(ctx context.Context, value string) (*domain.APIKey, error) {
+	if !strings.HasPrefix(value, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Ищем по хешу: индекс UNIQUE, а сравнение хешей не зависит от совпавших символов ключа
+	key, err := s.repo.GetByHash(ctx, hashToken(value))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil || !key.IsActive {
+		return nil, ErrInvalidAPIKey
+	}
+
+	// Неудачный учёт не должен мешать запросу
+	_ = s.repo.RecordRequest(ctx, key.ID)
+
+	return key, nil
+}
+
+// applyAPIKeyOptions переносит заданные параметры в ключ
+func applyAPIKeyOptions(key *domain.APIKey, opts APIKeyOptions) error {
+	if opts.Name != nil {
+		key.Name = strings.TrimSpace(*opts.Name)
+	}
+	if opts.MaxMailboxesPerHour != nil {
+		if *opts.MaxMailboxesPerHour < 0 {
+			return ErrInvalidQuota
+		}
+		key.MaxMailboxesPerHour = *opts.MaxMailboxesPerHour
+	}
+	if opts.MaxActiveMailboxes != nil {
+		if *opts.MaxActiveMailboxes < 0 {
+			return ErrInvalidQuota
+		}
+		key.MaxActiveMailboxes = *opts.MaxActiveMailboxes
+	}
+	if opts.IsActive != nil {
+		key.IsActive = *opts.IsActive
+	}
+	return nil
+}
@@ -1,14 +1,19 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math/rand"
+	"log/slog"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"tempmail/internal/config"
 	"tempmail/internal/domain"
+	"tempmail/internal/logging"
 	"tempmail/internal/repository"
+	"tempmail/internal/tracing"
 )
 
 // Ошибки сервиса
@@ -16,34 +21,300 @@ var (
 	ErrMailboxNotFound = errors.New("почтовый ящик не найден")
 	ErrMailboxExpired  = errors.New("срок действия ящика истёк")
 	ErrInvalidTTL      = errors.New("недопустимое время жизни")
+	ErrForeignDomain   = errors.New("домен не обслуживается этим сервером")
+	ErrLifetimeReached = errors.New("достигнут предельный срок жизни ящика")
+	ErrMailboxActive   = errors.New("ящик активен, восстановление не требуется")
+	ErrAddressTaken    = errors.New("адрес уже занят")
+	ErrAddressSpace    = errors.New("не удалось подобрать свободный адрес")
+	ErrHourlyQuota     = errors.New("превышена квота ключа на создание ящиков в час")
+	ErrActiveQuota     = errors.New("превышена квота ключа на число активных ящиков")
 )
 
+// generateAttempts — сколько раз пробовать новый случайный адрес при коллизии
+const generateAttempts = 5
+
+// CreateOptions — параметры создания ящика
+type CreateOptions struct {
+	Address    string         // Желаемый адрес или шаблон (пусто — случайный)
+	TTL        time.Duration  // Время жизни (0 — значение по умолчанию)
+	AutoExtend time.Duration  // Продление при активности (0 — выключено)
+	APIKey     *domain.APIKey // Ключ клиента: владелец ящика и его квоты (nil — анонимно)
+}
+
 // MailboxService — сервис для работы с почтовыми ящиками
 type MailboxService struct {
-	repo   *repository.MailboxRepository // Репозиторий для работы с БД
-	config config.MailConfig             // Настройки почты
+	repo      MailboxStore      // Хранилище ящиков
+	keys      APIKeyStore       // Счётчики использования API-ключей
+	config    config.MailConfig // Настройки почты
+	generator AddressGenerator  // Генератор случайных адресов
+	policy    *AddressPolicy    // Проверка и нормализация адресов
+	observer  Observer          // Метрики и статистика
+	logger    *slog.Logger
+
+	// Время последней успешной очистки (Unix, нс); при запуске — время создания сервиса
+	lastCleanup atomic.Int64
 }
 
 // NewMailboxService создаёт новый сервис
-func NewMailboxService(repo *repository.MailboxRepository, cfg config.MailConfig) *MailboxService {
-	return &MailboxService{
-		repo:   repo,
-		config: cfg,
+func NewMailboxService(
+	repo MailboxStore,
+	keys APIKeyStore,
+	cfg config.MailConfig,
+	generator AddressGenerator,
+	policy *AddressPolicy,
+	observer Observer,
+	logger *slog.Logger,
+) *MailboxService {
+	s := &MailboxService{
+		repo:      repo,
+		keys:      keys,
+		config:    cfg,
+		generator: generator,
+		policy:    policy,
+		observer:  observerOrNop(observer),
+		logger:    logging.OrDiscard(logger),
 	}
+	s.lastCleanup.Store(time.Now().UnixNano())
+	return s
 }
 
 // Create создаёт новый почтовый ящик
 // Если address пустой — генерируется случайный
-func (s *MailboxService) Create(address string, ttl time.Duration) (*domain.Mailbox, error) {
+// Адрес со звёздочкой (например, "test-*" или "*-qa@tempmail.dev") создаёт ящик-шаблон,
+// который принимает письма для всех подходящих адресов
+// Если указанный адрес занят, возвращается ErrAddressTaken
+func (s *MailboxService) Create(ctx context.Context, opts CreateOptions) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.Create")
+	defer span.End()
+
+	// Проверяем TTL
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = s.config.DefaultTTL
+	}
+	if ttl > s.config.MaxTTL || opts.AutoExtend < 0 || opts.AutoExtend > s.config.MaxTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	// Квоты ключа проверяем до того, как подбирать адрес
+	if err := s.checkQuota(ctx, opts.APIKey); err != nil {
+		return nil, err
+	}
+
+	// Токен для входа на submission-порт; сам токен возвращается только сейчас
+	token, tokenHash, err := newMailboxToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	mailbox := &domain.Mailbox{
+		CreatedAt:    now,
+		ExpiresAt:    now.Add(ttl),
+		IsActive:     true,
+		MaxExpiresAt: now.Add(s.maxLifetime(ttl)),
+		AutoExtend:   opts.AutoExtend,
+		TokenHash:    tokenHash,
+		Token:        token,
+	}
+	if opts.APIKey != nil {
+		mailbox.APIKeyID = opts.APIKey.ID
+	}
+
 	// Если адрес не указан — генерируем случайный
-	if address == "" {
-		address = s.generateRandomAddress()
-	} else {
-		// Добавляем домен к адресу
-		address = fmt.Sprintf("%s@%s", address, s.config.Domain)
+	if opts.Address == "" {
+		if err := s.createRandom(ctx, mailbox); err != nil {
+			return nil, err
+		}
+		s.recordCreated(ctx, mailbox)
+		return mailbox, nil
 	}
 
-	// Проверяем TTL
+	local, domainName := opts.Address, s.config.Domain
+	if at := strings.LastIndex(local, "@"); at >= 0 {
+		// Адрес указан целиком — домен должен быть нашим или его поддоменом
+		local, domainName = local[:at], local[at+1:]
+		if err := s.policy.ValidateDomain(domainName); err != nil {
+			return nil, err
+		}
+		if !s.IsLocalDomain(domainName) {
+			return nil, ErrForeignDomain
+		}
+	}
+
+	// Проверяем имя: синтаксис RFC 5321, служебные и запрещённые имена
+	wildcard := strings.Contains(local, "*")
+	if err := s.policy.Validate(local, wildcard); err != nil {
+		return nil, err
+	}
+
+	// Храним адрес в каноническом виде — так же его ищет GetByAddress
+	address := s.policy.NormalizeAddress(fmt.Sprintf("%s@%s", local, domainName))
+	if err := s.policy.ValidateAddress(address); err != nil {
+		return nil, err
+	}
+
+	mailbox.Address = address
+	mailbox.IsWildcard = wildcard
+
+	// Уникальность адреса гарантирует ограничение UNIQUE в БД,
+	// поэтому отдельная проверка перед вставкой не нужна
+	err = s.repo.Create(ctx, mailbox)
+	if errors.Is(err, repository.ErrDuplicate) {
+		return nil, ErrAddressTaken
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.recordCreated(ctx, mailbox)
+	return mailbox, nil
+}
+
+// checkQuota проверяет квоты ключа на создание ящика
+// Квоты проверяются без блокировки: при одновременных запросах ключ может
+// ненадолго превысить квоту на один-два ящика, это допустимо
+func (s *MailboxService) checkQuota(ctx context.Context, key *domain.APIKey) error {
+	if key == nil {
+		return nil
+	}
+
+	if key.MaxMailboxesPerHour > 0 {
+		created, err := s.repo.CountCreatedSince(ctx, key.ID, time.Now().Add(-time.Hour))
+		if err != nil {
+			return err
+		}
+		if created >= key.MaxMailboxesPerHour {
+			return ErrHourlyQuota
+		}
+	}
+
+	if key.MaxActiveMailboxes > 0 {
+		active, err := s.repo.CountActiveByAPIKey(ctx, key.ID)
+		if err != nil {
+			return err
+		}
+		if active >= key.MaxActiveMailboxes {
+			return ErrActiveQuota
+		}
+	}
+
+	return nil
+}
+
+// recordCreated учитывает созданный ящик в метриках и счётчиках ключа
+func (s *MailboxService) recordCreated(ctx context.Context, mailbox *domain.Mailbox) {
+	s.observer.MailboxCreated()
+	if mailbox.APIKeyID == "" {
+		return
+	}
+	// Неудачный учёт не должен мешать созданию ящика
+	_ = s.keys.RecordMailboxCreated(ctx, mailbox.APIKeyID)
+}
+
+// ListByAPIKey возвращает ящики, созданные ключом
+func (s *MailboxService) ListByAPIKey(ctx context.Context, apiKeyID string) ([]*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.ListByAPIKey")
+	defer span.End()
+
+	return s.repo.ListByAPIKey(ctx, apiKeyID)
+}
+
+// CheckAccess проверяет, что клиент с ключом key может работать с ящиком
+// Ящик, созданный с ключом, доступен только с этим ключом; чужим он не виден
+// Анонимные ящики доступны всем, кто знает их ID
+func (s *MailboxService) CheckAccess(ctx context.Context, id string, key *domain.APIKey) error {
+	ctx, span := tracing.Start(ctx, "MailboxService.CheckAccess")
+	defer span.End()
+
+	mailbox, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	// Отсутствующий ящик обработает сам запрос
+	if mailbox == nil || mailbox.APIKeyID == "" {
+		return nil
+	}
+	if key == nil || key.ID != mailbox.APIKeyID {
+		return ErrMailboxNotFound
+	}
+	return nil
+}
+
+// createRandom сохраняет ящик со случайным адресом
+// При коллизии адреса пробует новый, пока не исчерпает generateAttempts
+func (s *MailboxService) createRandom(ctx context.Context, mailbox *domain.Mailbox) error {
+	for attempt := 0; attempt < generateAttempts; attempt++ {
+		local, err := s.generator.Generate()
+		if err != nil {
+			return err
+		}
+		// Случайно получившееся служебное или запрещённое имя не выдаём
+		if s.policy.checkReserved(local) != nil {
+			continue
+		}
+		mailbox.Address = fmt.Sprintf("%s@%s", local, s.config.Domain)
+
+		err = s.repo.Create(ctx, mailbox)
+		if errors.Is(err, repository.ErrDuplicate) {
+			continue
+		}
+		return err
+	}
+	return ErrAddressSpace
+}
+
+// Extend продлевает срок действия ящика на ttl
+// Отсчёт идёт от текущего срока истечения; итоговый остаток не превышает MaxTTL,
+// а сам срок — предел жизни ящика (MaxLifetime от момента создания)
+func (s *MailboxService) Extend(ctx context.Context, id string, ttl time.Duration) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.Extend")
+	defer span.End()
+
+	if ttl <= 0 {
+		ttl = s.config.DefaultTTL
+	}
+	if ttl > s.config.MaxTTL {
+		return nil, ErrInvalidTTL
+	}
+
+	mailbox, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Деактивированный ящик продлить нельзя — только восстановить
+	if !mailbox.AcceptsMail() {
+		return nil, ErrMailboxExpired
+	}
+
+	now := time.Now()
+	next := mailbox.ExpiresAt.Add(ttl)
+	if limit := now.Add(s.config.MaxTTL); next.After(limit) {
+		next = limit
+	}
+	if next.After(mailbox.MaxExpiresAt) {
+		next = mailbox.MaxExpiresAt
+	}
+
+	// Продлевать дальше некуда
+	if !next.After(mailbox.ExpiresAt) {
+		return nil, ErrLifetimeReached
+	}
+
+	if err := s.repo.UpdateExpiresAt(ctx, mailbox.ID, next); err != nil {
+		return nil, err
+	}
+
+	mailbox.ExpiresAt = next
+	return mailbox, nil
+}
+
+// Restore восстанавливает истёкший ящик в течение льготного периода
+// Новый срок действия отсчитывается от текущего момента и ограничен пределом жизни ящика
+func (s *MailboxService) Restore(ctx context.Context, id string, ttl time.Duration) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.Restore")
+	defer span.End()
+
 	if ttl <= 0 {
 		ttl = s.config.DefaultTTL
 	}
@@ -51,23 +322,117 @@ func (s *MailboxService) Create(address string, ttl time.Duration) (*domain.Mail
 		return nil, ErrInvalidTTL
 	}
 
-	// Проверяем, не занят ли адрес
-	existing, err := s.repo.GetByAddress(address)
+	mailbox, err := s.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	if existing != nil {
-		// Адрес занят — генерируем новый
-		address = s.generateRandomAddress()
+	if mailbox.AcceptsMail() {
+		return nil, ErrMailboxActive
+	}
+
+	now := time.Now()
+	next := now.Add(ttl)
+	if next.After(mailbox.MaxExpiresAt) {
+		next = mailbox.MaxExpiresAt
+	}
+	if !next.After(now) {
+		return nil, ErrLifetimeReached
+	}
+
+	if err := s.repo.Restore(ctx, mailbox.ID, next); err != nil {
+		return nil, err
+	}
+
+	mailbox.ExpiresAt = next
+	mailbox.IsActive = true
+	return mailbox, nil
+}
+
+// Cleanup деактивирует истёкшие ящики и удаляет те, у которых закончился льготный период
+func (s *MailboxService) Cleanup(ctx context.Context) (deactivated, deleted int64, err error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.Cleanup")
+	defer span.End()
+
+	start := time.Now()
+	defer func() {
+		s.observer.CleanupFinished(time.Since(start), deactivated, deleted, err)
+	}()
+
+	deactivated, err = s.repo.DeactivateExpired(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	deleted, err = s.repo.DeleteExpired(ctx, time.Now().Add(-s.config.GracePeriod))
+	if err != nil {
+		return deactivated, 0, err
+	}
+
+	s.lastCleanup.Store(time.Now().UnixNano())
+	return deactivated, deleted, nil
+}
+
+// CheckCleanup проверяет, что очистка истёкших ящиков не отстала
+// Очистка считается зависшей, если не проходила успешно три интервала подряд
+func (s *MailboxService) CheckCleanup(ctx context.Context) error {
+	last := time.Unix(0, s.lastCleanup.Load())
+	if since := time.Since(last); since > cleanupStaleIntervals*s.config.CleanupInterval {
+		return fmt.Errorf("очистка не проходила %s", since.Truncate(time.Second))
 	}
+	return nil
+}
+
+// cleanupStaleIntervals — через сколько интервалов без успешной очистки она считается зависшей
+const cleanupStaleIntervals = 3
+
+// RunCleanup запускает Cleanup каждые CleanupInterval, пока не закрыт канал stop
+func (s *MailboxService) RunCleanup(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.config.CleanupInterval)
+	defer ticker.Stop()
 
-	// Создаём ящик
-	return s.repo.Create(address, ttl)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			deactivated, deleted, err := s.Cleanup(context.Background())
+			if err != nil {
+				s.logger.Error("mailbox cleanup failed", logging.Err(err))
+				continue
+			}
+			if deactivated > 0 || deleted > 0 {
+				s.logger.Info("mailbox cleanup finished",
+					slog.Int64("deactivated", deactivated),
+					slog.Int64("deleted", deleted),
+				)
+			}
+		}
+	}
+}
+
+// maxLifetime возвращает предел жизни ящика; он не бывает меньше начального TTL
+func (s *MailboxService) maxLifetime(ttl time.Duration) time.Duration {
+	if s.config.MaxLifetime < ttl {
+		return ttl
+	}
+	return s.config.MaxLifetime
+}
+
+// IsLocalDomain проверяет, обслуживается ли домен сервером:
+// это основной домен из конфигурации или любой его поддомен
+func (s *MailboxService) IsLocalDomain(name string) bool {
+	name = strings.ToLower(name)
+	base := strings.ToLower(s.config.Domain)
+	return name == base || strings.HasSuffix(name, "."+base)
 }
 
 // GetByID возвращает ящик по ID
-func (s *MailboxService) GetByID(id string) (*domain.Mailbox, error) {
-	mailbox, err := s.repo.GetByID(id)
+// Истёкший ящик возвращается в течение льготного периода (с IsActive = false)
+func (s *MailboxService) GetByID(ctx context.Context, id string) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.GetByID")
+	defer span.End()
+
+	mailbox, err := s.repo.GetByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
@@ -75,48 +440,139 @@ func (s *MailboxService) GetByID(id string) (*domain.Mailbox, error) {
 		return nil, ErrMailboxNotFound
 	}
 
-	// Проверяем, не истёк ли срок
-	if mailbox.IsExpired() {
+	// После льготного периода ящик считается исчезнувшим
+	if mailbox.IsGone(s.config.GracePeriod) {
 		return nil, ErrMailboxExpired
 	}
 
+	// В льготный период ящик доступен, но уже не активен,
+	// даже если очистка ещё не успела его деактивировать
+	if mailbox.IsExpired() {
+		mailbox.IsActive = false
+	}
+
 	return mailbox, nil
 }
 
 // Delete удаляет почтовый ящик
-func (s *MailboxService) Delete(id string) error {
+func (s *MailboxService) Delete(ctx context.Context, id string) error {
+	ctx, span := tracing.Start(ctx, "MailboxService.Delete")
+	defer span.End()
+
 	// Проверяем существование
-	_, err := s.GetByID(id)
+	_, err := s.GetByID(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	return s.repo.Delete(id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.observer.MailboxDeleted()
+	return nil
 }
 
-// generateRandomAddress генерирует случайный email-адрес
-func (s *MailboxService) generateRandomAddress() string {
-	// Символы для генерации
-	const chars = "abcdefghijklmnopqrstuvwxyz0123456789"
+// GetByAddress возвращает ящик, в который должно попасть письмо для address
+// Порядок поиска:
+//  1. ящик с точно таким адресом (после нормализации регистра и точек);
+//  2. ящик без подадреса (box+tag@domain → box@domain);
+//  3. ящик-шаблон; из нескольких подходящих выбирается самый точный,
+//     при равной точности — созданный раньше. Служебные адреса (postmaster@,
+//     abuse@ и др.) через шаблоны не находятся.
+func (s *MailboxService) GetByAddress(ctx context.Context, address string) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.GetByAddress")
+	defer span.End()
+
+	// Ящики хранятся с нормализованными адресами (регистр, точки)
+	normalized := s.policy.NormalizeAddress(address)
+
+	mailbox, err := s.lookupActive(ctx, normalized)
+	if err != nil {
+		return nil, err
+	}
+	if mailbox != nil {
+		return mailbox, nil
+	}
 
-	// Генерируем 10 случайных символов
-	result := make([]byte, 10)
-	for i := range result {
-		result[i] = chars[rand.Intn(len(chars))]
+	// Точного совпадения нет — пробуем отбросить подадрес
+	if base, _ := s.SplitSubaddress(address); base != address {
+		mailbox, err = s.lookupActive(ctx, s.policy.NormalizeAddress(base))
+		if err != nil {
+			return nil, err
+		}
+		if mailbox != nil {
+			return mailbox, nil
+		}
 	}
 
-	return fmt.Sprintf("%s@%s", string(result), s.config.Domain)
+	// Служебные имена нельзя занять, поэтому и шаблон их не перехватывает;
+	// тег подадреса не спасает: postmaster+x@ — тоже postmaster@
+	base, _ := s.SplitSubaddress(normalized)
+	if s.policy.IsReserved(localPart(normalized)) || s.policy.IsReserved(localPart(base)) {
+		return nil, nil
+	}
+
+	return s.lookupWildcard(ctx, normalized)
 }
 
-// init вызывается при загрузке пакета
-// Инициализируем генератор случайных чисел
-func init() {
-	rand.Seed(time.Now().UnixNano())
+// localPart возвращает часть адреса до последнего «@»
+func localPart(address string) string {
+	if at := strings.LastIndex(address, "@"); at >= 0 {
+		return address[:at]
+	}
+	return address
+}
+
+// lookupWildcard ищет самый точный действующий ящик-шаблон для адреса
+func (s *MailboxService) lookupWildcard(ctx context.Context, address string) (*domain.Mailbox, error) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return nil, nil
+	}
+
+	candidates, err := s.repo.GetWildcardsByDomain(ctx, address[at+1:])
+	if err != nil {
+		return nil, err
+	}
+
+	var best *domain.Mailbox
+	for _, candidate := range candidates {
+		if candidate.IsExpired() || !candidate.Matches(address) {
+			continue
+		}
+		// Кандидаты отсортированы по дате создания, поэтому при равной
+		// точности остаётся более старый ящик
+		if best == nil || candidate.Specificity() > best.Specificity() {
+			best = candidate
+		}
+	}
+
+	return best, nil
+}
+
+// SplitSubaddress отделяет тег подадреса от адреса
+// "box+signup-42@domain" → ("box@domain", "signup-42")
+// Если разделителя нет, адрес возвращается без изменений и с пустым тегом
+func (s *MailboxService) SplitSubaddress(address string) (base, tag string) {
+	at := strings.LastIndex(address, "@")
+	if at <= 0 || s.config.SubaddressSeparators == "" {
+		return address, ""
+	}
+
+	local, domainPart := address[:at], address[at:]
+
+	// Тегом считается всё, что идёт после первого разделителя
+	sep := strings.IndexAny(local, s.config.SubaddressSeparators)
+	if sep <= 0 {
+		return address, ""
+	}
+
+	return local[:sep] + domainPart, local[sep+1:]
 }
 
-// GetByAddress возвращает ящик по email-адресу
-func (s *MailboxService) GetByAddress(address string) (*domain.Mailbox, error) {
-	mailbox, err := s.repo.GetByAddress(address)
+// lookupActive ищет действующий (не истёкший) ящик по точному адресу
+func (s *MailboxService) lookupActive(ctx context.Context, address string) (*domain.Mailbox, error) {
+	mailbox, err := s.repo.GetByAddress(ctx, address)
 	if err != nil {
 		return nil, err
 	}
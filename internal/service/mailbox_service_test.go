@@ -0,0 +1,78 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/service"
+	"tempmail/internal/storage"
+)
+
+// TestWildcardCannotCatchAll проверяет, что шаблон без букв и цифр не создаётся,
+// а служебные адреса не находятся через шаблон, даже с тегом подадреса
+func TestWildcardCannotCatchAll(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(config.StorageConfig{Backend: "memory"}, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	mail := config.MailConfig{
+		Domain:               "tempmail.test",
+		DefaultTTL:           time.Hour,
+		MaxTTL:               24 * time.Hour,
+		MaxLifetime:          48 * time.Hour,
+		GracePeriod:          time.Hour,
+		AddressLength:        10,
+		AddressDots:          service.DotsKeep,
+		ReservedAddresses:    []string{"postmaster", "abuse"},
+		SubaddressSeparators: "+",
+	}
+	generator, err := service.NewAddressGenerator(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := service.NewAddressPolicy(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailboxes := service.NewMailboxService(store.Mailboxes, store.APIKeys, mail, generator, policy, nil, nil)
+
+	for _, pattern := range []string{"*", "**", "*.*"} {
+		_, err := mailboxes.Create(ctx, service.CreateOptions{Address: pattern + "@tempmail.test"})
+		if !errors.Is(err, service.ErrInvalidAddress) {
+			t.Errorf("Create(%q) error = %v, want ErrInvalidAddress", pattern, err)
+		}
+	}
+
+	// «*ster» подходит под postmaster, «a*» — под abuse
+	for _, pattern := range []string{"*ster", "a*"} {
+		if _, err := mailboxes.Create(ctx, service.CreateOptions{Address: pattern + "@tempmail.test"}); err != nil {
+			t.Fatalf("Create(%q): %v", pattern, err)
+		}
+	}
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{"postmaster@tempmail.test", false},
+		{"postmaster+x@tempmail.test", false},
+		{"abuse@tempmail.test", false},
+		{"alice@tempmail.test", true},
+		{"tester@tempmail.test", true},
+	}
+	for _, tt := range tests {
+		mailbox, err := mailboxes.GetByAddress(ctx, tt.address)
+		if err != nil {
+			t.Fatalf("GetByAddress(%q): %v", tt.address, err)
+		}
+		if got := mailbox != nil; got != tt.want {
+			t.Errorf("GetByAddress(%q) found = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
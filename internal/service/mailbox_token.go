@@ -0,0 +1,57 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"tempmail/internal/domain"
+	"tempmail/internal/tracing"
+)
+
+// ErrInvalidCredentials — неверный адрес ящика или токен
+var ErrInvalidCredentials = errors.New("неверный адрес или токен")
+
+// tokenBytes — длина токена ящика в байтах (в hex — вдвое длиннее)
+const tokenBytes = 32
+
+// newMailboxToken генерирует токен ящика и его хеш для хранения в БД
+func newMailboxToken() (token, hash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(buf)
+	return token, hashToken(token), nil
+}
+
+// hashToken возвращает SHA-256 токена в hex
+// Токен случайный и длинный, поэтому соль и медленный хеш не нужны
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Authenticate проверяет адрес ящика и его токен
+// Используется для входа на submission-порт; ящик должен принимать почту
+func (s *MailboxService) Authenticate(ctx context.Context, address, token string) (*domain.Mailbox, error) {
+	ctx, span := tracing.Start(ctx, "MailboxService.Authenticate")
+	defer span.End()
+
+	mailbox, err := s.repo.GetByAddress(ctx, s.policy.NormalizeAddress(address))
+	if err != nil {
+		return nil, err
+	}
+	if mailbox == nil || mailbox.TokenHash == "" || !mailbox.AcceptsMail() {
+		return nil, ErrInvalidCredentials
+	}
+
+	// Сравнение за постоянное время не выдаёт, сколько символов совпало
+	if subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(mailbox.TokenHash)) != 1 {
+		return nil, ErrInvalidCredentials
+	}
+	return mailbox, nil
+}
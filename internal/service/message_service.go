@@ -1,11 +1,13 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"tempmail/internal/config"
 	"tempmail/internal/domain"
-	"tempmail/internal/repository"
+	"tempmail/internal/tracing"
 )
 
 // Ошибки сервиса
@@ -13,32 +15,42 @@ var (
 	ErrMessageNotFound = errors.New("письмо не найдено")
 	ErrMailboxFull     = errors.New("ящик переполнен")
 	ErrMessageTooLarge = errors.New("письмо слишком большое")
+	ErrNoRawSource     = errors.New("исходный текст письма не сохранён")
 )
 
 // MessageService — сервис для работы с письмами
 type MessageService struct {
-	msgRepo     *repository.MessageRepository
-	mailboxRepo *repository.MailboxRepository
+	msgRepo     MessageStore
+	mailboxRepo MailboxStore
 	limits      config.LimitsConfig
+	mail        config.MailConfig
+	observer    Observer
 }
 
 // NewMessageService создаёт новый сервис
 func NewMessageService(
-	msgRepo *repository.MessageRepository,
-	mailboxRepo *repository.MailboxRepository,
+	msgRepo MessageStore,
+	mailboxRepo MailboxStore,
 	limits config.LimitsConfig,
+	mail config.MailConfig,
+	observer Observer,
 ) *MessageService {
 	return &MessageService{
 		msgRepo:     msgRepo,
 		mailboxRepo: mailboxRepo,
 		limits:      limits,
+		mail:        mail,
+		observer:    observerOrNop(observer),
 	}
 }
 
 // Create создаёт новое письмо
-func (s *MessageService) Create(msg *domain.Message) error {
+func (s *MessageService) Create(ctx context.Context, msg *domain.Message) error {
+	ctx, span := tracing.Start(ctx, "MessageService.Create")
+	defer span.End()
+
 	// Проверяем существование ящика
-	mailbox, err := s.mailboxRepo.GetByID(msg.MailboxID)
+	mailbox, err := s.mailboxRepo.GetByID(ctx, msg.MailboxID)
 	if err != nil {
 		return err
 	}
@@ -46,13 +58,13 @@ func (s *MessageService) Create(msg *domain.Message) error {
 		return ErrMailboxNotFound
 	}
 
-	// Проверяем, не истёк ли срок ящика
-	if mailbox.IsExpired() {
+	// Истёкший или деактивированный ящик почту не принимает
+	if !mailbox.AcceptsMail() {
 		return ErrMailboxExpired
 	}
 
 	// Проверяем количество писем в ящике
-	count, err := s.msgRepo.CountByMailboxID(msg.MailboxID)
+	count, err := s.msgRepo.CountByMailboxID(ctx, msg.MailboxID)
 	if err != nil {
 		return err
 	}
@@ -66,13 +78,23 @@ func (s *MessageService) Create(msg *domain.Message) error {
 		return ErrMessageTooLarge
 	}
 
-	return s.msgRepo.Create(msg)
+	if err := s.msgRepo.Create(ctx, msg); err != nil {
+		return err
+	}
+	s.observer.MessageStored(msg.IsSpam)
+
+	// Получение письма — активность в ящике
+	s.touchMailbox(ctx, mailbox)
+	return nil
 }
 
-// GetByMailboxID возвращает все письма ящика
-func (s *MessageService) GetByMailboxID(mailboxID string) ([]*domain.Message, error) {
+// GetByMailboxID возвращает письма ящика, подходящие под фильтр
+func (s *MessageService) GetByMailboxID(ctx context.Context, mailboxID string, filter domain.MessageFilter) ([]*domain.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetByMailboxID")
+	defer span.End()
+
 	// Проверяем существование ящика
-	mailbox, err := s.mailboxRepo.GetByID(mailboxID)
+	mailbox, err := s.mailboxRepo.GetByID(ctx, mailboxID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,34 +102,103 @@ func (s *MessageService) GetByMailboxID(mailboxID string) ([]*domain.Message, er
 		return nil, ErrMailboxNotFound
 	}
 
-	return s.msgRepo.GetByMailboxID(mailboxID)
+	// В льготный период письма ещё можно читать, после него — нет
+	if mailbox.IsGone(s.mail.GracePeriod) {
+		return nil, ErrMailboxExpired
+	}
+
+	// Чтение списка писем — тоже активность в ящике
+	s.touchMailbox(ctx, mailbox)
+
+	return s.msgRepo.GetByMailboxID(ctx, mailboxID, filter)
 }
 
-// GetByID возвращает письмо по ID
-func (s *MessageService) GetByID(id string) (*domain.Message, error) {
-	msg, err := s.msgRepo.GetByID(id)
+// GetByID возвращает письмо ящика mailboxID по ID
+// Письмо в карантине для владельца ящика не существует
+func (s *MessageService) GetByID(ctx context.Context, mailboxID, id string) (*domain.Message, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetByID")
+	defer span.End()
+
+	msg, err := s.mailboxMessage(ctx, mailboxID, id)
 	if err != nil {
 		return nil, err
 	}
-	if msg == nil {
-		return nil, ErrMessageNotFound
-	}
 
 	// Помечаем как прочитанное
-	_ = s.msgRepo.MarkAsRead(id)
+	_ = s.msgRepo.MarkAsRead(ctx, id)
 
 	return msg, nil
 }
 
-// Delete удаляет письмо
-func (s *MessageService) Delete(id string) error {
-	msg, err := s.msgRepo.GetByID(id)
+// GetRawSource возвращает исходный текст письма ящика mailboxID в формате RFC 5322
+func (s *MessageService) GetRawSource(ctx context.Context, mailboxID, id string) ([]byte, error) {
+	ctx, span := tracing.Start(ctx, "MessageService.GetRawSource")
+	defer span.End()
+
+	if _, err := s.mailboxMessage(ctx, mailboxID, id); err != nil {
+		return nil, err
+	}
+
+	raw, err := s.msgRepo.GetRawSource(ctx, id)
 	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, ErrNoRawSource
+	}
+	return raw, nil
+}
+
+// Delete удаляет письмо ящика mailboxID
+func (s *MessageService) Delete(ctx context.Context, mailboxID, id string) error {
+	ctx, span := tracing.Start(ctx, "MessageService.Delete")
+	defer span.End()
+
+	if _, err := s.mailboxMessage(ctx, mailboxID, id); err != nil {
 		return err
 	}
-	if msg == nil {
-		return ErrMessageNotFound
+
+	if err := s.msgRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.observer.MessageDeleted()
+	return nil
+}
+
+// mailboxMessage загружает письмо и проверяет, что оно лежит в ящике mailboxID
+// Как и список писем, отдельные письма доступны до конца льготного периода ящика
+// Письмо из чужого ящика, как и письмо в карантине, для владельца не существует:
+// доступ проверяется по ящику, и по одному ID письма читать чужое нельзя
+func (s *MessageService) mailboxMessage(ctx context.Context, mailboxID, id string) (*domain.Message, error) {
+	mailbox, err := s.mailboxRepo.GetByID(ctx, mailboxID)
+	if err != nil {
+		return nil, err
+	}
+	if mailbox == nil {
+		return nil, ErrMailboxNotFound
+	}
+	if mailbox.IsGone(s.mail.GracePeriod) {
+		return nil, ErrMailboxExpired
 	}
 
-	return s.msgRepo.Delete(id)
+	msg, err := s.msgRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil || msg.MailboxID != mailboxID || msg.IsQuarantined {
+		return nil, ErrMessageNotFound
+	}
+	return msg, nil
+}
+
+// touchMailbox продлевает срок ящика, если для него включено автопродление
+func (s *MessageService) touchMailbox(ctx context.Context, mailbox *domain.Mailbox) {
+	// Деактивированный ящик продлевается только явным восстановлением
+	if !mailbox.AcceptsMail() {
+		return
+	}
+	if next, ok := mailbox.ActivityExpiry(time.Now()); ok {
+		// Неудачное продление не должно мешать основной операции
+		_ = s.mailboxRepo.UpdateExpiresAt(ctx, mailbox.ID, next)
+	}
 }
@@ -0,0 +1,61 @@
+package service
+
+import "time"
+
+// Observer получает события сервисов: создание и удаление ящиков и писем, очистку
+// Через него сервисы сообщают о событиях метрикам и статистике,
+// не зная, кто и как их учитывает. Методы вызываются синхронно
+// и не должны блокироваться.
+type Observer interface {
+	MailboxCreated()                                                               // Создан ящик
+	MailboxDeleted()                                                               // Ящик удалён владельцем или администратором
+	MessageStored(spam bool)                                                       // Сохранено письмо
+	MessageDeleted()                                                               // Письмо удалено владельцем
+	CleanupFinished(duration time.Duration, deactivated, deleted int64, err error) // Завершена очистка истёкших ящиков
+}
+
+// Observers рассылает события всем наблюдателям по порядку
+type Observers []Observer
+
+// MailboxCreated реализует Observer
+func (o Observers) MailboxCreated() {
+	for _, observer := range o {
+		observer.MailboxCreated()
+	}
+}
+
+// MailboxDeleted реализует Observer
+func (o Observers) MailboxDeleted() {
+	for _, observer := range o {
+		observer.MailboxDeleted()
+	}
+}
+
+// MessageStored реализует Observer
+func (o Observers) MessageStored(spam bool) {
+	for _, observer := range o {
+		observer.MessageStored(spam)
+	}
+}
+
+// MessageDeleted реализует Observer
+func (o Observers) MessageDeleted() {
+	for _, observer := range o {
+		observer.MessageDeleted()
+	}
+}
+
+// CleanupFinished реализует Observer
+func (o Observers) CleanupFinished(duration time.Duration, deactivated, deleted int64, err error) {
+	for _, observer := range o {
+		observer.CleanupFinished(duration, deactivated, deleted, err)
+	}
+}
+
+// observerOrNop возвращает observer или пустой список, если наблюдателя нет
+func observerOrNop(observer Observer) Observer {
+	if observer == nil {
+		return Observers(nil)
+	}
+	return observer
+}
@@ -0,0 +1,201 @@
+package service_test
+
+import (
+	"bufio"
+	"context"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/metrics"
+	"tempmail/internal/service"
+	"tempmail/internal/storage"
+)
+
+// scrape возвращает значения метрик из ответа /metrics по имени ряда с метками
+func scrape(t *testing.T, m *metrics.Metrics) map[string]float64 {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	values := make(map[string]float64)
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("metric line %q: %v", line, err)
+		}
+		values[line[:i]] = value
+	}
+	return values
+}
+
+// TestObserversFeedStatsAndMetrics проходит жизненный цикл ящика и писем
+// с наблюдателями как в cmd/api и проверяет, что каждое событие попадает
+// и в постоянную статистику, и в счётчики Prometheus ровно один раз
+func TestObserversFeedStatsAndMetrics(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(config.StorageConfig{Backend: "memory"}, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	mail := config.MailConfig{
+		Domain:        "tempmail.test",
+		DefaultTTL:    time.Hour,
+		MaxTTL:        24 * time.Hour,
+		MaxLifetime:   48 * time.Hour,
+		GracePeriod:   time.Hour,
+		AddressLength: 10,
+		AddressDots:   service.DotsKeep,
+	}
+	generator, err := service.NewAddressGenerator(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := service.NewAddressPolicy(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appMetrics := metrics.New()
+	statsService := service.NewStatsService(store.Stats, config.StatsConfig{}, nil)
+	appMetrics.SetRecorder(statsService)
+	observer := service.Observers{appMetrics, statsService}
+
+	mailboxes := service.NewMailboxService(store.Mailboxes, store.APIKeys, mail, generator, policy, observer, nil)
+	messages := service.NewMessageService(store.Messages, store.Mailboxes,
+		config.LimitsConfig{MaxMessageSize: 1 << 20, MaxMessagesPerMailbox: 10}, mail, observer)
+
+	// check сверяет итоги статистики и счётчики Prometheus
+	// Между шагами часть приращений уже записана в хранилище, часть ещё в буфере
+	check := func(step string, wantStats map[string]int64, wantMetrics map[string]float64) {
+		t.Helper()
+		totals, err := statsService.Totals(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range wantStats {
+			if totals[name] != want {
+				t.Errorf("%s: stats %s = %d, want %d", step, name, totals[name], want)
+			}
+		}
+		values := scrape(t, appMetrics)
+		for series, want := range wantMetrics {
+			if values[series] != want {
+				t.Errorf("%s: %s = %v, want %v", step, series, values[series], want)
+			}
+		}
+	}
+
+	// Создание ящиков
+	kept, err := mailboxes.Create(ctx, service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, err := mailboxes.Create(ctx, service.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("mailboxes created",
+		map[string]int64{domain.StatMailboxesCreated: 2},
+		map[string]float64{"tempmail_mailboxes_created_total": 2},
+	)
+	if err := statsService.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Сохранение писем, одно из них — спам
+	var stored []*domain.Message
+	for _, spam := range []bool{false, true} {
+		msg := &domain.Message{
+			MailboxID:   kept.ID,
+			FromAddress: "alice@example.com",
+			Recipient:   kept.Address,
+			Subject:     "hello",
+			BodyText:    "hello",
+			ReceivedAt:  time.Now(),
+			IsSpam:      spam,
+		}
+		if err := messages.Create(ctx, msg); err != nil {
+			t.Fatal(err)
+		}
+		stored = append(stored, msg)
+	}
+	check("messages stored",
+		map[string]int64{domain.StatMailboxesCreated: 2, domain.StatMessagesStored: 2, domain.StatSpamMessages: 1},
+		map[string]float64{
+			`tempmail_messages_stored_total{spam="false"}`: 1,
+			`tempmail_messages_stored_total{spam="true"}`:  1,
+		},
+	)
+
+	// Удаление письма владельцем
+	if err := messages.Delete(ctx, kept.ID, stored[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	check("message deleted",
+		map[string]int64{domain.StatMessagesStored: 2, domain.StatMessagesDeleted: 1},
+		map[string]float64{"tempmail_messages_deleted_total": 1},
+	)
+	if err := statsService.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Удаление ящика владельцем
+	if err := mailboxes.Delete(ctx, removed.ID); err != nil {
+		t.Fatal(err)
+	}
+	check("mailbox deleted",
+		map[string]int64{domain.StatMailboxesRemoved: 1, domain.StatMailboxesDeleted: 0},
+		map[string]float64{"tempmail_mailboxes_deleted_total": 1},
+	)
+
+	// Очистка: ящик истёк два часа назад, льготный период (час) закончился,
+	// поэтому он и деактивируется, и удаляется за один проход
+	if err := store.Mailboxes.UpdateExpiresAt(ctx, kept.ID, time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	deactivated, deleted, err := mailboxes.Cleanup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deactivated != 1 || deleted != 1 {
+		t.Fatalf("Cleanup = %d deactivated, %d deleted; want 1, 1", deactivated, deleted)
+	}
+	check("cleanup",
+		map[string]int64{
+			domain.StatMailboxesCreated: 2,
+			domain.StatMailboxesExpired: 1,
+			domain.StatMailboxesDeleted: 1,
+			domain.StatMailboxesRemoved: 1,
+			domain.StatMessagesStored:   2,
+			domain.StatMessagesDeleted:  1,
+		},
+		map[string]float64{
+			`tempmail_cleanup_runs_total{result="ok"}`:               1,
+			`tempmail_cleanup_mailboxes_total{action="deactivated"}`: 1,
+			`tempmail_cleanup_mailboxes_total{action="deleted"}`:     1,
+			"tempmail_mailboxes_deleted_total":                       1,
+		},
+	)
+
+	// Всё записанное переживает сброс буфера без двойного счёта
+	if err := statsService.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	check("flushed",
+		map[string]int64{domain.StatMailboxesCreated: 2, domain.StatMessagesStored: 2, domain.StatMailboxesDeleted: 1},
+		nil,
+	)
+}
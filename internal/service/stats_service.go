@@ -0,0 +1,248 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/logging"
+)
+
+// ErrInvalidStatsRange — недопустимые параметры временного ряда
+var ErrInvalidStatsRange = errors.New("недопустимый период или интервал статистики")
+
+const (
+	statsResolution    = time.Hour      // Точность часовых значений
+	statsDay           = 24 * time.Hour // Точность суточных итогов
+	minHourlyRetention = 2 * statsDay   // Меньше нельзя: суточные итоги пересчитываются за двое суток
+)
+
+// statKey — счётчик за один час
+type statKey struct {
+	hour time.Time
+	name string
+}
+
+// StatsService — постоянная статистика сервиса
+//
+// События копятся в памяти и раз в FlushInterval прибавляются к часовым
+// счётчикам в БД, поэтому статистика переживает перезапуски и складывается
+// из всех экземпляров сервиса (API и отдельного SMTP-сервера).
+// Из часовых значений собираются суточные итоги, которые хранятся дольше.
+type StatsService struct {
+	repo   StatsStore
+	cfg    config.StatsConfig
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending map[statKey]int64 // Ещё не записанные в БД приращения
+}
+
+// NewStatsService создаёт новый сервис
+func NewStatsService(repo StatsStore, cfg config.StatsConfig, logger *slog.Logger) *StatsService {
+	cfg.HourlyRetention = max(cfg.HourlyRetention, minHourlyRetention)
+	return &StatsService{
+		repo:    repo,
+		cfg:     cfg,
+		logger:  logging.OrDiscard(logger),
+		pending: make(map[statKey]int64),
+	}
+}
+
+// Add прибавляет delta к счётчику name в текущем часе
+func (s *StatsService) Add(name string, delta int64) {
+	if s == nil || delta == 0 {
+		return
+	}
+	key := statKey{hour: time.Now().UTC().Truncate(statsResolution), name: name}
+
+	s.mu.Lock()
+	s.pending[key] += delta
+	s.mu.Unlock()
+}
+
+// MailboxCreated реализует Observer
+func (s *StatsService) MailboxCreated() {
+	s.Add(domain.StatMailboxesCreated, 1)
+}
+
+// MailboxDeleted реализует Observer
+func (s *StatsService) MailboxDeleted() {
+	s.Add(domain.StatMailboxesRemoved, 1)
+}
+
+// MessageStored реализует Observer
+func (s *StatsService) MessageStored(spam bool) {
+	s.Add(domain.StatMessagesStored, 1)
+	if spam {
+		s.Add(domain.StatSpamMessages, 1)
+	}
+}
+
+// MessageDeleted реализует Observer
+func (s *StatsService) MessageDeleted() {
+	s.Add(domain.StatMessagesDeleted, 1)
+}
+
+// CleanupFinished реализует Observer
+func (s *StatsService) CleanupFinished(_ time.Duration, deactivated, deleted int64, err error) {
+	if err != nil {
+		return
+	}
+	s.Add(domain.StatMailboxesExpired, deactivated)
+	s.Add(domain.StatMailboxesDeleted, deleted)
+}
+
+// Flush записывает накопленные приращения в БД
+// При ошибке приращения возвращаются в буфер и будут записаны в следующий раз
+func (s *StatsService) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[statKey]int64)
+	s.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	values := make([]domain.StatValue, 0, len(pending))
+	for key, value := range pending {
+		values = append(values, domain.StatValue{Time: key.hour, Name: key.name, Value: value})
+	}
+
+	if err := s.repo.Add(ctx, values); err != nil {
+		s.mu.Lock()
+		for key, value := range pending {
+			s.pending[key] += value
+		}
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Rollup пересчитывает суточные итоги за вчера и сегодня
+// и удаляет часовые значения старше HourlyRetention
+func (s *StatsService) Rollup(ctx context.Context) error {
+	now := time.Now()
+	if err := s.repo.Rollup(ctx, startOfDay(now.Add(-statsDay))); err != nil {
+		return err
+	}
+	_, err := s.repo.DeleteHourlyBefore(ctx, now.Add(-s.cfg.HourlyRetention).Truncate(statsResolution))
+	return err
+}
+
+// Run записывает счётчики и собирает итоги, пока не закрыт канал stop
+// Перед выходом записывает то, что успело накопиться
+func (s *StatsService) Run(stop <-chan struct{}) {
+	flush := time.NewTicker(s.cfg.FlushInterval)
+	defer flush.Stop()
+	rollup := time.NewTicker(s.cfg.RollupInterval)
+	defer rollup.Stop()
+
+	// Фоновая работа не относится ни к одному запросу
+	ctx := context.Background()
+	if err := s.Rollup(ctx); err != nil {
+		s.logger.Error("stats rollup failed", logging.Err(err))
+	}
+
+	for {
+		select {
+		case <-stop:
+			if err := s.Flush(ctx); err != nil {
+				s.logger.Error("stats flush failed", logging.Err(err))
+			}
+			return
+		case <-flush.C:
+			if err := s.Flush(ctx); err != nil {
+				s.logger.Error("stats flush failed", logging.Err(err))
+			}
+		case <-rollup.C:
+			if err := s.Rollup(ctx); err != nil {
+				s.logger.Error("stats rollup failed", logging.Err(err))
+			}
+		}
+	}
+}
+
+// Totals возвращает значения всех счётчиков за всё время
+// Учитываются и ещё не записанные приращения этого экземпляра
+func (s *StatsService) Totals(ctx context.Context) (map[string]int64, error) {
+	totals, err := s.repo.Totals(ctx, s.split(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	for key, value := range s.pending {
+		totals[key.name] += value
+	}
+	s.mu.Unlock()
+
+	for _, name := range domain.StatNames {
+		totals[name] += 0 // Счётчики без событий выводим нулями
+	}
+	return totals, nil
+}
+
+// Series возвращает временной ряд за последний период rng с интервалом bucket
+// bucket — целое число часов; последний интервал включает текущий момент
+// Там, где часовых значений уже нет, значения берутся из суточных итогов
+// и попадают в интервал, содержащий начало суток
+func (s *StatsService) Series(ctx context.Context, rng, bucket time.Duration) ([]domain.StatPoint, error) {
+	if bucket < statsResolution || bucket%statsResolution != 0 || rng < bucket {
+		return nil, ErrInvalidStatsRange
+	}
+	if int(rng/bucket) > s.cfg.MaxPoints {
+		return nil, ErrInvalidStatsRange
+	}
+
+	now := time.Now()
+	to := alignUnix(now, bucket).Add(bucket)
+	from := alignUnix(to.Add(-rng), bucket)
+
+	values, err := s.repo.Series(ctx, from, to, s.split(now), bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	// Интервалы без событий тоже нужны: заполняем ряд нулями
+	points := make([]domain.StatPoint, 0, int(to.Sub(from)/bucket))
+	index := make(map[int64]int)
+	for t := from; t.Before(to); t = t.Add(bucket) {
+		point := domain.StatPoint{Time: t.UTC(), Values: make(map[string]int64, len(domain.StatNames))}
+		for _, name := range domain.StatNames {
+			point.Values[name] = 0
+		}
+		index[t.Unix()] = len(points)
+		points = append(points, point)
+	}
+	for _, v := range values {
+		if i, ok := index[v.Time.Unix()]; ok {
+			points[i].Values[v.Name] += v.Value
+		}
+	}
+	return points, nil
+}
+
+// split возвращает начало первых суток, за которые часовые значения сохранились целиком
+// До этого момента статистика берётся из суточных итогов
+func (s *StatsService) split(now time.Time) time.Time {
+	return startOfDay(now.Add(-s.cfg.HourlyRetention)).Add(statsDay)
+}
+
+// startOfDay возвращает полночь UTC суток, в которые попадает t
+func startOfDay(t time.Time) time.Time {
+	return alignUnix(t, statsDay)
+}
+
+// alignUnix округляет t вниз до кратного step от начала эпохи Unix
+// (в отличие от time.Truncate, который считает от нулевого времени Go)
+func alignUnix(t time.Time, step time.Duration) time.Time {
+	sec := int64(step / time.Second)
+	return time.Unix(t.Unix()/sec*sec, 0).UTC()
+}
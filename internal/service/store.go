@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"tempmail/internal/domain"
+)
+
+// Хранилища, от которых зависят сервисы
+//
+// Реализации: repository (PostgreSQL), repository/sqlite (файл SQLite)
+// и repository/memory (в памяти процесса).
+// Общие для всех реализаций правила:
+//   - отсутствие записи — nil без ошибки, а не ошибка;
+//   - занятый уникальный ключ (адрес ящика, хеш ключа) — repository.ErrDuplicate;
+//   - удаление ящика удаляет его письма, удаление API-ключа отвязывает от него ящики.
+
+// MailboxStore хранит почтовые ящики
+type MailboxStore interface {
+	Create(ctx context.Context, mailbox *domain.Mailbox) error
+	GetByID(ctx context.Context, id string) (*domain.Mailbox, error)
+	GetByAddress(ctx context.Context, address string) (*domain.Mailbox, error) // Только активные
+	GetWildcardsByDomain(ctx context.Context, domainName string) ([]*domain.Mailbox, error)
+	ListByAPIKey(ctx context.Context, apiKeyID string) ([]*domain.Mailbox, error)
+	CountCreatedSince(ctx context.Context, apiKeyID string, since time.Time) (int, error)
+	CountActiveByAPIKey(ctx context.Context, apiKeyID string) (int, error)
+	Search(ctx context.Context, filter domain.MailboxSearch) ([]*domain.Mailbox, int, error)
+	ExpireMatching(ctx context.Context, filter domain.MailboxSearch) (int64, error)
+	UpdateExpiresAt(ctx context.Context, id string, expiresAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	Restore(ctx context.Context, id string, expiresAt time.Time) error
+	DeactivateExpired(ctx context.Context) (int64, error)
+	DeleteExpired(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MessageStore хранит письма вместе с их SMTP-конвертами и исходным текстом
+type MessageStore interface {
+	Create(ctx context.Context, msg *domain.Message) error
+	GetByMailboxID(ctx context.Context, mailboxID string, filter domain.MessageFilter) ([]*domain.Message, error)
+	GetByID(ctx context.Context, id string) (*domain.Message, error) // С конвертом
+	GetRawSource(ctx context.Context, id string) ([]byte, error)
+	Search(ctx context.Context, filter domain.MessageSearch) ([]*domain.Message, int, error)
+	SetQuarantined(ctx context.Context, id string, quarantined bool) (bool, error)
+	TopSenders(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error)
+	TopRecipients(ctx context.Context, since time.Time, limit int) ([]domain.AddressCount, error)
+	MarkAsRead(ctx context.Context, id string) error
+	Delete(ctx context.Context, id string) error
+	CountByMailboxID(ctx context.Context, mailboxID string) (int, error)
+}
+
+// APIKeyStore хранит API-ключи и счётчики их использования
+type APIKeyStore interface {
+	Create(ctx context.Context, key *domain.APIKey) error
+	GetByID(ctx context.Context, id string) (*domain.APIKey, error)
+	GetByHash(ctx context.Context, hash string) (*domain.APIKey, error)
+	List(ctx context.Context) ([]*domain.APIKey, error)
+	Update(ctx context.Context, key *domain.APIKey) error
+	Delete(ctx context.Context, id string) error
+	RecordRequest(ctx context.Context, id string) error
+	RecordMailboxCreated(ctx context.Context, id string) error
+}
+
+// StatsStore хранит часовые счётчики статистики и суточные итоги
+type StatsStore interface {
+	Add(ctx context.Context, values []domain.StatValue) error
+	Rollup(ctx context.Context, since time.Time) error
+	DeleteHourlyBefore(ctx context.Context, before time.Time) (int64, error)
+	Series(ctx context.Context, from, to, split time.Time, step time.Duration) ([]domain.StatValue, error)
+	Totals(ctx context.Context, split time.Time) (map[string]int64, error)
+}
+
+// GreylistStore — хранилище триплетов грейлистинга (основное хранилище или Redis)
+type GreylistStore interface {
+	// Attempt регистрирует попытку доставки; возвращает время первой попытки
+	// и признак того, что триплет уже прошёл грейлистинг
+	Attempt(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) (time.Time, bool, error)
+	// Pass отмечает триплет как прошедший
+	Pass(ctx context.Context, t domain.GreylistTriplet, ttl time.Duration) error
+	// Whitelist добавляет сеть клиента в белый список
+	Whitelist(ctx context.Context, network string, ttl time.Duration) error
+	// Whitelisted проверяет, находится ли сеть в белом списке
+	Whitelisted(ctx context.Context, network string) (bool, error)
+	// DeleteExpired удаляет устаревшие записи
+	DeleteExpired(ctx context.Context) (int64, error)
+}
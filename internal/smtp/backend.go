@@ -1,11 +1,21 @@
 package smtp
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
 
 	"github.com/emersion/go-smtp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 
+	"tempmail/internal/dnsbl"
+	"tempmail/internal/logging"
+	"tempmail/internal/mailauth"
+	"tempmail/internal/metrics"
 	"tempmail/internal/service"
+	"tempmail/internal/tracing"
 )
 
 // Backend реализует интерфейс smtp.Backend
@@ -14,6 +24,13 @@ type Backend struct {
 	mailboxService *service.MailboxService // Сервис для проверки ящиков
 	messageService *service.MessageService // Сервис для сохранения писем
 	domain         string                  // Наш домен (tempmail.dev)
+	verifier       *mailauth.Verifier      // Проверка SPF/DKIM/DMARC (nil — выключена)
+	protection     *Protection             // Защита от злоупотреблений (nil — выключена)
+	blocklist      *dnsbl.Checker          // Проверка IP по чёрным спискам (nil — выключена)
+	greylist       *Greylist               // Грейлистинг (nil — выключен)
+	metrics        *metrics.Metrics        // Метрики (nil — без метрик)
+	logger         *slog.Logger            // Логгер; у каждой сессии свой, с session_id
+	submission     bool                    // Бэкенд submission-порта: отправка после входа
 }
 
 // NewBackend создаёт новый SMTP-бэкенд
@@ -21,20 +38,117 @@ func NewBackend(
 	mailboxService *service.MailboxService,
 	messageService *service.MessageService,
 	domain string,
+	verifier *mailauth.Verifier,
+	protection *Protection,
+	blocklist *dnsbl.Checker,
+	greylist *Greylist,
+	m *metrics.Metrics,
+	logger *slog.Logger,
 ) *Backend {
 	return &Backend{
 		mailboxService: mailboxService,
 		messageService: messageService,
 		domain:         domain,
+		verifier:       verifier,
+		protection:     protection,
+		blocklist:      blocklist,
+		greylist:       greylist,
+		metrics:        m,
+		logger:         logging.OrDiscard(logger),
 	}
 }
 
+// forSubmission возвращает копию бэкенда для submission-порта
+func (b *Backend) forSubmission() *Backend {
+	submission := *b
+	submission.submission = true
+	return &submission
+}
+
+// listener возвращает метку порта для метрик
+func (b *Backend) listener() string {
+	if b.submission {
+		return metrics.ListenerSubmission
+	}
+	return metrics.ListenerMX
+}
+
 // NewSession создаёт новую сессию для входящего соединения
 // Вызывается при каждом новом подключении к SMTP-серверу
 func (b *Backend) NewSession(c *smtp.Conn) (smtp.Session, error) {
-	log.Printf("Новое SMTP-соединение от %s", c.Hostname())
-
-	return &Session{
+	ip := remoteIP(c)
+	sessionID := newID()
+	session := &Session{
 		backend: b,
-	}, nil
+		conn:    c,
+		started: time.Now(),
+		logger: b.logger.With(
+			slog.String("session_id", sessionID),
+			slog.String("listener", b.listener()),
+			slog.String("ip", ip.String()),
+		),
+	}
+
+	// Каждое соединение — отдельная трасса: от подключения до сохранения писем
+	ctx, span := tracing.StartServer(context.Background(), "smtp.session",
+		attribute.String("smtp.session_id", sessionID),
+		attribute.String("smtp.listener", b.listener()),
+		attribute.String("client.address", ip.String()),
+		attribute.String("smtp.helo", c.Hostname()),
+	)
+	if sc := span.SpanContext(); sc.IsSampled() {
+		session.logger = session.logger.With(slog.String("trace_id", sc.TraceID().String()))
+	}
+	// Контекст отменяется при завершении сессии: незаконченные запросы к БД прерываются
+	ctx, session.cancel = context.WithCancel(ctx)
+	session.span = span
+	session.ctx = logging.WithContext(ctx, session.logger)
+	session.logger.Info("smtp session started", slog.String("helo", c.Hostname()))
+
+	// На submission-порту клиент входит по токену ящика, списки IP не проверяем
+	if b.submission {
+		b.countSession(c)
+		return &submissionSession{Session: session}, nil
+	}
+
+	// Проверяем IP клиента по чёрным спискам
+	if b.blocklist != nil && !b.protection.trusted(ip) {
+		result := b.blocklist.Check(session.ctx, ip)
+		if result.Reject {
+			listing := result.Rejection()
+			session.logger.Info("smtp session rejected: listed in dnsbl",
+				slog.String("zone", listing.Zone.Name),
+				slog.Any("codes", listing.Codes),
+			)
+			b.metrics.SMTPConnectionRejected(metrics.ReasonDNSBL)
+			// Logout для отклонённого соединения не вызывается — закрываем спан и контекст здесь
+			span.SetStatus(codes.Error, metrics.ReasonDNSBL)
+			span.End()
+			session.cancel()
+			return nil, &smtp.SMTPError{
+				Code:         554,
+				EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+				Message:      fmt.Sprintf("Адрес %s в чёрном списке %s", ip, listing.Zone.Name),
+			}
+		}
+		session.dnsbl = result
+	}
+
+	b.countSession(c)
+	return session, nil
+}
+
+// countSession учитывает в метриках начало сессии соединения, а при его
+// закрытии — конец. Сессия после STARTTLS продолжает ту же и не учитывается
+func (b *Backend) countSession(c *smtp.Conn) {
+	conn := connSession(c.Conn())
+	if conn == nil {
+		return
+	}
+	started := time.Now()
+	listener := b.listener()
+	conn.begin(
+		func() { b.metrics.SMTPSessionStarted(listener) },
+		func() { b.metrics.SMTPSessionEnded(listener, time.Since(started)) },
+	)
 }
@@ -0,0 +1,154 @@
+package smtp
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/logging"
+	"tempmail/internal/service"
+)
+
+// greylistCleanupInterval — как часто удалять устаревшие триплеты
+const greylistCleanupInterval = time.Hour
+
+// Greylist — грейлистинг: первая попытка доставки с незнакомого триплета
+// (сеть клиента, отправитель, получатель) получает временный отказ 451.
+// Настоящие почтовые серверы повторяют доставку, спам-рассылки обычно нет.
+// После успешной доставки сеть клиента попадает в белый список.
+// nil-значение ничего не проверяет
+type Greylist struct {
+	store  service.GreylistStore
+	cfg    config.GreylistConfig
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewGreylist создаёт грейлистинг; если он выключен в конфигурации, возвращает nil
+func NewGreylist(cfg config.GreylistConfig, store service.GreylistStore, logger *slog.Logger) *Greylist {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Greylist{store: store, cfg: cfg, logger: logging.OrDiscard(logger), lastCleanup: time.Now()}
+}
+
+// Check проверяет триплет
+// Возвращает true, если триплет прошёл грейлистинг (доставка после неё
+// добавляет сеть в белый список), и ошибку 451, если доставку нужно отложить
+// Ошибки хранилища не мешают приёму почты
+func (g *Greylist) Check(ctx context.Context, ip net.IP, sender, recipient string) (bool, error) {
+	if g == nil || ip == nil || !g.appliesTo(recipient) {
+		return false, nil
+	}
+	g.maybeCleanup()
+
+	network := greylistNetwork(ip)
+	whitelisted, err := g.store.Whitelisted(ctx, network)
+	if err != nil {
+		g.logger.Error("greylist whitelist lookup failed", logging.Err(err))
+		return false, nil
+	}
+	if whitelisted {
+		return false, nil
+	}
+
+	if sender == "" {
+		sender = "<>"
+	}
+	triplet := domain.GreylistTriplet{
+		Network:   network,
+		Sender:    strings.ToLower(sender),
+		Recipient: strings.ToLower(recipient),
+	}
+
+	firstSeen, passed, err := g.store.Attempt(ctx, triplet, g.cfg.RetryWindow)
+	if err != nil {
+		g.logger.Error("greylist attempt failed", logging.Err(err))
+		return false, nil
+	}
+	if passed {
+		return true, nil
+	}
+
+	// Повтор пришёл слишком рано — откладываем ещё раз
+	if wait := g.cfg.Delay - time.Since(firstSeen); wait > 0 {
+		return false, &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+			Message:      fmt.Sprintf("Грейлистинг: повторите попытку через %s", wait.Round(time.Second)),
+		}
+	}
+
+	if err := g.store.Pass(ctx, triplet, g.cfg.PassTTL); err != nil {
+		g.logger.Error("greylist pass failed", logging.Err(err))
+	}
+	return true, nil
+}
+
+// Delivered добавляет сеть клиента в белый список после успешной доставки
+func (g *Greylist) Delivered(ctx context.Context, ip net.IP) {
+	if g == nil || ip == nil {
+		return
+	}
+	if err := g.store.Whitelist(ctx, greylistNetwork(ip), g.cfg.WhitelistTTL); err != nil {
+		g.logger.Error("greylist whitelist update failed", logging.Err(err))
+	}
+}
+
+// appliesTo проверяет, включён ли грейлистинг для домена получателя
+// Пустой список доменов — грейлистинг для всех наших доменов
+func (g *Greylist) appliesTo(recipient string) bool {
+	if len(g.cfg.Domains) == 0 {
+		return true
+	}
+
+	at := strings.LastIndex(recipient, "@")
+	if at < 0 {
+		return false
+	}
+	name := strings.ToLower(recipient[at+1:])
+
+	for _, d := range g.cfg.Domains {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d != "" && (name == d || strings.HasSuffix(name, "."+d)) {
+			return true
+		}
+	}
+	return false
+}
+
+// maybeCleanup периодически удаляет устаревшие записи в фоне
+func (g *Greylist) maybeCleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if time.Since(g.lastCleanup) < greylistCleanupInterval {
+		return
+	}
+	g.lastCleanup = time.Now()
+
+	go func() {
+		if _, err := g.store.DeleteExpired(context.Background()); err != nil {
+			g.logger.Error("greylist cleanup failed", logging.Err(err))
+		}
+	}()
+}
+
+// greylistNetwork возвращает сеть клиента: /24 для IPv4, /64 для IPv6
+// Крупные почтовые сервисы повторяют доставку с другого адреса той же сети
+func greylistNetwork(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String() + "/24"
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
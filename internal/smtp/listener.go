@@ -0,0 +1,198 @@
+package smtp
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"tempmail/internal/metrics"
+)
+
+// guardListener применяет защиту к каждому новому соединению
+//
+// go-smtp вызывает Backend.NewSession только после HELO/EHLO, а отклонять
+// соединение и ловить клиентов, заговоривших раньше времени, нужно до
+// приветствия. Поэтому проверки выполняются при первой записи в
+// соединение — это и есть приветствие 220.
+type guardListener struct {
+	net.Listener
+	protection *Protection
+}
+
+// newGuardListener оборачивает listener; без защиты возвращает его как есть
+func newGuardListener(l net.Listener, protection *Protection) net.Listener {
+	if protection == nil {
+		return l
+	}
+	return &guardListener{Listener: l, protection: protection}
+}
+
+// Accept принимает соединение, не блокируясь на проверках
+func (l *guardListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	var ip net.IP
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		ip = addr.IP
+	}
+	return &guardedConn{Conn: c, protection: l.protection, ip: ip}, nil
+}
+
+// guardedConn — соединение, проверяемое перед приветствием
+type guardedConn struct {
+	net.Conn
+	protection *Protection
+	ip         net.IP
+
+	checked   bool      // Проверки уже выполнены (пишет только горутина соединения)
+	admitted  bool      // Соединение занимает место в лимите
+	closeOnce sync.Once // Место освобождается один раз
+}
+
+// Write перед первой записью проверяет клиента
+// Отклонённому клиенту вместо приветствия отправляется отказ
+func (c *guardedConn) Write(p []byte) (int, error) {
+	if !c.checked {
+		c.checked = true
+		if err := c.check(); err != nil {
+			c.refuse(err)
+			return 0, net.ErrClosed
+		}
+	}
+	return c.Conn.Write(p)
+}
+
+// check выполняет проверки соединения
+func (c *guardedConn) check() error {
+	admitted, err := c.protection.admit(c.ip)
+	if err != nil {
+		return err
+	}
+	c.admitted = admitted
+
+	if c.protection.trusted(c.ip) || c.protection.greetDelay <= 0 {
+		return nil
+	}
+	return c.waitGreeting()
+}
+
+// waitGreeting выдерживает паузу перед приветствием
+// Честный клиент молча ждёт 220; спам-боты часто шлют команды сразу
+func (c *guardedConn) waitGreeting() error {
+	if err := c.Conn.SetReadDeadline(time.Now().Add(c.protection.greetDelay)); err != nil {
+		return err
+	}
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	buf := make([]byte, 1)
+	n, err := c.Conn.Read(buf)
+	if n > 0 {
+		c.protection.metrics.SMTPConnectionRejected(metrics.ReasonEarlyTalker)
+		c.protection.logger.Info("early talker disconnected", slog.String("ip", c.ip.String()))
+		return errEarlyTalker
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	// Клиент отключился, не дождавшись приветствия
+	return err
+}
+
+// refuse отправляет клиенту отказ и закрывает соединение
+func (c *guardedConn) refuse(err error) {
+	var smtpErr *smtp.SMTPError
+	if errors.As(err, &smtpErr) {
+		c.Conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(c.Conn, "%d %d.%d.%d %s\r\n", smtpErr.Code,
+			smtpErr.EnhancedCode[0], smtpErr.EnhancedCode[1], smtpErr.EnhancedCode[2], smtpErr.Message)
+	}
+	c.Close()
+}
+
+// Close закрывает соединение и освобождает место в лимите
+func (c *guardedConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.admitted {
+			c.protection.release(c.ip)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// sessionListener оборачивает соединения в sessionConn, чтобы SMTP-сессия
+// учитывалась в метриках один раз на соединение
+type sessionListener struct {
+	net.Listener
+}
+
+// Accept принимает соединение
+func (l sessionListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &sessionConn{Conn: c}, nil
+}
+
+// sessionConn — соединение, на котором учитывается SMTP-сессия
+//
+// При STARTTLS go-smtp завершает сессию (Logout) и после нового EHLO
+// создаёт другую, хотя клиент тот же. Поэтому начало сессии учитывает
+// первый NewSession соединения, а конец — закрытие соединения: после
+// STARTTLS клиент может и не прислать EHLO, тогда Logout не будет.
+type sessionConn struct {
+	net.Conn
+
+	mu     sync.Mutex
+	begun  bool   // Сессия уже учтена
+	closed bool   // Соединение закрыто
+	onEnd  func() // Учитывает конец сессии при закрытии
+}
+
+// begin вызывает onStart, если сессия соединения ещё не учтена,
+// и запоминает onEnd до закрытия соединения
+func (c *sessionConn) begin(onStart, onEnd func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.begun || c.closed {
+		return
+	}
+	c.begun = true
+	c.onEnd = onEnd
+	onStart()
+}
+
+// Close закрывает соединение и учитывает конец сессии
+func (c *sessionConn) Close() error {
+	c.mu.Lock()
+	onEnd := c.onEnd
+	c.onEnd = nil
+	c.closed = true
+	c.mu.Unlock()
+
+	if onEnd != nil {
+		onEnd()
+	}
+	return c.Conn.Close()
+}
+
+// connSession находит sessionConn под соединением go-smtp (в том числе
+// под TLS после STARTTLS); nil — соединение принято не через sessionListener
+func connSession(c net.Conn) *sessionConn {
+	if tlsConn, ok := c.(*tls.Conn); ok {
+		c = tlsConn.NetConn()
+	}
+	conn, _ := c.(*sessionConn)
+	return conn
+}
@@ -0,0 +1,101 @@
+package smtp
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/ratelimit"
+)
+
+// greeting — приветствие, которое сервер пишет первым в соединение
+const greeting = "220 tempmail.test ESMTP\r\n"
+
+// startGuarded запускает listener с защитой, который на каждое соединение
+// отвечает приветствием, как go-smtp
+func startGuarded(t *testing.T, cfg config.SMTPConfig) string {
+	t.Helper()
+	protection, err := NewProtection(cfg, ratelimit.NewMemoryStore(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guarded := newGuardListener(l, protection)
+	t.Cleanup(func() { guarded.Close() })
+
+	go func() {
+		for {
+			conn, err := guarded.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write([]byte(greeting))
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// firstLine подключается, при talkFirst сразу отправляет команду
+// и возвращает первую строку ответа сервера
+func firstLine(t *testing.T, addr string, talkFirst bool) string {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	if talkFirst {
+		if _, err := conn.Write([]byte("EHLO spammer.example\r\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("read reply: %v", err)
+	}
+	return line
+}
+
+func TestGreetDelay(t *testing.T) {
+	delay := 200 * time.Millisecond
+
+	tests := []struct {
+		name      string
+		cfg       config.SMTPConfig
+		talkFirst bool
+		wantCode  string
+		minWait   time.Duration
+	}{
+		{"patient client greeted after delay", config.SMTPConfig{GreetDelay: delay}, false, "220", delay},
+		{"early talker rejected", config.SMTPConfig{GreetDelay: delay}, true, "554 5.5.1", 0},
+		{"trusted client not delayed", config.SMTPConfig{GreetDelay: delay, AllowIPs: []string{"127.0.0.1"}}, true, "220", 0},
+		{"zero delay disables check", config.SMTPConfig{}, true, "220", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := startGuarded(t, tt.cfg)
+
+			began := time.Now()
+			line := firstLine(t, addr, tt.talkFirst)
+			if !strings.HasPrefix(line, tt.wantCode) {
+				t.Fatalf("reply = %q, want %s", line, tt.wantCode)
+			}
+			if waited := time.Since(began); waited < tt.minWait {
+				t.Errorf("greeted after %v, want at least %v", waited, tt.minWait)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package smtp
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/emersion/go-smtp"
+
+	"tempmail/internal/config"
+	"tempmail/internal/logging"
+	"tempmail/internal/metrics"
+	"tempmail/internal/ratelimit"
+)
+
+// maxTarpitDelay — предел задержки ответа, чтобы не держать соединение бесконечно
+const maxTarpitDelay = 30 * time.Second
+
+// Ответы сервера при срабатывании защиты
+var (
+	errDeniedIP = &smtp.SMTPError{
+		Code:         554,
+		EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+		Message:      "Приём писем с вашего адреса запрещён",
+	}
+	errTooManyConnections = &smtp.SMTPError{
+		Code:         421,
+		EnhancedCode: smtp.EnhancedCode{4, 7, 0},
+		Message:      "Слишком много соединений, попробуйте позже",
+	}
+	errEarlyTalker = &smtp.SMTPError{
+		Code:         554,
+		EnhancedCode: smtp.EnhancedCode{5, 5, 1},
+		Message:      "Команда отправлена до приветствия сервера",
+	}
+	errMessageRate = &smtp.SMTPError{
+		Code:         450,
+		EnhancedCode: smtp.EnhancedCode{4, 7, 1},
+		Message:      "Слишком много писем, попробуйте позже",
+	}
+	errRecipientRate = &smtp.SMTPError{
+		Code:         450,
+		EnhancedCode: smtp.EnhancedCode{4, 2, 1},
+		Message:      "Ящик получает слишком много писем, попробуйте позже",
+	}
+)
+
+// Protection — защита SMTP-сервера от злоупотреблений: списки IP,
+// лимиты соединений и писем, задержка ответов после ошибок (tarpit)
+// и отключение клиентов, заговоривших до приветствия
+// nil-значение ничего не ограничивает
+type Protection struct {
+	allow ratelimit.IPList // Клиенты без ограничений
+	deny  ratelimit.IPList // Клиенты, которым приём запрещён
+
+	maxConns      int                // Одновременных соединений с одного IP
+	connRate      *ratelimit.Limiter // Новых соединений с одного IP
+	messageRate   *ratelimit.Limiter // Писем с одного IP
+	recipientRate *ratelimit.Limiter // Писем в один ящик
+
+	tarpitDelay time.Duration // Задержка ответа за каждую ошибку клиента
+	greetDelay  time.Duration // Пауза перед приветствием
+
+	metrics *metrics.Metrics // Метрики отклонённых соединений (nil — без метрик)
+	logger  *slog.Logger
+
+	mu    sync.Mutex
+	conns map[string]int // Открытые соединения по ключу IP
+}
+
+// NewProtection создаёт защиту по настройкам SMTP
+func NewProtection(cfg config.SMTPConfig, store ratelimit.Store, m *metrics.Metrics, logger *slog.Logger) (*Protection, error) {
+	allow, err := ratelimit.ParseIPList(cfg.AllowIPs)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := ratelimit.ParseIPList(cfg.DenyIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Protection{
+		allow:         allow,
+		deny:          deny,
+		maxConns:      cfg.MaxConnsPerIP,
+		connRate:      ratelimit.New(store, "smtp-conn", cfg.ConnRate),
+		messageRate:   ratelimit.New(store, "smtp-message", cfg.MessageRate),
+		recipientRate: ratelimit.New(store, "smtp-rcpt", cfg.RecipientRate),
+		tarpitDelay:   cfg.TarpitDelay,
+		greetDelay:    cfg.GreetDelay,
+		metrics:       m,
+		logger:        logging.OrDiscard(logger),
+		conns:         make(map[string]int),
+	}, nil
+}
+
+// trusted сообщает, что клиент в списке разрешённых и ограничения к нему не применяются
+func (p *Protection) trusted(ip net.IP) bool {
+	return p != nil && p.allow.Contains(ip)
+}
+
+// admit решает, принимать ли новое соединение
+// Принятое соединение занимает место в лимите одновременных соединений: его
+// нужно освободить вызовом release
+func (p *Protection) admit(ip net.IP) (bool, error) {
+	if p == nil {
+		return false, nil
+	}
+	if p.deny.Contains(ip) {
+		p.metrics.SMTPConnectionRejected(metrics.ReasonDeniedIP)
+		return false, errDeniedIP
+	}
+	if p.trusted(ip) {
+		return false, nil
+	}
+
+	key := ratelimit.IPKey(ip)
+
+	p.mu.Lock()
+	if p.maxConns > 0 && p.conns[key] >= p.maxConns {
+		p.mu.Unlock()
+		p.metrics.SMTPConnectionRejected(metrics.ReasonTooManyConns)
+		return false, errTooManyConnections
+	}
+	p.conns[key]++
+	p.mu.Unlock()
+
+	if !p.withinLimit(context.Background(), p.connRate, key) {
+		p.release(ip)
+		p.metrics.SMTPConnectionRejected(metrics.ReasonConnRate)
+		return false, errTooManyConnections
+	}
+	return true, nil
+}
+
+// release освобождает место, занятое соединением в admit
+func (p *Protection) release(ip net.IP) {
+	key := ratelimit.IPKey(ip)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[key] <= 1 {
+		delete(p.conns, key)
+		return
+	}
+	p.conns[key]--
+}
+
+// checkMessage проверяет лимит писем с одного IP (команда MAIL FROM)
+func (p *Protection) checkMessage(ctx context.Context, ip net.IP) error {
+	if p == nil || p.trusted(ip) || p.withinLimit(ctx, p.messageRate, ratelimit.IPKey(ip)) {
+		return nil
+	}
+	return errMessageRate
+}
+
+// checkRecipient проверяет лимит писем в один ящик
+func (p *Protection) checkRecipient(ctx context.Context, ip net.IP, mailboxID string) error {
+	if p == nil || p.trusted(ip) || p.withinLimit(ctx, p.recipientRate, mailboxID) {
+		return nil
+	}
+	return errRecipientRate
+}
+
+// withinLimit спрашивает ограничитель; при недоступном хранилище счётчиков
+// письма не отклоняем — лучше пропустить лишнее, чем потерять почту
+func (p *Protection) withinLimit(ctx context.Context, limiter *ratelimit.Limiter, key string) bool {
+	result, err := limiter.Allow(ctx, key)
+	if err != nil {
+		logging.FromContext(ctx, p.logger).Warn("rate limit check failed", logging.Err(err))
+		return true
+	}
+	return result.Allowed
+}
+
+// tarpit задерживает ответ клиенту после ошибки
+// Задержка растёт с каждой ошибкой: перебор адресов становится дорогим
+func (p *Protection) tarpit(ip net.IP, errors int) {
+	if p == nil || p.trusted(ip) || p.tarpitDelay <= 0 {
+		return
+	}
+	time.Sleep(min(time.Duration(errors)*p.tarpitDelay, maxTarpitDelay))
+}
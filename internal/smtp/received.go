@@ -0,0 +1,89 @@
+package smtp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// receivedInfo — данные для заголовка Received (RFC 5321, раздел 4.4)
+type receivedInfo struct {
+	Helo      string    // Имя клиента из HELO/EHLO
+	IP        net.IP    // IP-адрес клиента
+	By        string    // Имя нашего сервера
+	TLS       bool      // Соединение защищено STARTTLS
+	UTF8      bool      // Клиент передал SMTPUTF8
+	Auth      bool      // Клиент вошёл по AUTH (submission-порт)
+	QueueID   string    // Идентификатор письма на нашем сервере
+	Recipient string    // Получатель этой копии письма
+	Date      time.Time // Момент приёма
+}
+
+// header собирает заголовок Received с завершающим CRLF, например:
+//
+//	Received: from mail.example.com ([192.0.2.1])
+//		by tempmail.dev (TempMail) with ESMTPS id 4F1A2B3C4D5E
+//		for <box@tempmail.dev>; Mon, 02 Jan 2006 15:04:05 +0000
+func (r receivedInfo) header() string {
+	var b strings.Builder
+
+	b.WriteString("Received: from ")
+	b.WriteString(sanitizeHeaderValue(r.Helo))
+	if r.IP != nil {
+		fmt.Fprintf(&b, " (%s)", addressLiteral(r.IP))
+	}
+	b.WriteString("\r\n")
+
+	fmt.Fprintf(&b, "\tby %s (TempMail) with %s id %s\r\n", r.By, r.protocol(), r.QueueID)
+	fmt.Fprintf(&b, "\tfor <%s>; %s\r\n", sanitizeHeaderValue(r.Recipient), r.Date.Format(time.RFC1123Z))
+
+	return b.String()
+}
+
+// protocol возвращает протокол приёма для предложения "with"
+// Значения из реестра IANA Mail Transmission Types (RFC 3848, RFC 6531)
+func (r receivedInfo) protocol() string {
+	proto := "ESMTP"
+	if r.UTF8 {
+		proto = "UTF8SMTP"
+	}
+	if r.TLS {
+		proto += "S"
+	}
+	if r.Auth {
+		proto += "A"
+	}
+	return proto
+}
+
+// addressLiteral оформляет IP по правилам RFC 5321: [192.0.2.1] или [IPv6:2001:db8::1]
+func addressLiteral(ip net.IP) string {
+	if ip.To4() != nil {
+		return "[" + ip.String() + "]"
+	}
+	return "[IPv6:" + ip.String() + "]"
+}
+
+// sanitizeHeaderValue убирает из значения символы, которые могли бы сломать заголовок
+// Имя HELO и адрес присылает клиент, поэтому им нельзя доверять
+func sanitizeHeaderValue(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == '(' || r == ')' || r == '<' || r == '>' || r == ';' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// newID генерирует идентификатор письма или сессии: 12 случайных шестнадцатеричных символов
+func newID() string {
+	buf := make([]byte, 6)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand не возвращает ошибок на поддерживаемых платформах
+		return fmt.Sprintf("%012X", time.Now().UnixNano()&0xFFFFFFFFFFFF)
+	}
+	return strings.ToUpper(hex.EncodeToString(buf))
+}
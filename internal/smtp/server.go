@@ -1,62 +1,152 @@
 package smtp
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net"
+	"sync/atomic"
 	"time"
 
 	"github.com/emersion/go-smtp"
 
 	"tempmail/internal/config"
+	"tempmail/internal/dnsbl"
+	"tempmail/internal/logging"
+	"tempmail/internal/mailauth"
+	"tempmail/internal/metrics"
 	"tempmail/internal/service"
 )
 
 // Server — SMTP-сервер для приёма писем
 type Server struct {
-	server  *smtp.Server
-	backend *Backend
-	config  config.ServerConfig
+	server     *smtp.Server // Приём почты из интернета (MX)
+	submission *smtp.Server // Отправка писем владельцами ящиков (nil — выключена)
+	backend    *Backend
+	config     config.ServerConfig
+
+	// Принимают ли порты соединения (для /readyz)
+	serving           atomic.Bool
+	submissionServing atomic.Bool
 }
 
 // NewServer создаёт новый SMTP-сервер
 func NewServer(
 	cfg config.ServerConfig,
 	mailCfg config.MailConfig,
+	smtpCfg config.SMTPConfig,
 	mailboxService *service.MailboxService,
 	messageService *service.MessageService,
-) *Server {
+	verifier *mailauth.Verifier,
+	protection *Protection,
+	blocklist *dnsbl.Checker,
+	greylist *Greylist,
+	m *metrics.Metrics,
+	logger *slog.Logger,
+) (*Server, error) {
 	// Создаём бэкенд
-	backend := NewBackend(mailboxService, messageService, mailCfg.Domain)
+	backend := NewBackend(mailboxService, messageService, mailCfg.Domain, verifier, protection, blocklist, greylist, m, logger)
+
+	// Сертификат включает STARTTLS
+	var tlsConfig *tls.Config
+	if smtpCfg.TLSCert != "" || smtpCfg.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(smtpCfg.TLSCert, smtpCfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка загрузки TLS-сертификата: %w", err)
+		}
+		tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
 
 	// Создаём SMTP-сервер
-	server := smtp.NewServer(backend)
+	server := newSMTPServer(backend, cfg.SMTPPort, mailCfg.Domain, tlsConfig)
 
-	// Настраиваем параметры сервера
-	server.Addr = fmt.Sprintf(":%d", cfg.SMTPPort) // Адрес для прослушивания
-	server.Domain = mailCfg.Domain                 // Наш домен
-	server.ReadTimeout = 30 * time.Second          // Таймаут чтения
-	server.WriteTimeout = 30 * time.Second         // Таймаут записи
-	server.MaxMessageBytes = 10 * 1024 * 1024      // Макс. размер письма (10 MB)
-	server.MaxRecipients = 10                      // Макс. получателей
-	server.AllowInsecureAuth = true                // Разрешаем без TLS (для разработки)
-
-	return &Server{
+	// На MX-порту AUTH не предлагается: почту сюда доставляют без входа
+	server.AllowInsecureAuth = false
+
+	s := &Server{
 		server:  server,
 		backend: backend,
 		config:  cfg,
 	}
+
+	if cfg.SubmissionPort > 0 {
+		s.submission = newSMTPServer(backend.forSubmission(), cfg.SubmissionPort, mailCfg.Domain, tlsConfig)
+		s.submission.AllowInsecureAuth = smtpCfg.AllowInsecureAuth
+
+		if tlsConfig == nil && !smtpCfg.AllowInsecureAuth {
+			backend.logger.Warn("submission port without TLS: AUTH is unavailable, set SMTP_TLS_CERT and SMTP_TLS_KEY")
+		}
+	}
+
+	return s, nil
+}
+
+// newSMTPServer создаёт go-smtp сервер с общими настройками
+func newSMTPServer(backend *Backend, port int, domain string, tlsConfig *tls.Config) *smtp.Server {
+	server := smtp.NewServer(backend)
+
+	// Настраиваем параметры сервера
+	server.Addr = fmt.Sprintf(":%d", port)    // Адрес для прослушивания
+	server.Domain = domain                    // Наш домен
+	server.ReadTimeout = 30 * time.Second     // Таймаут чтения
+	server.WriteTimeout = 30 * time.Second    // Таймаут записи
+	server.MaxMessageBytes = 10 * 1024 * 1024 // Макс. размер письма (10 MB)
+	server.MaxRecipients = 10                 // Макс. получателей
+	server.TLSConfig = tlsConfig              // STARTTLS (nil — выключен)
+
+	return server
 }
 
 // Start запускает SMTP-сервер
 func (s *Server) Start() error {
-	log.Printf("SMTP-сервер запущен на порту %d", s.config.SMTPPort)
-	log.Printf("Домен: %s", s.server.Domain)
+	logger := s.backend.logger
+	logger.Info("smtp server starting", slog.Int("port", s.config.SMTPPort), slog.String("domain", s.server.Domain))
+
+	// Submission-порт работает в отдельной горутине
+	if s.submission != nil {
+		logger.Info("submission server starting", slog.Int("port", s.config.SubmissionPort))
+		go func() {
+			if err := serve(s.submission, &s.submissionServing, nil); err != nil {
+				logger.Error("submission server stopped", logging.Err(err))
+			}
+		}()
+	}
 
-	// ListenAndServe блокирует выполнение
-	return s.server.ListenAndServe()
+	// Serve блокирует выполнение
+	// Защита проверяет каждое соединение до приветствия
+	return serve(s.server, &s.serving, s.backend.protection)
+}
+
+// serve слушает порт сервера и отмечает в serving, что порт принимает соединения
+// protection (nil — без защиты) проверяет соединения до приветствия
+func serve(server *smtp.Server, serving *atomic.Bool, protection *Protection) error {
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	serving.Store(true)
+	defer serving.Store(false)
+	return server.Serve(sessionListener{newGuardListener(listener, protection)})
+}
+
+// CheckListeners проверяет, что SMTP-порты принимают соединения
+func (s *Server) CheckListeners(ctx context.Context) error {
+	if !s.serving.Load() {
+		return errors.New("SMTP-порт не слушает")
+	}
+	if s.submission != nil && !s.submissionServing.Load() {
+		return errors.New("submission-порт не слушает")
+	}
+	return nil
 }
 
 // Close останавливает SMTP-сервер
 func (s *Server) Close() error {
+	if s.submission != nil {
+		s.submission.Close()
+	}
 	return s.server.Close()
 }
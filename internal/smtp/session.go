@@ -2,89 +2,205 @@ package smtp
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"mime"
 	"mime/multipart"
+	"net"
 	"net/mail"
 	"strings"
+	"time"
 
 	"github.com/emersion/go-smtp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
+	"tempmail/internal/dnsbl"
 	"tempmail/internal/domain"
+	"tempmail/internal/logging"
+	"tempmail/internal/mailauth"
+	"tempmail/internal/metrics"
+	"tempmail/internal/tracing"
 )
 
 // Session обрабатывает одну SMTP-сессию (одно письмо)
 type Session struct {
-	backend *Backend // Ссылка на бэкенд
-	from    string   // Адрес отправителя
-	to      []string // Адреса получателей
+	backend  *Backend           // Ссылка на бэкенд
+	conn     *smtp.Conn         // Соединение: IP клиента, имя из HELO/EHLO
+	from     string             // Адрес отправителя
+	mailOpts smtp.MailOptions   // Параметры MAIL FROM (BODY=, SIZE=, SMTPUTF8)
+	to       []recipient        // Получатели, прошедшие проверку RCPT TO
+	errors   int                // Число ошибок клиента за сессию (для tarpit)
+	dnsbl    *dnsbl.Result      // Результат проверки IP по чёрным спискам (nil — не проверялся)
+	started  time.Time          // Начало сессии (для метрик)
+	logger   *slog.Logger       // Логгер с session_id, портом и IP клиента
+	ctx      context.Context    // Контекст сессии: логгер и span для запросов к сервисам
+	span     trace.Span         // Спан всей сессии; закрывается в Logout
+	cancel   context.CancelFunc // Отменяет ctx; вызывается в Logout
+
+	greylistPassed bool // Хотя бы один получатель прошёл грейлистинг
+
+	user *domain.Mailbox // Ящик, вошедший на submission-порт (nil — приём по MX)
 }
 
-// AuthPlain обрабатывает PLAIN-аутентификацию
-// Мы не требуем аутентификацию, поэтому всегда возвращаем nil
-func (s *Session) AuthPlain(username, password string) error {
-	// Аутентификация не требуется для приёма писем
-	return nil
+// recipient — получатель письма, для которого найден ящик
+type recipient struct {
+	address   string // Адрес из RCPT TO
+	mailboxID string // ID ящика, в который попадёт письмо
+	tag       string // Тег подадреса (box+tag@domain), если есть
 }
 
 // Mail вызывается, когда клиент сообщает адрес отправителя (MAIL FROM)
 func (s *Session) Mail(from string, opts *smtp.MailOptions) error {
-	log.Printf("MAIL FROM: %s", from)
+	s.logger.Debug("mail from", slog.String("from", from))
+
+	// На submission-порту отправлять может только вошедший владелец ящика,
+	// и только от имени своего ящика
+	if s.backend.submission {
+		if s.user == nil {
+			s.backend.metrics.SMTPSender(metrics.ReasonAuthRequired)
+			return smtp.ErrAuthRequired
+		}
+		if !s.user.Matches(from) {
+			s.backend.metrics.SMTPSender(metrics.ReasonSenderMismatch)
+			return s.reject(&smtp.SMTPError{
+				Code:         553,
+				EnhancedCode: smtp.EnhancedCode{5, 7, 1},
+				Message:      "Адрес отправителя не совпадает с адресом ящика",
+			})
+		}
+	}
+
+	// Лимит писем с одного IP
+	if err := s.backend.protection.checkMessage(s.ctx, remoteIP(s.conn)); err != nil {
+		s.backend.metrics.SMTPSender(metrics.ReasonRateLimited)
+		return s.reject(err)
+	}
+
+	s.backend.metrics.SMTPSender("")
 	s.from = from
+	if opts != nil {
+		s.mailOpts = *opts
+	}
 	return nil
 }
 
 // Rcpt вызывается для каждого получателя (RCPT TO)
 // Здесь мы проверяем, существует ли почтовый ящик
 func (s *Session) Rcpt(to string, opts *smtp.RcptOptions) error {
-	log.Printf("RCPT TO: %s", to)
+	s.logger.Debug("rcpt to", slog.String("to", to))
+
+	// Поиск ящика, лимиты и грейлистинг попадают в трассу дочерними спанами
+	ctx, span := tracing.Start(s.ctx, "smtp.rcpt")
+	defer span.End()
+
+	// outcome учитывает результат проверки в метриках и в спане
+	outcome := func(reason string) {
+		s.backend.metrics.SMTPRecipient(reason)
+		if reason != "" {
+			span.SetAttributes(attribute.String("smtp.reject_reason", reason))
+		}
+	}
+
+	if s.backend.submission && s.user == nil {
+		outcome(metrics.ReasonAuthRequired)
+		return smtp.ErrAuthRequired
+	}
 
 	// Извлекаем email из формата "Name <email@domain.com>"
 	address := extractEmail(to)
 
-	// Проверяем, что письмо для нашего домена
-	if !strings.HasSuffix(address, "@"+s.backend.domain) {
-		return fmt.Errorf("мы не принимаем письма для домена %s", address)
+	// Проверяем, что письмо для нашего домена или его поддомена
+	at := strings.LastIndex(address, "@")
+	if at < 0 || !s.backend.mailboxService.IsLocalDomain(address[at+1:]) {
+		outcome(metrics.ReasonRelayDenied)
+		return s.reject(fmt.Errorf("мы не принимаем письма для домена %s", address))
 	}
 
 	// Проверяем, существует ли ящик
-	mailbox, err := s.backend.mailboxService.GetByAddress(address)
+	mailbox, err := s.backend.mailboxService.GetByAddress(ctx, address)
 	if err != nil {
-		log.Printf("Ошибка проверки ящика: %v", err)
-		return &smtp.SMTPError{
+		s.logger.Error("mailbox lookup failed", logging.Err(err))
+		outcome(metrics.ReasonLookupError)
+		return s.reject(&smtp.SMTPError{
 			Code:    550,
 			Message: "Почтовый ящик не найден",
-		}
+		})
 	}
 	if mailbox == nil {
-		return &smtp.SMTPError{
+		outcome(metrics.ReasonUnknownMailbox)
+		return s.reject(&smtp.SMTPError{
 			Code:    550,
 			Message: "Почтовый ящик не существует",
+		})
+	}
+
+	// Лимит писем в один ящик
+	if err := s.backend.protection.checkRecipient(ctx, remoteIP(s.conn), mailbox.ID); err != nil {
+		outcome(metrics.ReasonRateLimited)
+		return s.reject(err)
+	}
+
+	// Грейлистинг: незнакомый триплет получает временный отказ
+	// Это не ошибка клиента, поэтому без tarpit
+	// Вошедших на submission-порт не проверяем
+	if s.user == nil && !s.backend.protection.trusted(remoteIP(s.conn)) {
+		passed, err := s.backend.greylist.Check(ctx, remoteIP(s.conn), s.from, address)
+		if err != nil {
+			outcome(metrics.ReasonGreylisted)
+			return err
 		}
+		s.greylistPassed = s.greylistPassed || passed
+	}
+
+	// Если ящик найден не по точному адресу — запоминаем тег подадреса,
+	// в том числе когда box+tag@ подошёл под ящик-шаблон
+	rcpt := recipient{address: address, mailboxID: mailbox.ID}
+	if mailbox.Address != address {
+		_, rcpt.tag = s.backend.mailboxService.SplitSubaddress(address)
 	}
 
 	// Добавляем получателя
-	s.to = append(s.to, address)
+	outcome("")
+	s.to = append(s.to, rcpt)
 	return nil
 }
 
+// reject учитывает ошибку клиента и задерживает ответ (tarpit)
+func (s *Session) reject(err error) error {
+	s.errors++
+	s.backend.protection.tarpit(remoteIP(s.conn), s.errors)
+	return err
+}
+
 // Data вызывается, когда клиент отправляет содержимое письма
 func (s *Session) Data(r io.Reader) error {
-	log.Println("Получение данных письма...")
-
 	// Читаем всё письмо в буфер
 	var buf bytes.Buffer
 	_, err := buf.ReadFrom(r)
 	if err != nil {
 		return err
 	}
+	raw := buf.Bytes()
+	s.backend.metrics.SMTPMessageReceived(len(raw))
+
+	ctx, span := tracing.Start(s.ctx, "smtp.data",
+		attribute.Int("smtp.message.size", len(raw)),
+		attribute.Int("smtp.recipients", len(s.to)),
+	)
+	defer span.End()
 
 	// Парсим письмо
-	msg, err := mail.ReadMessage(&buf)
+	_, parseSpan := tracing.Start(ctx, "smtp.parse")
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
 	if err != nil {
-		log.Printf("Ошибка парсинга письма: %v", err)
+		tracing.Fail(parseSpan, err)
+		parseSpan.End()
+		s.logger.Warn("message parse failed", slog.Int("size", len(raw)), logging.Err(err))
+		s.backend.metrics.SMTPParseFailure()
 		return err
 	}
 
@@ -99,41 +215,139 @@ func (s *Session) Data(r io.Reader) error {
 
 	// Парсим тело письма
 	bodyText, bodyHTML := parseBody(msg.Body, contentType)
+	parseSpan.End()
+
+	// Общая для всех получателей часть письма
+	template := domain.Message{
+		FromAddress: extractEmail(from),
+		Subject:     subject,
+		BodyText:    bodyText,
+		BodyHTML:    bodyHTML,
+		ReceivedAt:  time.Now(),
+		IsRead:      false,
+		IsSpam:      s.dnsbl != nil && s.dnsbl.Spam, // IP в чёрном списке с действием spam
+	}
 
-	log.Printf("Письмо от %s, тема: %s", from, subject)
+	// Один идентификатор на всё письмо: копии для разных получателей связаны
+	queueID := newID()
+	logger := s.logger.With(slog.String("queue_id", queueID))
+	span.SetAttributes(attribute.String("smtp.queue_id", queueID))
+
+	// Проверяем SPF, DKIM и DMARC по исходному тексту письма
+	// Письма с submission-порта отправлены вошедшим владельцем ящика — проверять нечего
+	if s.backend.verifier != nil && s.user == nil {
+		verifyCtx, verifySpan := tracing.Start(ctx, "mailauth.verify")
+		template.Authentication = s.backend.verifier.Verify(verifyCtx, mailauth.Input{
+			IP:       remoteIP(s.conn),
+			Helo:     s.conn.Hostname(),
+			MailFrom: s.from,
+			Raw:      raw,
+		})
+		verifySpan.End()
+	}
 
 	// Сохраняем письмо для каждого получателя
-	for _, to := range s.to {
-		err := s.saveMessage(to, from, subject, bodyText, bodyHTML)
+	delivered := false
+	for _, rcpt := range s.to {
+		err := s.saveMessage(ctx, rcpt, template, queueID, raw)
 		if err != nil {
-			log.Printf("Ошибка сохранения письма для %s: %v", to, err)
+			logger.Error("message store failed", slog.String("rcpt", rcpt.address), logging.Err(err))
+			continue
+		}
+		delivered = true
+	}
+
+	// Тему и текст письма в лог не пишем
+	logger.Info("message received",
+		slog.String("mail_from", s.from),
+		slog.Int("recipients", len(s.to)),
+		slog.Int("size", len(raw)),
+		slog.Bool("spam", template.IsSpam),
+		slog.Bool("delivered", delivered),
+	)
+
+	// Клиент, повторивший доставку после грейлистинга, — настоящий сервер
+	if delivered && s.greylistPassed {
+		s.backend.greylist.Delivered(ctx, remoteIP(s.conn))
+	}
+
+	// Ни одной копии не сохранили — пусть отправитель повторит доставку позже,
+	// иначе письмо потеряется после ответа 250
+	if !delivered {
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Не удалось сохранить письмо, повторите попытку позже",
 		}
 	}
 
 	return nil
 }
 
-// saveMessage сохраняет письмо в базу данных
-func (s *Session) saveMessage(to, from, subject, bodyText, bodyHTML string) error {
-	mailbox, err := s.backend.mailboxService.GetByAddress(to)
-	if err != nil {
-		return err
+// saveMessage сохраняет копию письма для одного получателя
+// Существование и срок действия ящика проверяет MessageService
+func (s *Session) saveMessage(ctx context.Context, rcpt recipient, template domain.Message, queueID string, raw []byte) error {
+	ctx, span := tracing.Start(ctx, "smtp.store", attribute.String("mailbox.id", rcpt.mailboxID))
+	defer span.End()
+
+	message := template
+	message.MailboxID = rcpt.mailboxID
+	message.Recipient = rcpt.address
+	message.Tag = rcpt.tag
+	message.Envelope = s.envelope(rcpt, queueID, len(raw), template.ReceivedAt)
+
+	// Заголовок Received у каждой копии свой: в нём указан получатель
+	received := s.received(rcpt, queueID, template.ReceivedAt).header()
+	message.RawSource = make([]byte, 0, len(received)+len(raw))
+	message.RawSource = append(message.RawSource, received...)
+	message.RawSource = append(message.RawSource, raw...)
+
+	err := s.backend.messageService.Create(ctx, &message)
+	tracing.Fail(span, err)
+	return err
+}
+
+// received собирает данные для заголовка Received одной копии письма
+func (s *Session) received(rcpt recipient, queueID string, receivedAt time.Time) receivedInfo {
+	_, tls := s.conn.TLSConnectionState()
+	return receivedInfo{
+		Helo:      s.conn.Hostname(),
+		IP:        remoteIP(s.conn),
+		By:        s.backend.domain,
+		TLS:       tls,
+		UTF8:      s.mailOpts.UTF8,
+		Auth:      s.user != nil,
+		QueueID:   queueID,
+		Recipient: rcpt.address,
+		Date:      receivedAt,
 	}
-	if mailbox == nil {
-		return fmt.Errorf("ящик %s не найден", to)
+}
+
+// envelope собирает SMTP-конверт письма для одного получателя
+func (s *Session) envelope(rcpt recipient, queueID string, size int, receivedAt time.Time) *domain.Envelope {
+	env := &domain.Envelope{
+		QueueID:      queueID,
+		Helo:         s.conn.Hostname(),
+		MailFrom:     s.from,
+		RcptTo:       rcpt.address,
+		BodyType:     string(s.mailOpts.Body),
+		SMTPUTF8:     s.mailOpts.UTF8,
+		DeclaredSize: s.mailOpts.Size,
+		Size:         int64(size),
+		ReceivedAt:   receivedAt,
 	}
 
-	message := &domain.Message{
-		MailboxID:   mailbox.ID,
-		FromAddress: extractEmail(from),
-		Subject:     subject,
-		BodyText:    bodyText,
-		BodyHTML:    bodyHTML,
-		IsRead:      false,
-		IsSpam:      false,
+	if ip := remoteIP(s.conn); ip != nil {
+		env.RemoteIP = ip.String()
+	}
+
+	if state, ok := s.conn.TLSConnectionState(); ok {
+		env.TLS = true
+		env.TLSVersion = tls.VersionName(state.Version)
+		env.TLSCipher = tls.CipherSuiteName(state.CipherSuite)
 	}
 
-	return s.backend.messageService.Create(message)
+	return env
 }
 
 // parseBody парсит тело письма и извлекает текст и HTML
@@ -204,12 +418,16 @@ func decodeHeader(s string) string {
 // Reset вызывается для сброса сессии
 func (s *Session) Reset() {
 	s.from = ""
+	s.mailOpts = smtp.MailOptions{}
 	s.to = nil
+	s.greylistPassed = false
 }
 
 // Logout вызывается при завершении сессии
 func (s *Session) Logout() error {
-	log.Println("SMTP-сессия завершена")
+	s.logger.Info("smtp session ended", slog.Int64("duration_ms", time.Since(s.started).Milliseconds()))
+	s.span.End()
+	s.cancel()
 	return nil
 }
 
@@ -224,3 +442,11 @@ func extractEmail(s string) string {
 	// Иначе возвращаем как есть
 	return strings.TrimSpace(s)
 }
+
+// remoteIP возвращает IP-адрес подключившегося клиента
+func remoteIP(c *smtp.Conn) net.IP {
+	if addr, ok := c.Conn().RemoteAddr().(*net.TCPAddr); ok {
+		return addr.IP
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package smtp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/metrics"
+	"tempmail/internal/service"
+	"tempmail/internal/storage"
+)
+
+// testServer — SMTP-сервер без защиты и проверок поверх хранилища в памяти
+type testServer struct {
+	addr      string
+	store     *storage.Storage
+	mailboxes *service.MailboxService
+}
+
+// startServer запускает сервер; tlsConfig включает STARTTLS, m — метрики (nil — без них)
+func startServer(t *testing.T, tlsConfig *tls.Config, m *metrics.Metrics) *testServer {
+	t.Helper()
+	store, err := storage.Open(config.StorageConfig{Backend: "memory"}, config.DatabaseConfig{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	mail := config.MailConfig{
+		Domain:               "tempmail.test",
+		DefaultTTL:           time.Hour,
+		MaxTTL:               24 * time.Hour,
+		MaxLifetime:          48 * time.Hour,
+		GracePeriod:          time.Hour,
+		AddressLength:        10,
+		AddressDots:          service.DotsKeep,
+		SubaddressSeparators: "+",
+	}
+	generator, err := service.NewAddressGenerator(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy, err := service.NewAddressPolicy(mail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mailboxes := service.NewMailboxService(store.Mailboxes, store.APIKeys, mail, generator, policy, nil, nil)
+	messages := service.NewMessageService(store.Messages, store.Mailboxes,
+		config.LimitsConfig{MaxMessageSize: 1 << 20, MaxMessagesPerMailbox: 10}, mail, nil)
+
+	backend := NewBackend(mailboxes, messages, mail.Domain, nil, nil, nil, nil, m, nil)
+	server := newSMTPServer(backend, 0, mail.Domain, tlsConfig)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(sessionListener{l})
+	t.Cleanup(func() { server.Close() })
+
+	return &testServer{addr: l.Addr().String(), store: store, mailboxes: mailboxes}
+}
+
+// TestSubaddressTag проверяет, что тег подадреса сохраняется и у письма,
+// принятого через ящик-шаблон
+func TestSubaddressTag(t *testing.T) {
+	ctx := context.Background()
+	srv := startServer(t, nil, nil)
+
+	exact, err := srv.mailboxes.Create(ctx, service.CreateOptions{Address: "inbox@tempmail.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wildcard, err := srv.mailboxes.Create(ctx, service.CreateOptions{Address: "shop-*@tempmail.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rcpt    string
+		mailbox *domain.Mailbox
+		wantTag string
+	}{
+		{"inbox@tempmail.test", exact, ""},
+		{"inbox+news@tempmail.test", exact, "news"},
+		{"shop-a@tempmail.test", wildcard, ""},
+		{"shop-b+orders@tempmail.test", wildcard, "orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rcpt, func(t *testing.T) {
+			msg := "From: alice@example.com\r\nTo: " + tt.rcpt + "\r\nSubject: " + tt.rcpt + "\r\n\r\nhello\r\n"
+			if err := smtp.SendMail(srv.addr, nil, "alice@example.com", []string{tt.rcpt}, []byte(msg)); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, err := srv.store.Messages.GetByMailboxID(ctx, tt.mailbox.ID, domain.MessageFilter{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, m := range stored {
+				if m.Recipient != tt.rcpt {
+					continue
+				}
+				if m.Tag != tt.wantTag {
+					t.Errorf("tag = %q, want %q", m.Tag, tt.wantTag)
+				}
+				return
+			}
+			t.Fatalf("no message for %s in mailbox %s", tt.rcpt, tt.mailbox.Address)
+		})
+	}
+}
+
+// selfSignedTLS возвращает настройки TLS с самоподписанным сертификатом
+func selfSignedTLS(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"tempmail.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// sessionRecorder считает сессии в постоянной статистике
+type sessionRecorder struct {
+	mu       sync.Mutex
+	sessions int64
+}
+
+func (r *sessionRecorder) Add(name string, delta int64) {
+	if name != domain.StatSMTPSessions {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions += delta
+}
+
+func (r *sessionRecorder) count() int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sessions
+}
+
+// TestSessionCountedOnce проверяет, что соединение с STARTTLS учитывается
+// как одна сессия и после отключения не остаётся открытой
+func TestSessionCountedOnce(t *testing.T) {
+	m := metrics.New()
+	recorder := &sessionRecorder{}
+	m.SetRecorder(recorder)
+	srv := startServer(t, selfSignedTLS(t), m)
+
+	// active возвращает число открытых сессий
+	active := func() int64 {
+		t.Helper()
+		snapshot, err := m.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return snapshot.ActiveSMTPSessions
+	}
+
+	// cmd отправляет команду и читает ответ с ожидаемым кодом
+	cmd := func(t *testing.T, text *textproto.Conn, want int, line string) {
+		t.Helper()
+		if err := text.PrintfLine("%s", line); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := text.ReadResponse(want); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		startTLS bool
+		ehlo     bool // EHLO после STARTTLS: go-smtp начинает новую сессию
+	}{
+		{"plain", false, false},
+		{"starttls", true, true},
+		{"starttls without ehlo", true, false},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", srv.addr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			conn.SetDeadline(time.Now().Add(5 * time.Second))
+			text := textproto.NewConn(conn)
+			if _, _, err := text.ReadResponse(220); err != nil {
+				t.Fatal(err)
+			}
+			cmd(t, text, 250, "EHLO client.example")
+
+			if tt.startTLS {
+				cmd(t, text, 220, "STARTTLS")
+				tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true})
+				if err := tlsConn.Handshake(); err != nil {
+					t.Fatal(err)
+				}
+				conn = tlsConn
+				text = textproto.NewConn(tlsConn)
+				if tt.ehlo {
+					cmd(t, text, 250, "EHLO client.example")
+				}
+			}
+
+			if got := active(); got != 1 {
+				t.Errorf("active sessions while connected = %d, want 1", got)
+			}
+			conn.Close()
+
+			// Сервер закрывает соединение асинхронно
+			deadline := time.Now().Add(5 * time.Second)
+			for active() != 0 && time.Now().Before(deadline) {
+				time.Sleep(10 * time.Millisecond)
+			}
+			if got := active(); got != 0 {
+				t.Errorf("active sessions after disconnect = %d, want 0", got)
+			}
+			if got, want := recorder.count(), int64(i+1); got != want {
+				t.Errorf("sessions = %d, want %d", got, want)
+			}
+		})
+	}
+}
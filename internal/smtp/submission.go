@@ -0,0 +1,61 @@
+package smtp
+
+import (
+	"errors"
+	"log/slog"
+
+	"github.com/emersion/go-sasl"
+	"github.com/emersion/go-smtp"
+
+	"tempmail/internal/logging"
+	"tempmail/internal/service"
+)
+
+// errAuthUnavailable — вход временно невозможен (например, недоступна БД)
+var errAuthUnavailable = &smtp.SMTPError{
+	Code:         454,
+	EnhancedCode: smtp.EnhancedCode{4, 7, 0},
+	Message:      "Временная ошибка входа, попробуйте позже",
+}
+
+// submissionSession — сессия на submission-порту
+// Владелец ящика входит по AUTH PLAIN (имя — адрес ящика, пароль — токен,
+// выданный при создании) и отправляет тестовые письма в ящики сервиса
+type submissionSession struct {
+	*Session
+}
+
+// AuthMechanisms возвращает поддерживаемые механизмы входа
+func (s *submissionSession) AuthMechanisms() []string {
+	return []string{sasl.Plain}
+}
+
+// Auth начинает вход выбранным механизмом
+func (s *submissionSession) Auth(mech string) (sasl.Server, error) {
+	if mech != sasl.Plain {
+		return nil, smtp.ErrAuthUnknownMechanism
+	}
+
+	return sasl.NewPlainServer(func(identity, username, password string) error {
+		// Входить от имени другого ящика нельзя
+		if identity != "" && identity != username {
+			s.backend.metrics.SMTPAuthFailure()
+			return s.reject(smtp.ErrAuthFailed)
+		}
+
+		mailbox, err := s.backend.mailboxService.Authenticate(s.ctx, username, password)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			s.logger.Info("submission auth failed", slog.String("username", username))
+			s.backend.metrics.SMTPAuthFailure()
+			return s.reject(smtp.ErrAuthFailed)
+		}
+		if err != nil {
+			s.logger.Error("submission auth error", logging.Err(err))
+			return errAuthUnavailable
+		}
+
+		s.user = mailbox
+		s.logger.Info("submission auth succeeded", slog.String("mailbox_id", mailbox.ID))
+		return nil
+	}), nil
+}
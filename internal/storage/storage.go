@@ -0,0 +1,121 @@
+// Package storage открывает хранилище данных, выбранное в STORAGE_BACKEND
+//
+// Сервисы зависят только от интерфейсов хранилищ (service.MailboxStore и др.),
+// поэтому для них не важно, где лежат данные: в PostgreSQL, в файле SQLite
+// или в памяти процесса.
+package storage
+
+import (
+	"fmt"
+
+	"tempmail/internal/config"
+	"tempmail/internal/health"
+	"tempmail/internal/metrics"
+	"tempmail/internal/repository"
+	"tempmail/internal/repository/memory"
+	"tempmail/internal/repository/sqlite"
+	"tempmail/internal/service"
+)
+
+// Storage — хранилища выбранного бэкенда
+type Storage struct {
+	Mailboxes service.MailboxStore
+	Messages  service.MessageStore
+	APIKeys   service.APIKeyStore
+	Stats     service.StatsStore
+	Greylist  service.GreylistStore // Триплеты грейлистинга в основном хранилище (GREYLIST_STORE=storage)
+
+	checks []namedCheck // Проверки готовности бэкенда
+	close  func() error // Закрывает подключение (nil — закрывать нечего)
+}
+
+// namedCheck — проверка готовности с именем для /readyz
+type namedCheck struct {
+	name  string
+	check health.Check
+}
+
+// Open открывает хранилище cfg.Backend
+// Время запросов к PostgreSQL и SQLite попадает в метрики m (nil — без метрик)
+func Open(cfg config.StorageConfig, db config.DatabaseConfig, m *metrics.Metrics) (*Storage, error) {
+	switch cfg.Backend {
+	case "postgres":
+		return openPostgres(db, m)
+	case "sqlite":
+		return openSQLite(cfg.SQLitePath, db, m)
+	case "memory":
+		return openMemory(), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище STORAGE_BACKEND=%q", cfg.Backend)
+	}
+}
+
+// openPostgres подключается к PostgreSQL
+func openPostgres(cfg config.DatabaseConfig, m *metrics.Metrics) (*Storage, error) {
+	db, err := repository.NewPostgresDB(cfg, m)
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{
+		Mailboxes: repository.NewMailboxRepository(db.DB),
+		Messages:  repository.NewMessageRepository(db.DB),
+		APIKeys:   repository.NewAPIKeyRepository(db.DB),
+		Stats:     repository.NewStatsRepository(db.DB),
+		Greylist:  repository.NewGreylistRepository(db.DB),
+		checks: []namedCheck{
+			{"postgres", db.DB.PingContext},
+			{"schema", db.CheckSchema},
+			{"storage", db.CheckWritable},
+		},
+		close: db.Close,
+	}, nil
+}
+
+// openSQLite открывает файл SQLite и применяет к нему встроенные миграции
+// Из DatabaseConfig используются только сроки запросов
+func openSQLite(path string, cfg config.DatabaseConfig, m *metrics.Metrics) (*Storage, error) {
+	db, err := sqlite.Open(path, cfg, m)
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{
+		Mailboxes: sqlite.NewMailboxRepository(db.DB),
+		Messages:  sqlite.NewMessageRepository(db.DB),
+		APIKeys:   sqlite.NewAPIKeyRepository(db.DB),
+		Stats:     sqlite.NewStatsRepository(db.DB),
+		Greylist:  sqlite.NewGreylistRepository(db.DB),
+		checks: []namedCheck{
+			{"sqlite", db.DB.PingContext},
+			{"schema", db.CheckSchema},
+		},
+		close: db.Close,
+	}, nil
+}
+
+// openMemory создаёт пустое хранилище в памяти процесса
+func openMemory() *Storage {
+	db := memory.NewDB()
+	return &Storage{
+		Mailboxes: memory.NewMailboxRepository(db),
+		Messages:  memory.NewMessageRepository(db),
+		APIKeys:   memory.NewAPIKeyRepository(db),
+		Stats:     memory.NewStatsRepository(db),
+		Greylist:  memory.NewGreylistRepository(db),
+	}
+}
+
+// RegisterChecks добавляет проверки готовности хранилища в /readyz
+// Хранилищу в памяти проверять нечего
+func (s *Storage) RegisterChecks(checks *health.Registry) {
+	for _, c := range s.checks {
+		checks.Register(c.name, c.check)
+	}
+}
+
+// Close закрывает подключение к хранилищу
+func (s *Storage) Close() error {
+	if s.close == nil {
+		return nil
+	}
+	return s.close()
+}
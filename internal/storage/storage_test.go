@@ -0,0 +1,308 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tempmail/internal/config"
+	"tempmail/internal/domain"
+	"tempmail/internal/repository"
+	"tempmail/internal/storage"
+)
+
+// backends — хранилища, которые должны одинаково соблюдать правила из service/store.go
+// PostgreSQL здесь нет: для него нужен сервер БД
+var backends = []struct {
+	name string
+	cfg  func(t *testing.T) config.StorageConfig
+}{
+	{"memory", func(*testing.T) config.StorageConfig {
+		return config.StorageConfig{Backend: "memory"}
+	}},
+	{"sqlite", func(t *testing.T) config.StorageConfig {
+		return config.StorageConfig{Backend: "sqlite", SQLitePath: filepath.Join(t.TempDir(), "tempmail.db")}
+	}},
+}
+
+// contract — проверка одного правила на пустом хранилище
+type contract struct {
+	name string
+	run  func(t *testing.T, ctx context.Context, s *storage.Storage)
+}
+
+func TestStoreContract(t *testing.T) {
+	contracts := []contract{
+		{"duplicate mailbox", testDuplicateMailbox},
+		{"mailbox delete cascades to messages", testDeleteCascade},
+		{"deactivate expired", testDeactivateExpired},
+		{"delete expired", testDeleteExpired},
+		{"mailbox search paging", testMailboxSearch},
+		{"message search paging", testMessageSearch},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, c := range contracts {
+				t.Run(c.name, func(t *testing.T) {
+					s, err := storage.Open(backend.cfg(t), config.DatabaseConfig{}, nil)
+					if err != nil {
+						t.Fatal(err)
+					}
+					t.Cleanup(func() { s.Close() })
+					c.run(t, context.Background(), s)
+				})
+			}
+		})
+	}
+}
+
+// now — текущее время с точностью SQLite (микросекунды)
+func now() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+// createMailbox сохраняет активный ящик со сроком expiresAt
+func createMailbox(t *testing.T, ctx context.Context, s *storage.Storage, address string, createdAt, expiresAt time.Time) *domain.Mailbox {
+	t.Helper()
+	mailbox := &domain.Mailbox{
+		Address:      address,
+		CreatedAt:    createdAt,
+		ExpiresAt:    expiresAt,
+		IsActive:     true,
+		MaxExpiresAt: expiresAt.Add(time.Hour),
+	}
+	if err := s.Mailboxes.Create(ctx, mailbox); err != nil {
+		t.Fatalf("create mailbox %s: %v", address, err)
+	}
+	return mailbox
+}
+
+// createMessage сохраняет письмо с исходным текстом в ящик mailboxID
+func createMessage(t *testing.T, ctx context.Context, s *storage.Storage, mailboxID, subject string, receivedAt time.Time) *domain.Message {
+	t.Helper()
+	msg := &domain.Message{
+		MailboxID:   mailboxID,
+		FromAddress: "alice@example.com",
+		Subject:     subject,
+		BodyText:    subject,
+		ReceivedAt:  receivedAt,
+		RawSource:   []byte("Subject: " + subject + "\r\n\r\n" + subject + "\r\n"),
+	}
+	if err := s.Messages.Create(ctx, msg); err != nil {
+		t.Fatalf("create message %s: %v", subject, err)
+	}
+	return msg
+}
+
+// getMailbox возвращает ящик по ID (nil — ящика нет)
+func getMailbox(t *testing.T, ctx context.Context, s *storage.Storage, id string) *domain.Mailbox {
+	t.Helper()
+	mailbox, err := s.Mailboxes.GetByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mailbox
+}
+
+func testDuplicateMailbox(t *testing.T, ctx context.Context, s *storage.Storage) {
+	first := createMailbox(t, ctx, s, "box@tempmail.test", now(), now().Add(time.Hour))
+
+	sameAddress := &domain.Mailbox{Address: first.Address, ExpiresAt: first.ExpiresAt, MaxExpiresAt: first.MaxExpiresAt}
+	if err := s.Mailboxes.Create(ctx, sameAddress); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("same address: err = %v, want ErrDuplicate", err)
+	}
+
+	sameID := &domain.Mailbox{ID: first.ID, Address: "other@tempmail.test", ExpiresAt: first.ExpiresAt, MaxExpiresAt: first.MaxExpiresAt}
+	if err := s.Mailboxes.Create(ctx, sameID); !errors.Is(err, repository.ErrDuplicate) {
+		t.Errorf("same ID: err = %v, want ErrDuplicate", err)
+	}
+
+	if got := getMailbox(t, ctx, s, first.ID); got == nil || got.Address != first.Address {
+		t.Errorf("GetByID = %+v, want the first mailbox unchanged", got)
+	}
+}
+
+func testDeleteCascade(t *testing.T, ctx context.Context, s *storage.Storage) {
+	deleted := createMailbox(t, ctx, s, "deleted@tempmail.test", now(), now().Add(time.Hour))
+	kept := createMailbox(t, ctx, s, "kept@tempmail.test", now(), now().Add(time.Hour))
+	gone := []*domain.Message{
+		createMessage(t, ctx, s, deleted.ID, "one", now()),
+		createMessage(t, ctx, s, deleted.ID, "two", now()),
+	}
+	other := createMessage(t, ctx, s, kept.ID, "other", now())
+
+	if err := s.Mailboxes.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range gone {
+		if got, err := s.Messages.GetByID(ctx, msg.ID); err != nil || got != nil {
+			t.Errorf("message %s after mailbox delete: %+v, %v; want nil, nil", msg.Subject, got, err)
+		}
+		if raw, err := s.Messages.GetRawSource(ctx, msg.ID); err != nil || raw != nil {
+			t.Errorf("raw source %s after mailbox delete: %q, %v; want nil, nil", msg.Subject, raw, err)
+		}
+	}
+	if n, err := s.Messages.CountByMailboxID(ctx, deleted.ID); err != nil || n != 0 {
+		t.Errorf("CountByMailboxID = %d, %v; want 0", n, err)
+	}
+	if got, err := s.Messages.GetByID(ctx, other.ID); err != nil || got == nil {
+		t.Errorf("message of another mailbox: %+v, %v; want it kept", got, err)
+	}
+}
+
+func testDeactivateExpired(t *testing.T, ctx context.Context, s *storage.Storage) {
+	expired := createMailbox(t, ctx, s, "expired@tempmail.test", now(), now().Add(-time.Minute))
+	live := createMailbox(t, ctx, s, "live@tempmail.test", now(), now().Add(time.Minute))
+	inactive := createMailbox(t, ctx, s, "inactive@tempmail.test", now(), now().Add(-time.Minute))
+	if _, err := s.Mailboxes.DeactivateExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// Уже деактивированный ящик повторно не считается
+	if err := s.Mailboxes.Restore(ctx, expired.ID, now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := s.Mailboxes.DeactivateExpired(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("DeactivateExpired = %d, want 1", n)
+	}
+
+	for _, tt := range []struct {
+		mailbox    *domain.Mailbox
+		wantActive bool
+	}{{expired, false}, {live, true}, {inactive, false}} {
+		got := getMailbox(t, ctx, s, tt.mailbox.ID)
+		if got == nil {
+			t.Fatalf("%s deleted by DeactivateExpired", tt.mailbox.Address)
+		}
+		if got.IsActive != tt.wantActive {
+			t.Errorf("%s IsActive = %v, want %v", tt.mailbox.Address, got.IsActive, tt.wantActive)
+		}
+	}
+
+	// Деактивированный ящик не находится по адресу
+	if got, err := s.Mailboxes.GetByAddress(ctx, expired.Address); err != nil || got != nil {
+		t.Errorf("GetByAddress(deactivated) = %+v, %v; want nil, nil", got, err)
+	}
+}
+
+func testDeleteExpired(t *testing.T, ctx context.Context, s *storage.Storage) {
+	before := now().Add(-time.Hour)
+	older := createMailbox(t, ctx, s, "older@tempmail.test", before.Add(-time.Hour), before.Add(-time.Microsecond))
+	boundary := createMailbox(t, ctx, s, "boundary@tempmail.test", before.Add(-time.Hour), before)
+	newer := createMailbox(t, ctx, s, "newer@tempmail.test", before.Add(-time.Hour), now().Add(-time.Minute))
+	msg := createMessage(t, ctx, s, older.ID, "expired", before.Add(-time.Minute))
+
+	n, err := s.Mailboxes.DeleteExpired(ctx, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("DeleteExpired = %d, want 1", n)
+	}
+
+	if getMailbox(t, ctx, s, older.ID) != nil {
+		t.Error("mailbox expired before the cutoff was not deleted")
+	}
+	// Срок, равный границе, ещё не «раньше before»
+	if getMailbox(t, ctx, s, boundary.ID) == nil {
+		t.Error("mailbox expiring exactly at the cutoff was deleted")
+	}
+	if getMailbox(t, ctx, s, newer.ID) == nil {
+		t.Error("mailbox expired after the cutoff was deleted")
+	}
+	if got, err := s.Messages.GetByID(ctx, msg.ID); err != nil || got != nil {
+		t.Errorf("message of deleted mailbox: %+v, %v; want nil, nil", got, err)
+	}
+}
+
+func testMailboxSearch(t *testing.T, ctx context.Context, s *storage.Storage) {
+	base := now().Add(-time.Hour)
+	var ids []string
+	for i := range 5 {
+		mailbox := createMailbox(t, ctx, s, fmt.Sprintf("page-%d@tempmail.test", i), base.Add(time.Duration(i)*time.Minute), now().Add(time.Hour))
+		ids = append(ids, mailbox.ID)
+	}
+	createMailbox(t, ctx, s, "unrelated@tempmail.test", now(), now().Add(time.Hour))
+
+	// Новые первыми: страницы идут от page-4 к page-0
+	tests := []struct {
+		offset int
+		want   []string
+	}{
+		{0, []string{ids[4], ids[3]}},
+		{2, []string{ids[2], ids[1]}},
+		{4, []string{ids[0]}},
+		{6, nil},
+	}
+	for _, tt := range tests {
+		found, total, err := s.Mailboxes.Search(ctx, domain.MailboxSearch{Query: "PAGE-", Limit: 2, Offset: tt.offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != 5 {
+			t.Errorf("offset %d: total = %d, want 5", tt.offset, total)
+		}
+		var got []string
+		for _, m := range found {
+			got = append(got, m.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("offset %d: got %v, want %v", tt.offset, got, tt.want)
+		}
+	}
+}
+
+func testMessageSearch(t *testing.T, ctx context.Context, s *storage.Storage) {
+	mailbox := createMailbox(t, ctx, s, "search@tempmail.test", now(), now().Add(time.Hour))
+	other := createMailbox(t, ctx, s, "other@tempmail.test", now(), now().Add(time.Hour))
+
+	base := now().Add(-time.Hour)
+	var ids []string
+	for i := range 5 {
+		msg := createMessage(t, ctx, s, mailbox.ID, fmt.Sprintf("message %d", i), base.Add(time.Duration(i)*time.Minute))
+		ids = append(ids, msg.ID)
+	}
+	createMessage(t, ctx, s, other.ID, "other", now())
+
+	tests := []struct {
+		filter domain.MessageSearch
+		total  int
+		want   []string
+	}{
+		{domain.MessageSearch{MailboxID: mailbox.ID, Limit: 3}, 5, []string{ids[4], ids[3], ids[2]}},
+		{domain.MessageSearch{MailboxID: mailbox.ID, Limit: 3, Offset: 3}, 5, []string{ids[1], ids[0]}},
+		{domain.MessageSearch{MailboxID: mailbox.ID, Since: base.Add(3 * time.Minute), Limit: 10}, 2, []string{ids[4], ids[3]}},
+		{domain.MessageSearch{Limit: 1}, 6, nil}, // Самое новое — письмо другого ящика
+	}
+	for i, tt := range tests {
+		found, total, err := s.Messages.Search(ctx, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if total != tt.total {
+			t.Errorf("case %d: total = %d, want %d", i, total, tt.total)
+		}
+		if tt.want == nil {
+			if len(found) != 1 || found[0].MailboxID != other.ID {
+				t.Errorf("case %d: got %+v, want the newest message of another mailbox", i, found)
+			}
+			continue
+		}
+		var got []string
+		for _, m := range found {
+			got = append(got, m.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("case %d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package tracing настраивает трассировку OpenTelemetry
+//
+// Setup вызывается один раз в main и регистрирует глобальный TracerProvider —
+// так принято в OpenTelemetry, и так спаны получают сторонние библиотеки.
+// Пока трассировка выключена, Start и StartServer возвращают пустые спаны почти без накладных расходов.
+// Спаны связываются через context.Context, который передаётся от HTTP-обработчика
+// или SMTP-сессии через сервисы до запросов к БД.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"tempmail/internal/config"
+)
+
+// instrumentation — имя, под которым сервис создаёт спаны
+const instrumentation = "tempmail"
+
+// Setup включает экспорт трасс по OTLP/HTTP
+// service — имя сервиса в трассах (tempmail-api, tempmail-smtp)
+// Возвращает функцию, которая при остановке отправляет накопленные спаны
+func Setup(ctx context.Context, cfg config.TracingConfig, service string) (func(context.Context) error, error) {
+	// Заголовок traceparent принимаем и передаём, даже если свои спаны не пишем
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO должен быть от 0 до 1, получено %v", cfg.SampleRatio)
+	}
+
+	opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка настройки экспорта трасс: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", service),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start начинает спан name, дочерний к спану из ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartServer начинает спан входящего запроса или соединения
+// Родителем становится удалённый спан из ctx (заголовок traceparent), если он есть
+func StartServer(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentation).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// Fail отмечает спан ошибкой err; nil и отмену запроса клиентом не считает ошибкой
+func Fail(span trace.Span, err error) {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_mailbox_tag;
+ALTER TABLE messages DROP COLUMN IF EXISTS tag;
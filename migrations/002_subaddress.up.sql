@@ -0,0 +1,5 @@
+-- Тег подадреса (box+tag@domain) для каждого письма
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS tag VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Индекс для фильтрации писем ящика по тегу
+CREATE INDEX IF NOT EXISTS idx_messages_mailbox_tag ON messages(mailbox_id, tag);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_mailboxes_wildcard;
+ALTER TABLE messages DROP COLUMN IF EXISTS recipient;
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS is_wildcard;
@@ -0,0 +1,8 @@
+-- Ящики-шаблоны (catch-all): адрес вида *@domain или test-*@domain
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS is_wildcard BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Исходный адрес получателя (RCPT TO), на который пришло письмо
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS recipient VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Частичный индекс для быстрого поиска активных шаблонов
+CREATE INDEX IF NOT EXISTS idx_mailboxes_wildcard ON mailboxes(is_wildcard) WHERE is_wildcard = TRUE AND is_active = TRUE;
@@ -0,0 +1,2 @@
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS auto_extend_seconds;
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS max_expires_at;
@@ -0,0 +1,10 @@
+-- Предел продления срока жизни ящика
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS max_expires_at TIMESTAMP;
+
+-- Для существующих ящиков продление не предусматривалось
+UPDATE mailboxes SET max_expires_at = expires_at WHERE max_expires_at IS NULL;
+
+ALTER TABLE mailboxes ALTER COLUMN max_expires_at SET NOT NULL;
+
+-- Автопродление при активности, в секундах (0 — выключено)
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS auto_extend_seconds INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+-- Результаты проверки SPF, DKIM и DMARC входящего письма (NULL — проверка не выполнялась)
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS authentication JSONB;
@@ -0,0 +1,20 @@
+-- SMTP-конверт и параметры соединения для каждого письма
+CREATE TABLE IF NOT EXISTS message_envelopes (
+    message_id UUID PRIMARY KEY REFERENCES messages(id) ON DELETE CASCADE, -- Связь с письмом
+    remote_ip VARCHAR(45) NOT NULL DEFAULT '',     -- IP-адрес клиента (IPv4 или IPv6)
+    helo VARCHAR(255) NOT NULL DEFAULT '',         -- Имя из HELO/EHLO
+    tls BOOLEAN NOT NULL DEFAULT FALSE,            -- Передано ли по TLS
+    tls_version VARCHAR(20) NOT NULL DEFAULT '',   -- Версия TLS
+    tls_cipher VARCHAR(100) NOT NULL DEFAULT '',   -- Набор шифров
+    mail_from VARCHAR(255) NOT NULL DEFAULT '',    -- MAIL FROM (пусто — null sender)
+    rcpt_to VARCHAR(255) NOT NULL DEFAULT '',      -- RCPT TO
+    body_type VARCHAR(20) NOT NULL DEFAULT '',     -- BODY=7BIT/8BITMIME/BINARYMIME
+    smtputf8 BOOLEAN NOT NULL DEFAULT FALSE,       -- Параметр SMTPUTF8
+    declared_size BIGINT NOT NULL DEFAULT 0,       -- SIZE= из MAIL FROM
+    size BIGINT NOT NULL DEFAULT 0,                -- Фактический размер
+    received_at TIMESTAMP NOT NULL DEFAULT NOW()   -- Время приёма
+);
+
+-- Индексы для отчётов по отправителям и IP
+CREATE INDEX IF NOT EXISTS idx_envelopes_mail_from ON message_envelopes(mail_from);
+CREATE INDEX IF NOT EXISTS idx_envelopes_remote_ip ON message_envelopes(remote_ip);
@@ -0,0 +1,2 @@
+ALTER TABLE message_envelopes DROP COLUMN IF EXISTS queue_id;
+ALTER TABLE messages DROP COLUMN IF EXISTS raw_source;
@@ -0,0 +1,5 @@
+-- Исходный текст письма с добавленным заголовком Received
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS raw_source BYTEA;
+
+-- Идентификатор письма на нашем сервере (из заголовка Received)
+ALTER TABLE message_envelopes ADD COLUMN IF NOT EXISTS queue_id VARCHAR(32) NOT NULL DEFAULT '';
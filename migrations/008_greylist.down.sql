@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS greylist_whitelist;
+DROP TABLE IF EXISTS greylist;
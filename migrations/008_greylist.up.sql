@@ -0,0 +1,20 @@
+-- Триплеты грейлистинга (сеть клиента, отправитель, получатель)
+CREATE TABLE IF NOT EXISTS greylist (
+    client_net VARCHAR(64) NOT NULL,    -- Сеть клиента (/24 или /64)
+    sender VARCHAR(255) NOT NULL,       -- MAIL FROM ("<>" — null sender)
+    recipient VARCHAR(255) NOT NULL,    -- RCPT TO
+    first_seen TIMESTAMP NOT NULL,      -- Первая попытка доставки
+    passed BOOLEAN NOT NULL DEFAULT FALSE, -- Повтор после задержки принят
+    expires_at TIMESTAMP NOT NULL,      -- Когда запись можно удалить
+    PRIMARY KEY (client_net, sender, recipient)
+);
+
+-- Сети, из которых письма доставлялись успешно (автоматический белый список)
+CREATE TABLE IF NOT EXISTS greylist_whitelist (
+    client_net VARCHAR(64) PRIMARY KEY, -- Сеть клиента (/24 или /64)
+    expires_at TIMESTAMP NOT NULL       -- Когда запись можно удалить
+);
+
+-- Индексы для очистки устаревших записей
+CREATE INDEX IF NOT EXISTS idx_greylist_expires_at ON greylist(expires_at);
+CREATE INDEX IF NOT EXISTS idx_greylist_whitelist_expires_at ON greylist_whitelist(expires_at);
@@ -0,0 +1 @@
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS token_hash;
@@ -0,0 +1,3 @@
+-- SHA-256 токена ящика для входа на submission-порт
+-- У ящиков, созданных раньше, токена нет: войти с ними нельзя
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS token_hash VARCHAR(64);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_mailboxes_api_key_id;
+ALTER TABLE mailboxes DROP COLUMN IF EXISTS api_key_id;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,20 @@
+-- API-ключи клиентов (команд); сам ключ не хранится, только SHA-256
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY,                          -- Уникальный идентификатор
+    name VARCHAR(100) NOT NULL,                   -- Название (команда, сервис)
+    key_hash VARCHAR(64) NOT NULL UNIQUE,         -- SHA-256 ключа
+    prefix VARCHAR(16) NOT NULL,                  -- Начало ключа, чтобы узнать его в списке
+    max_mailboxes_per_hour INT NOT NULL DEFAULT 0, -- Ящиков в час (0 — без ограничения)
+    max_active_mailboxes INT NOT NULL DEFAULT 0,  -- Активных ящиков одновременно (0 — без ограничения)
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,      -- Отозванный ключ не принимается
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),  -- Дата создания
+    last_used_at TIMESTAMP,                       -- Последний запрос с ключом
+    requests BIGINT NOT NULL DEFAULT 0,           -- Запросов с ключом
+    mailboxes_created BIGINT NOT NULL DEFAULT 0   -- Создано ящиков
+);
+
+-- Владелец ящика; ящики без ключа созданы анонимно
+ALTER TABLE mailboxes ADD COLUMN IF NOT EXISTS api_key_id UUID REFERENCES api_keys(id) ON DELETE SET NULL;
+
+-- Индекс для квот и списка ящиков ключа
+CREATE INDEX IF NOT EXISTS idx_mailboxes_api_key_id ON mailboxes(api_key_id, created_at) WHERE api_key_id IS NOT NULL;
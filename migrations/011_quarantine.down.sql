@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_messages_quarantined;
+DROP INDEX IF EXISTS idx_messages_spam;
+ALTER TABLE messages DROP COLUMN IF EXISTS is_quarantined;
@@ -0,0 +1,6 @@
+-- Карантин: письмо скрыто от владельца ящика, его видит только администратор
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS is_quarantined BOOLEAN NOT NULL DEFAULT false;
+
+-- Выборки спама и карантина в админке
+CREATE INDEX IF NOT EXISTS idx_messages_spam ON messages(received_at DESC) WHERE is_spam;
+CREATE INDEX IF NOT EXISTS idx_messages_quarantined ON messages(received_at DESC) WHERE is_quarantined;
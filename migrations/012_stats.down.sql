@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS stats_daily;
+DROP TABLE IF EXISTS stats_hourly;
@@ -0,0 +1,15 @@
+-- Постоянная статистика: счётчики по часам от всех экземпляров сервиса
+CREATE TABLE IF NOT EXISTS stats_hourly (
+    bucket TIMESTAMPTZ NOT NULL, -- Начало часа
+    name VARCHAR(64) NOT NULL,   -- Имя счётчика
+    value BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (bucket, name)
+);
+
+-- Суточные итоги: собираются из stats_hourly и хранятся дольше часовых
+CREATE TABLE IF NOT EXISTS stats_daily (
+    day TIMESTAMPTZ NOT NULL,  -- Начало суток (UTC)
+    name VARCHAR(64) NOT NULL,
+    value BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, name)
+);
@@ -0,0 +1,11 @@
+-- Версия схемы БД: /readyz сравнивает её с версией, которую ожидает код
+-- Каждая следующая миграция добавляет сюда свой номер
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Миграции 001–013 уже применены к этому моменту
+INSERT INTO schema_migrations (version)
+SELECT generate_series(1, 13)
+ON CONFLICT (version) DO NOTHING;
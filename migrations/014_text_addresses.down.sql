@@ -0,0 +1,13 @@
+-- Длинные значения обрезаются до прежнего предела
+ALTER TABLE greylist ALTER COLUMN recipient TYPE VARCHAR(255) USING left(recipient, 255);
+ALTER TABLE greylist ALTER COLUMN sender TYPE VARCHAR(255) USING left(sender, 255);
+
+ALTER TABLE message_envelopes ALTER COLUMN rcpt_to TYPE VARCHAR(255) USING left(rcpt_to, 255);
+ALTER TABLE message_envelopes ALTER COLUMN mail_from TYPE VARCHAR(255) USING left(mail_from, 255);
+ALTER TABLE message_envelopes ALTER COLUMN helo TYPE VARCHAR(255) USING left(helo, 255);
+
+ALTER TABLE messages ALTER COLUMN tag TYPE VARCHAR(255) USING left(tag, 255);
+ALTER TABLE messages ALTER COLUMN recipient TYPE VARCHAR(255) USING left(recipient, 255);
+ALTER TABLE messages ALTER COLUMN from_address TYPE VARCHAR(255) USING left(from_address, 255);
+
+DELETE FROM schema_migrations WHERE version = 14;
@@ -0,0 +1,15 @@
+-- Адреса и имена из SMTP-сессии задаёт клиент, и go-smtp пропускает строки
+-- длиннее 255 символов: такое письмо не сохранялось бы целиком
+-- Смена VARCHAR на TEXT не переписывает таблицы и индексы
+ALTER TABLE messages ALTER COLUMN from_address TYPE TEXT;
+ALTER TABLE messages ALTER COLUMN recipient TYPE TEXT;
+ALTER TABLE messages ALTER COLUMN tag TYPE TEXT;
+
+ALTER TABLE message_envelopes ALTER COLUMN helo TYPE TEXT;
+ALTER TABLE message_envelopes ALTER COLUMN mail_from TYPE TEXT;
+ALTER TABLE message_envelopes ALTER COLUMN rcpt_to TYPE TEXT;
+
+ALTER TABLE greylist ALTER COLUMN sender TYPE TEXT;
+ALTER TABLE greylist ALTER COLUMN recipient TYPE TEXT;
+
+INSERT INTO schema_migrations (version) VALUES (14) ON CONFLICT (version) DO NOTHING;